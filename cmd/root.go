@@ -5,22 +5,26 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"io"
 	log2 "log"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"os/signal"
 	"path"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
 	"github.com/NYTimes/logrotate"
 	"github.com/apex/log"
 	"github.com/apex/log/handlers/multi"
-	"github.com/docker/docker/client"
 	"github.com/gammazero/workerpool"
+	"github.com/go-co-op/gocron"
 	"github.com/mitchellh/colorstring"
 	"github.com/spf13/cobra"
 	"golang.org/x/crypto/acme"
@@ -30,7 +34,10 @@ import (
 	"github.com/pterodactyl/wings/environment"
 	"github.com/pterodactyl/wings/internal/cron"
 	"github.com/pterodactyl/wings/internal/database"
+	"github.com/pterodactyl/wings/internal/restart"
 	"github.com/pterodactyl/wings/loggers/cli"
+	"github.com/pterodactyl/wings/loggers/json"
+	"github.com/pterodactyl/wings/loggers/levels"
 	"github.com/pterodactyl/wings/remote"
 	"github.com/pterodactyl/wings/router"
 	"github.com/pterodactyl/wings/server"
@@ -43,6 +50,13 @@ var (
 	debug      = false
 )
 
+// The files the Wings log and access log are written to. The access log is nil if
+// it has been disabled.
+var (
+	logFile   *logrotate.File
+	accessLog *log.Logger
+)
+
 var rootCommand = &cobra.Command{
 	Use:   "wings",
 	Short: "Runs the API server allowing programmatic control of game servers for Pterodactyl Panel.",
@@ -127,7 +141,8 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 		config.Get().PanelLocation,
 		remote.WithCredentials(config.Get().AuthenticationTokenId, config.Get().AuthenticationToken),
 		remote.WithHttpClient(&http.Client{
-			Timeout: time.Second * time.Duration(config.Get().RemoteQuery.Timeout),
+			Timeout:   time.Second * time.Duration(config.Get().RemoteQuery.Timeout),
+			Transport: config.ClientTransport(),
 		}),
 	)
 
@@ -140,6 +155,12 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 		log.WithField("error", err).Fatal("failed to load server configurations")
 	}
 
+	// Restore the crash detection and console throttle state handed over by the
+	// previous process if this process was started by a graceful restart.
+	if err := manager.RestoreRuntimeState(); err != nil {
+		log.WithField("error", err).Warn("failed to restore server runtime state from previous process")
+	}
+
 	if err := environment.ConfigureDocker(cmd.Context()); err != nil {
 		log.WithField("error", err).Fatal("failed to configure docker environment")
 	}
@@ -190,67 +211,7 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 		}
 
 		pool.Submit(func() {
-			s.Log().Info("configuring server environment and restoring to previous state")
-			var st string
-			if state, exists := states[s.ID()]; exists {
-				st = state
-			}
-
-			// Use a timed context here to avoid booting issues where Docker hangs for a
-			// specific container that would cause Wings to be un-bootable until the entire
-			// machine is rebooted. It is much better for us to just have a single failed
-			// server instance than an entire offline node.
-			//
-			// @see https://github.com/pterodactyl/panel/issues/2475
-			// @see https://github.com/pterodactyl/panel/issues/3358
-			ctx, cancel := context.WithTimeout(cmd.Context(), time.Second*30)
-			defer cancel()
-
-			r, err := s.Environment.IsRunning(ctx)
-			// We ignore missing containers because we don't want to actually block booting of wings at this
-			// point. If we didn't do this, and you pruned all the images and then started wings you could
-			// end up waiting a long period of time for all the images to be re-pulled on Wings boot rather
-			// than when the server itself is started.
-			if err != nil && !client.IsErrNotFound(err) {
-				s.Log().WithField("error", err).Error("error checking server environment status")
-			}
-
-			// Check if the server was previously running. If so, attempt to start the server now so that Wings
-			// can pick up where it left off. If the environment does not exist at all, just create it and then allow
-			// the normal flow to execute.
-			//
-			// This does mean that booting wings after a catastrophic machine crash and wiping out the Docker images
-			// as a result will result in a slow boot.
-			if !r && (st == environment.ProcessRunningState || st == environment.ProcessStartingState) {
-				if err := s.HandlePowerAction(server.PowerActionStart); err != nil {
-					s.Log().WithField("error", err).Warn("failed to return server to running state")
-				}
-			} else if r || (!r && s.IsRunning()) {
-				// If the server is currently running on Docker, mark the process as being in that state.
-				// We never want to stop an instance that is currently running external from Wings since
-				// that is a good way of keeping things running even if Wings gets in a very corrupted state.
-				//
-				// This will also validate that a server process is running if the last tracked state we have
-				// is that it was running, but we see that the container process is not currently running.
-				s.Log().Info("detected server is running, re-attaching to process...")
-
-				s.Environment.SetState(environment.ProcessRunningState)
-				if err := s.Environment.Attach(ctx); err != nil {
-					s.Log().WithField("error", err).Warn("failed to attach to running server environment")
-				}
-			} else {
-				// At this point we've determined that the server should indeed be in an offline state, so we'll
-				// make a call to set that state just to ensure we don't ever accidentally end up with some invalid
-				// state being tracked.
-				s.Environment.SetState(environment.ProcessOfflineState)
-			}
-
-			if state := s.Environment.State(); state == environment.ProcessStartingState || state == environment.ProcessRunningState {
-				s.Log().Debug("re-syncing server configuration for already running server")
-				if err := s.Sync(); err != nil {
-					s.Log().WithError(err).Error("failed to re-sync server configuration")
-				}
-			}
+			s.Boot(cmd.Context(), states[s.ID()])
 		})
 	}
 
@@ -264,16 +225,18 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 		}
 	}()
 
-	if s, err := cron.Scheduler(cmd.Context(), manager); err != nil {
+	scheduler, err := cron.Scheduler(cmd.Context(), manager)
+	if err != nil {
 		log.WithField("error", err).Fatal("failed to initialize cron system")
-	} else {
-		log.WithField("subsystem", "cron").Info("starting cron processes")
-		s.StartAsync()
 	}
+	log.WithField("subsystem", "cron").Info("starting cron processes")
+	scheduler.StartAsync()
+	registerReloadHooks(cmd.Context(), manager, pclient, scheduler)
 
+	sftpServer := sftp.New(manager)
 	go func() {
 		// Run the SFTP server.
-		if err := sftp.New(manager).Run(); err != nil {
+		if err := sftpServer.Run(); err != nil {
 			log.WithError(err).Fatal("failed to initialize the sftp server")
 			return
 		}
@@ -317,10 +280,26 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 	// and external clients.
 	s := &http.Server{
 		Addr:      api.Host + ":" + strconv.Itoa(api.Port),
-		Handler:   router.Configure(manager, pclient),
+		Handler:   router.Configure(manager, pclient, accessLogger()),
 		TLSConfig: config.DefaultTLSConfig,
 	}
 
+	// Client certificates are verified if they are presented, but only required on the
+	// routes that are not accessed directly by browsers.
+	if api.Mtls.Enabled {
+		if !api.Ssl.Enabled && !autotls {
+			log.Fatal("mutual TLS requires SSL to be enabled for the webserver")
+		}
+		pool, err := api.Mtls.ClientCertPool()
+		if err != nil {
+			log.WithField("error", err).Fatal("failed to configure mutual TLS for the webserver")
+		}
+		s.TLSConfig = s.TLSConfig.Clone()
+		s.TLSConfig.ClientCAs = pool
+		s.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		log.WithField("client_ca", api.Mtls.ClientCAFile).Info("mutual TLS is enabled for protected webserver routes")
+	}
+
 	profile, _ := cmd.Flags().GetBool("pprof")
 	if profile {
 		if r, _ := cmd.Flags().GetInt("pprof-block-rate"); r > 0 {
@@ -331,10 +310,29 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 
 		profilePort, _ := cmd.Flags().GetInt("pprof-port")
 		go func() {
-			http.ListenAndServe(fmt.Sprintf("localhost:%d", profilePort), nil)
+			if l, err := restart.Listen("pprof", fmt.Sprintf("localhost:%d", profilePort)); err == nil {
+				http.Serve(l, nil)
+			}
 		}()
 	}
 
+	// The listener is inherited from the previous process when started by a graceful
+	// restart, so that no connections are refused while booting.
+	l, err := restart.Listen("http", s.Addr)
+	if err != nil {
+		log.WithField("error", err).Fatal("failed to configure HTTP server")
+	}
+	drained := make(chan struct{})
+	// Once a new process has taken over, stop the background tasks of this process
+	// so that they are not run by both processes while this one is draining.
+	handover := func() {
+		scheduler.Stop()
+		ticker.Stop()
+		manager.Detach()
+	}
+	go handleGracefulRestart(manager, s, sftpServer, handover, drained)
+	go handleConfigReload()
+
 	// Check if the server should run with TLS but using autocert.
 	if autotls {
 		m := autocert.Manager{
@@ -351,28 +349,152 @@ func rootCmdRun(cmd *cobra.Command, _ []string) {
 
 		// Start the autocert server.
 		go func() {
-			if err := http.ListenAndServe(":http", m.HTTPHandler(nil)); err != nil {
+			al, err := restart.Listen("acme", ":http")
+			if err == nil {
+				err = http.Serve(al, m.HTTPHandler(nil))
+			}
+			if err != nil {
 				log.WithError(err).Error("failed to serve autocert http server")
 			}
 		}()
 		// Start the main http server with TLS using autocert.
-		if err := s.ListenAndServeTLS("", ""); err != nil {
+		restart.Ready()
+		if err := s.ServeTLS(l, "", ""); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.WithFields(log.Fields{"auto_tls": true, "tls_hostname": tlshostname, "error": err}).Fatal("failed to configure HTTP server using auto-tls")
 		}
-		return
+		<-drained
+		os.Exit(0)
 	}
 
 	// Check if main http server should run with TLS. Otherwise, reset the TLS
 	// config on the server and then serve it over normal HTTP.
+	restart.Ready()
 	if api.Ssl.Enabled {
-		if err := s.ListenAndServeTLS(api.Ssl.CertificateFile, api.Ssl.KeyFile); err != nil {
+		if err := s.ServeTLS(l, api.Ssl.CertificateFile, api.Ssl.KeyFile); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.WithFields(log.Fields{"auto_tls": false, "error": err}).Fatal("failed to configure HTTPS server")
 		}
-		return
+	} else {
+		s.TLSConfig = nil
+		if err := s.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.WithField("error", err).Fatal("failed to configure HTTP server")
+		}
 	}
-	s.TLSConfig = nil
-	if err := s.ListenAndServe(); err != nil {
-		log.WithField("error", err).Fatal("failed to configure HTTP server")
+	// The webserver is only closed once a new process has taken over, wait for the
+	// requests in progress to finish before exiting.
+	<-drained
+	os.Exit(0)
+}
+
+// registerReloadHooks registers the functions used to reconfigure the subsystems
+// that only read their settings once when the configuration is reloaded.
+func registerReloadHooks(ctx context.Context, m *server.Manager, pclient remote.Client, scheduler *gocron.Scheduler) {
+	config.OnReload("remote", func(c *config.Configuration) error {
+		pclient.SetCredentials(c.AuthenticationTokenId, c.AuthenticationToken)
+		return nil
+	}, "token_id", "token")
+	config.OnReload("logging", func(_ *config.Configuration) error {
+		configureLogging()
+		return nil
+	}, "debug", "system.logging")
+	config.OnReload("cron", func(_ *config.Configuration) error {
+		return cron.Reload(ctx, scheduler, m)
+	}, "system.timezone", "system.activity_send_interval", "system.activity_send_count", "docker.images.prune_interval")
+	config.OnReload("throttles", func(_ *config.Configuration) error {
+		m.ConfigureThrottles()
+		return nil
+	}, "throttles")
+	config.OnReload("console", func(c *config.Configuration) error {
+		server.SetAppName(c.AppName)
+		return nil
+	}, "app_name")
+}
+
+// handleConfigReload reloads the configuration from the disk when SIGHUP is
+// received. The signal is also sent by logrotate after rotating the log files,
+// which is harmless since reloading an unchanged configuration does nothing.
+func handleConfigReload() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	for range ch {
+		if _, err := config.ReloadFromDisk(); err != nil {
+			log.WithField("error", err).Error("failed to reload configuration, continuing to use the current configuration")
+		}
+	}
+}
+
+// handleGracefulRestart restarts Wings when SIGUSR2 is received. SIGHUP is not used
+// since it reloads the configuration and is sent by logrotate after rotating the
+// log files. A new process is started which inherits the HTTP and SFTP listeners,
+// and once it has finished booting this process calls handover to stop its own
+// background tasks, stops accepting connections, closes the open websockets, waits
+// for the requests and server tasks in progress to finish and then closes the
+// drained channel. Server tasks are only waited on for up to restart.TaskTimeout.
+// Servers keep running throughout, and the new process re-attaches to them while
+// booting.
+//
+// Restarting is refused while any server is installing, backing up, restoring or
+// transferring, since those tasks cannot be handed over to the new process.
+func handleGracefulRestart(m *server.Manager, s *http.Server, sftpServer *sftp.SFTPServer, handover func(), drained chan<- struct{}) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR2)
+	for sig := range ch {
+		if busy := m.Busy(); len(busy) > 0 {
+			log.WithField("servers", busy).Error("cannot gracefully restart wings while servers are installing, backing up, restoring or transferring")
+			continue
+		}
+		log.WithField("signal", sig.String()).Info("received signal, gracefully restarting wings")
+		if err := m.PersistStates(); err != nil {
+			log.WithField("error", err).Warn("failed to persist server states to disk")
+		}
+		if err := m.PersistRuntimeState(); err != nil {
+			log.WithField("error", err).Warn("failed to persist server runtime state to disk")
+		}
+		if err := restart.Start(); err != nil {
+			log.WithField("error", err).Error("failed to gracefully restart wings, continuing to run the current process")
+			_ = os.Remove(config.Get().System.GetRuntimeStatePath())
+			continue
+		}
+		signal.Stop(ch)
+		handover()
+
+		log.Info("new wings process has finished booting, waiting for requests in progress to finish")
+		ctx, cancel := context.WithTimeout(context.Background(), restart.DrainTimeout)
+		var wg sync.WaitGroup
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if err := router.CloseWebsockets(ctx); err != nil {
+				log.WithField("error", err).Warn("failed to close websocket connections before exiting")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := s.Shutdown(ctx); err != nil {
+				log.WithField("error", err).Warn("failed to finish http requests in progress before exiting")
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := sftpServer.Shutdown(ctx); err != nil {
+				log.WithField("error", err).Warn("failed to wait for sftp connections to close before exiting")
+			}
+		}()
+		wg.Wait()
+		cancel()
+
+		// A task may have been started by a request received while the new process
+		// was booting, wait for those to finish so the Panel is notified of the result.
+		deadline := time.Now().Add(restart.TaskTimeout)
+		for busy := m.Busy(); len(busy) > 0; busy = m.Busy() {
+			if time.Now().After(deadline) {
+				log.WithField("servers", busy).Warn("timed out waiting for server tasks in progress to finish, exiting")
+				break
+			}
+			log.WithField("servers", busy).Info("waiting for server tasks in progress to finish before exiting")
+			time.Sleep(time.Second * 5)
+		}
+		close(drained)
+		return
 	}
 }
 
@@ -410,14 +532,78 @@ func initLogging() {
 	if err != nil {
 		log2.Fatalf("cmd/root: failed to create wings log: %s", err)
 	}
-	log.SetLevel(log.InfoLevel)
-	if config.Get().Debug {
-		log.SetLevel(log.DebugLevel)
+	logFile = w
+	if config.Get().System.Logging.AccessLog {
+		a, err := logrotate.NewFile(filepath.Join(dir, "/access.log"))
+		if err != nil {
+			log2.Fatalf("cmd/root: failed to create access log: %s", err)
+		}
+		accessLog = &log.Logger{Handler: accessLogHandler(a), Level: log.InfoLevel}
 	}
-	log.SetHandler(multi.New(cli.Default, cli.New(w.File, false)))
+	configureLogging()
 	log.WithField("path", p).Info("writing log files to disk")
 }
 
+// configureLogging sets the handler and levels of the Wings log using the logging
+// configuration.
+func configureLogging() {
+	cfg := config.Get()
+	level, err := log.ParseLevel(cfg.System.Logging.Level)
+	if err != nil {
+		defer log.WithField("level", cfg.System.Logging.Level).Warn("invalid log level configured, using info")
+		level = log.InfoLevel
+	}
+	if cfg.Debug {
+		level = log.DebugLevel
+	}
+	subsystems := make(map[string]log.Level, len(cfg.System.Logging.Subsystems))
+	for name, v := range cfg.System.Logging.Subsystems {
+		l, err := log.ParseLevel(v)
+		if err != nil {
+			defer log.WithFields(log.Fields{"subsystem": name, "level": v}).Warn("invalid log level configured for subsystem, ignoring")
+			continue
+		}
+		subsystems[name] = l
+	}
+
+	h := levels.New(multi.New(logHandler(os.Stderr, true), logHandler(logFile, false)), level, subsystems)
+	log.SetLevel(h.MinLevel())
+	log.SetHandler(h)
+}
+
+// accessLogger returns the logger for the access log, or nil if it is disabled.
+func accessLogger() log.Interface {
+	if accessLog == nil {
+		return nil
+	}
+	return accessLog
+}
+
+// logHandler returns the handler for the configured log format writing to w. Colors
+// are only used for text output to the console.
+func logHandler(w io.Writer, console bool) log.Handler {
+	if config.Get().System.Logging.Format == "json" {
+		return json.New(w)
+	}
+	if console {
+		return cli.Default
+	}
+	return cli.New(w, false)
+}
+
+// accessLogHandler returns the handler for the access log writing to w. Entries are
+// written in the log format configured at the time they are logged, so that a new
+// format is used once the configuration has been reloaded.
+func accessLogHandler(w io.Writer) log.Handler {
+	text, js := cli.New(w, false), json.New(w)
+	return log.HandlerFunc(func(e *log.Entry) error {
+		if config.Get().System.Logging.Format == "json" {
+			return js.HandleLog(e)
+		}
+		return text.HandleLog(e)
+	})
+}
+
 // Prints the wings logo, nothing special here!
 func printLogo() {
 	fmt.Printf(colorstring.Color(`
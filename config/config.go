@@ -10,6 +10,7 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -86,6 +87,9 @@ type ApiConfiguration struct {
 		KeyFile         string `json:"key" yaml:"key"`
 	}
 
+	// Mutual TLS configuration for the daemon. This cannot be changed by the Panel.
+	Mtls MtlsConfiguration `json:"-" yaml:"mtls"`
+
 	// Determines if functionality for allowing remote download of files into server directories
 	// is enabled on this instance. If set to "true" remote downloads will not be possible for
 	// servers.
@@ -98,6 +102,25 @@ type ApiConfiguration struct {
 	TrustedProxies []string `json:"trusted_proxies" yaml:"trusted_proxies"`
 }
 
+// MtlsConfiguration defines the configuration for mutual TLS between the Panel and
+// Wings, and between Wings nodes.
+type MtlsConfiguration struct {
+	// Enabled requires requests to protected routes and the transfer endpoints to
+	// present a client certificate signed by the client CA. Routes authenticated by
+	// signed JWTs, such as websockets and file downloads, are not affected since
+	// they are accessed directly by browsers. SSL must be enabled for the API.
+	Enabled bool `yaml:"enabled"`
+
+	// ClientCAFile is the path to a bundle of PEM encoded certificate authorities used
+	// to verify the client certificates presented by the Panel and other nodes.
+	ClientCAFile string `yaml:"client_ca"`
+
+	// CertificateFile and KeyFile are the client certificate presented by this node
+	// when making requests to the Panel and to other nodes.
+	CertificateFile string `yaml:"cert"`
+	KeyFile         string `yaml:"key"`
+}
+
 // RemoteQueryConfiguration defines the configuration settings for remote requests
 // from Wings to the Panel.
 type RemoteQueryConfiguration struct {
@@ -204,6 +227,15 @@ type SystemConfiguration struct {
 	// The number of lines to send when a server connects to the websocket.
 	WebsocketLogCount int `default:"150" yaml:"websocket_log_count"`
 
+	// The number of console commands to retain in the local history for each server.
+	// This history is used by clients to restore their input history when reconnecting
+	// to the websocket. Set to 0 to disable storing command history.
+	CommandHistorySize int `default:"100" yaml:"command_history_size"`
+
+	// The number of installation runs, including their output, to retain for each
+	// server. Set to 0 to disable storing installation history.
+	InstallHistorySize int `default:"10" yaml:"install_history_size"`
+
 	Sftp SftpConfiguration `yaml:"sftp"`
 
 	CrashDetection CrashDetection `yaml:"crash_detection"`
@@ -212,6 +244,12 @@ type SystemConfiguration struct {
 
 	Transfers Transfers `yaml:"transfers"`
 
+	DiskQuota DiskQuota `yaml:"disk_quota"`
+
+	InstallTemplates InstallTemplates `yaml:"install_templates"`
+
+	Logging Logging `yaml:"logging"`
+
 	OpenatMode string `default:"auto" yaml:"openat_mode"`
 }
 
@@ -252,13 +290,91 @@ type Backups struct {
 }
 
 type Transfers struct {
-	// DownloadLimit imposes a Network I/O read limit when downloading a transfer archive.
+	// DownloadLimit imposes a Network I/O read limit when receiving a server from
+	// another node.
 	//
 	// If the value is less than 1, the write speed is unlimited,
 	// if the value is greater than 0, the write speed is the value in MiB/s.
 	//
 	// Defaults to 0 (unlimited)
 	DownloadLimit int `default:"0" yaml:"download_limit"`
+
+	// UploadLimit imposes a Network I/O write limit when sending a server to another
+	// node.
+	//
+	// If the value is less than 1, the send speed is unlimited,
+	// if the value is greater than 0, the send speed is the value in MiB/s.
+	//
+	// Defaults to 0 (unlimited)
+	UploadLimit int `default:"0" yaml:"upload_limit"`
+}
+
+type DiskQuota struct {
+	// Driver determines how the disk space used by servers is tracked and limited.
+	//
+	// "walk" -> walks every file in the server directory every DiskCheckInterval seconds
+	// "project" -> uses XFS or ext4 project quotas, the filesystem containing the data
+	//              directory must be mounted with the "prjquota" option
+	// "loopback" -> stores each server on its own loopback mounted filesystem image
+	//
+	// The "project" and "loopback" drivers report exact usage instantly and are enforced
+	// by the kernel. If a driver cannot be used for a server, it falls back to "walk".
+	//
+	// Defaults to "walk"
+	Driver string `default:"walk" yaml:"driver"`
+
+	// LoopbackDirectory is the directory where the filesystem images for each server are
+	// stored when using the "loopback" driver.
+	LoopbackDirectory string `default:"/var/lib/pterodactyl/disks" yaml:"loopback_directory"`
+
+	// LoopbackFilesystem is the filesystem used to format new images when using the
+	// "loopback" driver, either "ext4" or "xfs".
+	LoopbackFilesystem string `default:"ext4" yaml:"loopback_filesystem"`
+
+	// Accounting determines how the size of each file is counted when using the "walk"
+	// driver. Files with multiple hard links are only ever counted once.
+	//
+	// "apparent" -> counts the size of the file, as reported by "ls"
+	// "allocated" -> counts the blocks allocated on the disk for the file, as reported
+	//                by "du", so that sparse files only count the data actually written
+	//
+	// Defaults to "apparent"
+	Accounting string `default:"apparent" yaml:"accounting"`
+}
+
+type InstallTemplates struct {
+	// Directory is the directory containing templates stored on the node, which can be
+	// referenced by name from an installation script.
+	Directory string `default:"/var/lib/pterodactyl/templates" yaml:"directory"`
+
+	// CacheDirectory is the directory where templates downloaded from a URL are cached,
+	// so that they are only downloaded once for every server using them.
+	CacheDirectory string `default:"/var/lib/pterodactyl/templates/.cache" yaml:"cache_directory"`
+
+	// CacheSize is the maximum size of the template cache in MiB. Once the cache grows
+	// beyond this size the least recently used templates are removed. Set to 0 to
+	// remove downloaded templates as soon as they are no longer used.
+	//
+	// Defaults to 10240 (10 GiB)
+	CacheSize int64 `default:"10240" yaml:"cache_size"`
+}
+
+type Logging struct {
+	// Format is the format log entries are written in, either "text" or "json". JSON
+	// output writes each entry as a single line for use with log aggregation systems.
+	Format string `default:"text" yaml:"format"`
+
+	// Level is the minimum level of entries that are logged, one of "debug", "info",
+	// "warn", "error" or "fatal". Running Wings with --debug always uses "debug".
+	Level string `default:"info" yaml:"level"`
+
+	// Subsystems overrides the level for entries logged by a specific subsystem, such
+	// as "sftp", "cron", "environment", "transfer", "backup" or "http".
+	Subsystems map[string]string `yaml:"subsystems"`
+
+	// AccessLog enables writing an entry for every request made to the API to the
+	// access.log file in the log directory, separate from the Wings log.
+	AccessLog bool `default:"true" yaml:"access_log"`
 }
 
 type ConsoleThrottles struct {
@@ -296,6 +412,10 @@ type Configuration struct {
 	// validate against it.
 	AuthenticationToken string `json:"token" yaml:"token"`
 
+	// ApiKeys are additional tokens that can access a limited part of the API. These
+	// cannot be changed by the Panel.
+	ApiKeys []ApiKey `json:"-" yaml:"api_keys"`
+
 	Api    ApiConfiguration    `json:"api" yaml:"api"`
 	System SystemConfiguration `json:"system" yaml:"system"`
 	Docker DockerConfiguration `json:"docker" yaml:"docker"`
@@ -313,6 +433,15 @@ type Configuration struct {
 	// This is required to have the "Server Mounts" feature work properly.
 	AllowedMounts []string `json:"-" yaml:"allowed_mounts"`
 
+	// AllowedDevices is a list of host devices, such as "/dev/fuse" or "/dev/net/tun",
+	// that the Panel is allowed to pass through to a server container.
+	AllowedDevices []string `json:"-" yaml:"allowed_devices"`
+
+	// AllowedUlimits is a map of the ulimits the Panel is allowed to set for a server
+	// container, such as "nofile" or "memlock", to the maximum hard limit that may be
+	// requested. A maximum of -1 allows any value, including unlimited.
+	AllowedUlimits map[string]int64 `json:"-" yaml:"allowed_ulimits"`
+
 	// AllowedOrigins is a list of allowed request origins.
 	// The Panel URL is automatically allowed, this is only needed for adding
 	// additional origins.
@@ -500,19 +629,11 @@ func EnsurePterodactylUser() error {
 // FromFile reads the configuration from the provided file and stores it in the
 // global singleton for this instance.
 func FromFile(path string) error {
-	b, err := os.ReadFile(path)
-	if err != nil {
-		return err
-	}
-	c, err := NewAtPath(path)
+	c, err := Load(path)
 	if err != nil {
 		return err
 	}
 
-	if err := yaml.Unmarshal(b, c); err != nil {
-		return err
-	}
-
 	// Store this configuration in the global state.
 	Set(c)
 	return nil
@@ -564,10 +685,28 @@ func ConfigureDirectories() error {
 	return nil
 }
 
+// logrotateTemplate is the logrotate configuration written for the given log
+// files.
+var logrotateTemplate = template.Must(template.New("logrotate").Parse(`{{range .}}{{.}} {{end}}{
+    size 10M
+    compress
+    delaycompress
+    dateext
+    maxage 7
+    missingok
+    notifempty
+    postrotate
+        /usr/bin/systemctl kill -s HUP wings.service >/dev/null 2>&1 || true
+    endscript
+}
+`))
+
 // EnableLogRotation writes a logrotate file for wings to the system logrotate
 // configuration directory if one exists and a logrotate file is not found. This
 // allows us to basically automate away the log rotation for most installs, but
-// also enable users to make modifications on their own.
+// also enable users to make modifications on their own. If the access log is
+// enabled and an existing logrotate file does not rotate it, a configuration for
+// the access log is appended to the file.
 //
 // This function IS NOT thread-safe.
 func EnableLogRotation() error {
@@ -581,9 +720,29 @@ func EnableLogRotation() error {
 	} else if (err != nil && os.IsNotExist(err)) || !st.IsDir() {
 		return nil
 	}
-	if _, err := os.Stat("/etc/logrotate.d/wings"); err == nil || !os.IsNotExist(err) {
+
+	accessLog := path.Join(_config.System.LogDirectory, "access.log")
+	b, err := os.ReadFile("/etc/logrotate.d/wings")
+	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
+	if err == nil {
+		if !_config.System.Logging.AccessLog || logrotateIncludes(b, accessLog) {
+			return nil
+		}
+		log.Info("log rotation configuration does not include the access log: updating file now")
+		f, err := os.OpenFile("/etc/logrotate.d/wings", os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		if len(b) > 0 && b[len(b)-1] != '\n' {
+			if _, err := f.WriteString("\n"); err != nil {
+				return err
+			}
+		}
+		return errors.Wrap(logrotateTemplate.Execute(f, []string{accessLog}), "config: failed to write logrotate to disk")
+	}
 
 	log.Info("no log rotation configuration found: adding file now")
 	// If we've gotten to this point it means the logrotate directory exists on the system
@@ -595,23 +754,54 @@ func EnableLogRotation() error {
 	}
 	defer f.Close()
 
-	t, err := template.New("logrotate").Parse(`{{.LogDirectory}}/wings.log {
-    size 10M
-    compress
-    delaycompress
-    dateext
-    maxage 7
-    missingok
-    notifempty
-    postrotate
-        /usr/bin/systemctl kill -s HUP wings.service >/dev/null 2>&1 || true
-    endscript
-}`)
-	if err != nil {
-		return err
+	files := []string{path.Join(_config.System.LogDirectory, "wings.log"), accessLog}
+	return errors.Wrap(logrotateTemplate.Execute(f, files), "config: failed to write logrotate to disk")
+}
+
+// logrotateScriptDirectives are the logrotate directives that are followed by a
+// script ending with "endscript".
+var logrotateScriptDirectives = []string{"postrotate", "prerotate", "firstaction", "lastaction", "preremove"}
+
+// logrotateIncludes returns true if the logrotate configuration rotates the file,
+// either by listing its path or by a glob pattern matching it.
+func logrotateIncludes(b []byte, file string) bool {
+	var block, script bool
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if script {
+			script = line != "endscript"
+			continue
+		}
+		if block {
+			if fields := strings.Fields(line); slices.Contains(logrotateScriptDirectives, fields[0]) {
+				script = true
+			} else if strings.HasSuffix(line, "}") {
+				block = false
+			}
+			continue
+		}
+		// Outside of a block each line lists the files the following block applies to.
+		if i := strings.Index(line, "{"); i >= 0 {
+			block = !strings.HasSuffix(line, "}")
+			line = line[:i]
+		}
+		for _, pattern := range strings.Fields(line) {
+			pattern = strings.Trim(pattern, `"'`)
+			if ok, err := filepath.Match(pattern, file); pattern == file || (err == nil && ok) {
+				return true
+			}
+		}
 	}
+	return false
+}
 
-	return errors.Wrap(t.Execute(f, _config.System), "config: failed to write logrotate to disk")
+// GetRuntimeStatePath returns the location of the JSON file used to hand over the
+// in-memory state of each server to a new process during a graceful restart.
+func (sc *SystemConfiguration) GetRuntimeStatePath() string {
+	return path.Join(sc.RootDirectory, "/runtime-state.json")
 }
 
 // GetStatesPath returns the location of the JSON file that tracks server states.
@@ -619,6 +809,18 @@ func (sc *SystemConfiguration) GetStatesPath() string {
 	return path.Join(sc.RootDirectory, "/states.json")
 }
 
+// GetServersCachePath returns the location of the JSON file that caches the last
+// server configurations successfully retrieved from the Panel.
+func (sc *SystemConfiguration) GetServersCachePath() string {
+	return path.Join(sc.RootDirectory, "/servers.json")
+}
+
+// GetImagesStatePath returns the location of the JSON file that tracks when each
+// Docker image was last used, so that images are not pruned early after a restart.
+func (sc *SystemConfiguration) GetImagesStatePath() string {
+	return path.Join(sc.RootDirectory, "/images.json")
+}
+
 // ConfigureTimezone sets the timezone data for the configuration if it is
 // currently missing. If a value has been set, this functionality will only run
 // to validate that the timezone being used is valid.
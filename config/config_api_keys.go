@@ -0,0 +1,84 @@
+package config
+
+import (
+	"time"
+)
+
+// The scopes that can be granted to an API key. Each scope grants access to a
+// fixed set of routes on the Wings API, any route not covered by a scope can only
+// be accessed using the node's authentication token.
+const (
+	// ScopeSystemRead allows reading the system information, the list of servers
+	// and the images present on the node.
+	ScopeSystemRead = "system:read"
+	// ScopeServersRead allows reading the details, logs, command history and install
+	// history of a server. The environment variables of servers are only returned
+	// to keys that have also been granted ScopeServersConfig.
+	ScopeServersRead = "servers:read"
+	// ScopeServersConfig allows reading the full configuration of a server, including
+	// its environment variables, when listing or reading servers.
+	ScopeServersConfig = "servers:config"
+	// ScopeFilesRead allows listing and reading the files of a server.
+	ScopeFilesRead = "files:read"
+	// ScopeFilesWrite allows creating, modifying and deleting the files of a server.
+	ScopeFilesWrite = "files:write"
+	// ScopePower allows changing the power state of a server.
+	ScopePower = "power"
+	// ScopeConsole allows sending commands to the console of a server.
+	ScopeConsole = "console"
+	// ScopeBackups allows creating, restoring and deleting the backups of a server.
+	ScopeBackups = "backups"
+	// ScopeInstall allows running the installation process for a server.
+	ScopeInstall = "install"
+)
+
+// ApiKey is an additional token that can be used to access a limited part of the
+// Wings API, for example by monitoring systems that should not hold the token used
+// by the Panel. API keys can only be defined in the configuration file.
+type ApiKey struct {
+	// Name identifies the key in the logs of every request made using it.
+	Name string `yaml:"name"`
+
+	// Token is the bearer token that must be provided in the Authorization header.
+	Token string `yaml:"token"`
+
+	// Scopes is the list of scopes granted to the key, such as "system:read" or
+	// "power".
+	Scopes []string `yaml:"scopes"`
+
+	// Servers is a list of server UUIDs the key is allowed to access. If empty the
+	// key can access every server on the node.
+	Servers []string `yaml:"servers"`
+
+	// ExpiresAt is the time after which the key can no longer be used. If not set
+	// the key never expires.
+	ExpiresAt time.Time `yaml:"expires_at"`
+}
+
+// HasScope returns true if the key has been granted the given scope.
+func (k *ApiKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessServer returns true if the key is allowed to access the server.
+func (k *ApiKey) CanAccessServer(uuid string) bool {
+	if len(k.Servers) == 0 {
+		return true
+	}
+	for _, s := range k.Servers {
+		if s == uuid {
+			return true
+		}
+	}
+	return false
+}
+
+// Expired returns true if the key has expired.
+func (k *ApiKey) Expired() bool {
+	return !k.ExpiresAt.IsZero() && time.Now().After(k.ExpiresAt)
+}
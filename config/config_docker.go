@@ -39,6 +39,26 @@ type DockerNetworkConfiguration struct {
 	EnableICC  bool                    `default:"true" yaml:"enable_icc"`
 	NetworkMTU int64                   `default:"1500" yaml:"network_mtu"`
 	Interfaces dockerNetworkInterfaces `yaml:"interfaces"`
+
+	// DualStack causes every port bound to 0.0.0.0 to also be bound on ::, allowing
+	// servers to be reached over both IPv4 and IPv6. This requires "ip6tables" to be
+	// enabled for the Docker daemon.
+	DualStack bool `default:"false" yaml:"dual_stack"`
+
+	// Isolation controls which servers share a network, and can therefore reach each
+	// other's internal ports. The default of "none" places every server on the network
+	// defined above. A value of "server" creates a dedicated network for each server,
+	// and "owner" creates one network shared by every server belonging to the same
+	// user. Servers can additionally be linked into private networks by the Panel.
+	Isolation string `default:"none" yaml:"isolation"`
+
+	// IsolationPool is the IPv4 range that subnets are allocated from for the networks
+	// created by isolation and for linked networks. Each network is given a subnet
+	// with the configured prefix size that does not overlap any existing network.
+	IsolationPool struct {
+		Subnet string `default:"172.30.0.0/16" yaml:"subnet"`
+		Size   int    `default:"26" yaml:"size"`
+	} `yaml:"isolation_pool"`
 }
 
 // DockerConfiguration defines the docker configuration used by the daemon when
@@ -74,6 +94,17 @@ type DockerConfiguration struct {
 		Cpu    int64 `default:"100" json:"cpu" yaml:"cpu"`
 	} `json:"installer_limits" yaml:"installer_limits"`
 
+	// InstallerTimeout is the maximum number of seconds an installation script may run
+	// before the installer container is killed and the installation is marked as failed.
+	// Eggs may define their own timeout. Set to 0 to allow scripts to run forever.
+	InstallerTimeout int64 `default:"3600" json:"installer_timeout" yaml:"installer_timeout"`
+
+	// InstallerRetryExitCodes are the exit codes of an installation script that indicate
+	// the script failed due to a network error, and may be retried if the egg allows it.
+	// The defaults are the network related exit codes of curl, and wget's network
+	// failure exit code.
+	InstallerRetryExitCodes []int64 `default:"[4,6,7,28,35,52,56]" json:"installer_retry_exit_codes" yaml:"installer_retry_exit_codes"`
+
 	// Overhead controls the memory overhead given to all containers to circumvent certain
 	// software such as the JVM not staying below the maximum memory limit.
 	Overhead Overhead `json:"overhead" yaml:"overhead"`
@@ -92,6 +123,100 @@ type DockerConfiguration struct {
 		Type   string            `default:"local" json:"type" yaml:"type"`
 		Config map[string]string `default:"{\"max-size\":\"5m\",\"max-file\":\"1\",\"compress\":\"false\",\"mode\":\"non-blocking\"}" json:"config" yaml:"config"`
 	} `json:"log_config" yaml:"log_config"`
+
+	// Images controls the cleanup of images that are no longer used by any server
+	// on the node.
+	Images DockerImagesConfiguration `json:"images" yaml:"images"`
+
+	// SecurityProfiles defines named security profiles that can be selected for a
+	// server by the Panel or its Egg. If a profile named "default" is defined it is
+	// used for every server that does not select a profile, otherwise the built-in
+	// defaults are used.
+	SecurityProfiles map[string]SecurityProfile `json:"security_profiles" yaml:"security_profiles"`
+
+	// Ulimits defines the resource limits applied to every server and installation
+	// container on the node. Servers may override these with their own limits if they
+	// are allowed by the AllowedUlimits configuration.
+	Ulimits []Ulimit `json:"ulimits" yaml:"ulimits"`
+
+	// Devices defines the host devices passed through to every server and installation
+	// container on the node.
+	Devices []DeviceMapping `json:"devices" yaml:"devices"`
+}
+
+// SecurityProfile defines the security options applied to a server container.
+type SecurityProfile struct {
+	// Seccomp is the path to a seccomp profile in JSON format on the node, or
+	// "unconfined" to disable seccomp. If empty the Docker default profile is used.
+	Seccomp string `json:"seccomp" yaml:"seccomp"`
+
+	// AppArmor is the name of the AppArmor profile loaded on the host to use for
+	// the container. If empty the Docker default profile is used.
+	AppArmor string `json:"apparmor" yaml:"apparmor"`
+
+	// AllowNewPrivileges allows processes in the container to gain additional
+	// privileges, such as through setuid binaries.
+	AllowNewPrivileges bool `json:"allow_new_privileges" yaml:"allow_new_privileges"`
+
+	// CapAdd is the list of capabilities added to the container, such as "SYS_NICE".
+	CapAdd []string `json:"cap_add" yaml:"cap_add"`
+
+	// CapDrop is the list of capabilities dropped from the container. If not set the
+	// default list of capabilities is dropped. Use ["ALL"] to drop every capability.
+	CapDrop []string `json:"cap_drop" yaml:"cap_drop"`
+
+	// WritableRootfs allows the container to write to its root filesystem rather
+	// than only to its mounts.
+	WritableRootfs bool `json:"writable_rootfs" yaml:"writable_rootfs"`
+
+	// Tmpfs defines additional tmpfs mounts for the container, keyed by the path
+	// inside the container with the mount options as the value. Defining "/tmp"
+	// replaces the default /tmp mount.
+	Tmpfs map[string]string `json:"tmpfs" yaml:"tmpfs"`
+
+	// Ulimits defines the resource limits applied to processes in the container.
+	Ulimits []Ulimit `json:"ulimits" yaml:"ulimits"`
+
+	// Sysctls defines the namespaced kernel parameters set for the container.
+	Sysctls map[string]string `json:"sysctls" yaml:"sysctls"`
+}
+
+// Ulimit defines a resource limit applied to processes in a container, such as
+// "nofile" or "nproc".
+type Ulimit struct {
+	Name string `json:"name" yaml:"name"`
+	Soft int64  `json:"soft" yaml:"soft"`
+	Hard int64  `json:"hard" yaml:"hard"`
+}
+
+// DeviceMapping defines a host device that is passed through to a container.
+type DeviceMapping struct {
+	// Source is the path of the device on the host.
+	Source string `json:"source" yaml:"source"`
+	// Target is the path of the device in the container. If empty the source path
+	// is used.
+	Target string `json:"target" yaml:"target"`
+	// Permissions is the cgroup permissions for the device, any combination of "r",
+	// "w" and "m". If empty "rwm" is used.
+	Permissions string `json:"permissions" yaml:"permissions"`
+}
+
+// DockerImagesConfiguration defines how unused images are removed from the node.
+type DockerImagesConfiguration struct {
+	// PruneInterval is the number of minutes between each run of the job that removes
+	// unused images. A value of 0 disables the job.
+	PruneInterval int `default:"0" json:"prune_interval" yaml:"prune_interval"`
+
+	// PruneGracePeriod is the number of hours an image must go unused before it is
+	// removed. Images are only considered unused once they have not been referenced
+	// by any server, or pulled for an installation, for this entire period.
+	PruneGracePeriod int `default:"24" json:"prune_grace_period" yaml:"prune_grace_period"`
+
+	// PrunePrefixes limits the images that can be removed to those whose repository
+	// starts with one of the given values, such as "ghcr.io/pterodactyl/yolks". No
+	// images are removed if no prefixes are set, a prefix of "*" allows every unused
+	// image on the system to be removed.
+	PrunePrefixes []string `json:"prune_prefixes" yaml:"prune_prefixes"`
 }
 
 func (c DockerConfiguration) ContainerLogConfig() container.LogConfig {
@@ -110,14 +235,30 @@ func (c DockerConfiguration) ContainerLogConfig() container.LogConfig {
 type RegistryConfiguration struct {
 	Username string `yaml:"username"`
 	Password string `yaml:"password"`
+
+	// IdentityToken is an OAuth refresh token used to authenticate with registries
+	// that support it, in place of a username and password.
+	IdentityToken string `yaml:"identity_token"`
+
+	// RegistryToken is a bearer token sent directly to the registry.
+	RegistryToken string `yaml:"registry_token"`
+
+	// CredentialHelper is the name of a Docker credential helper used to retrieve
+	// credentials for the registry, such as "ecr-login" or "gcr". The helper binary
+	// "docker-credential-<name>" must be available in the PATH of the Wings process.
+	// Credentials returned by a helper are cached for a short period of time and
+	// refreshed automatically.
+	CredentialHelper string `yaml:"credential_helper"`
 }
 
 // Base64 returns the authentication for a given registry as a base64 encoded
 // string value.
 func (c RegistryConfiguration) Base64() (string, error) {
 	b, err := json.Marshal(registry.AuthConfig{
-		Username: c.Username,
-		Password: c.Password,
+		Username:      c.Username,
+		Password:      c.Password,
+		IdentityToken: c.IdentityToken,
+		RegistryToken: c.RegistryToken,
 	})
 	if err != nil {
 		return "", err
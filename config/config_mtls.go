@@ -0,0 +1,47 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+
+	"emperror.dev/errors"
+)
+
+// ClientCertPool returns the pool of certificate authorities used to verify the
+// client certificates presented to the API when mutual TLS is enabled.
+func (c MtlsConfiguration) ClientCertPool() (*x509.CertPool, error) {
+	b, err := os.ReadFile(c.ClientCAFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "config: failed to read mtls client ca bundle")
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errors.New("config: mtls client ca bundle does not contain any certificates")
+	}
+	return pool, nil
+}
+
+// ClientTransport returns the HTTP transport used for requests made to the Panel
+// and to other nodes. When mutual TLS is enabled and a client certificate has been
+// configured it is presented to the remote server. The certificate is read from
+// the disk for each new connection so that it can be rotated without a restart.
+func ClientTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	t.TLSClientConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		m := Get().Api.Mtls
+		if !m.Enabled || m.CertificateFile == "" {
+			return &tls.Certificate{}, nil
+		}
+		cert, err := tls.LoadX509KeyPair(m.CertificateFile, m.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "config: failed to load mtls client certificate")
+		}
+		return &cert, nil
+	}
+	return t
+}
@@ -0,0 +1,219 @@
+package config
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"gopkg.in/yaml.v2"
+)
+
+// restartRequired are the settings that are only read while Wings boots, such as
+// the addresses listeners are bound to and the directories used for server data.
+// Changes to these settings are written to the disk, but the running values are
+// kept until Wings is restarted. Each entry also covers every setting below it.
+var restartRequired = []string{
+	"remote",
+	"remote_query.timeout",
+	"api.host",
+	"api.port",
+	"api.ssl",
+	"api.mtls",
+	"api.trusted_proxies",
+	"system.root_directory",
+	"system.log_directory",
+	"system.data",
+	"system.username",
+	"system.user",
+	"system.disk_check_interval",
+	"system.openat_mode",
+	"system.sftp.bind_address",
+	"system.sftp.bind_port",
+	"system.disk_quota.driver",
+	"system.disk_quota.loopback_directory",
+	"system.disk_quota.loopback_filesystem",
+	"system.logging.access_log",
+	"docker.network",
+}
+
+// ReloadFunc reconfigures a subsystem using the reloaded configuration.
+type ReloadFunc func(c *Configuration) error
+
+type reloadHook struct {
+	name     string
+	settings []string
+	fn       ReloadFunc
+}
+
+var (
+	_reloadLock sync.Mutex
+	_hooks      []reloadHook
+)
+
+// ReloadResult describes the settings that were changed by reloading the
+// configuration, using their YAML keys.
+type ReloadResult struct {
+	// Applied are the changed settings that are now in use.
+	Applied []string `json:"applied"`
+	// RestartRequired are the changed settings that only take effect once Wings
+	// has been restarted.
+	RestartRequired []string `json:"restart_required"`
+	// Failed are the changed settings that could not be applied because the
+	// subsystem using them failed to be reconfigured.
+	Failed []string `json:"failed"`
+}
+
+// OnReload registers a function that is called to reconfigure a subsystem when
+// the configuration is reloaded and any of the given settings, or the settings
+// below them, have changed.
+func OnReload(name string, fn ReloadFunc, settings ...string) {
+	_reloadLock.Lock()
+	_hooks = append(_hooks, reloadHook{name: name, settings: settings, fn: fn})
+	_reloadLock.Unlock()
+}
+
+// Load reads the configuration from the provided file without storing it in the
+// global state.
+func Load(path string) (*Configuration, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := NewAtPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// FromDisk reads the configuration file the running configuration was loaded from
+// without storing it in the global state. Settings changed in the file that require
+// a restart have the values from the file rather than their running values.
+func FromDisk() (*Configuration, error) {
+	c, err := Load(Get().path)
+	if err != nil {
+		return nil, errors.Wrap(err, "config: failed to read configuration file")
+	}
+	return c, nil
+}
+
+// ReloadFromDisk reads the configuration file the running configuration was loaded
+// from and reloads the configuration using it.
+func ReloadFromDisk() (*ReloadResult, error) {
+	c, err := FromDisk()
+	if err != nil {
+		return nil, err
+	}
+	return Reload(c), nil
+}
+
+// Reload replaces the global configuration with the provided configuration and
+// reconfigures every subsystem affected by the settings that were changed. The
+// running values of settings that require a restart are kept.
+func Reload(c *Configuration) *ReloadResult {
+	_reloadLock.Lock()
+	defer _reloadLock.Unlock()
+
+	old := Get()
+	mu.RLock()
+	if _debugViaFlag {
+		c.Debug = true
+	}
+	mu.RUnlock()
+
+	res := &ReloadResult{Applied: []string{}, RestartRequired: []string{}, Failed: []string{}}
+	var changed []string
+	walk("", reflect.ValueOf(old).Elem(), reflect.ValueOf(c).Elem(), func(key string, a, b reflect.Value) {
+		if matches(key, restartRequired) {
+			res.RestartRequired = append(res.RestartRequired, key)
+			b.Set(a)
+			return
+		}
+		changed = append(changed, key)
+	})
+	if len(changed) == 0 {
+		if len(res.RestartRequired) > 0 {
+			log.WithField("restart_required", res.RestartRequired).Warn("config: reloaded configuration contains changes that require a restart")
+		}
+		return res
+	}
+
+	Set(c)
+	failed := make(map[string]bool)
+	for _, h := range _hooks {
+		var keys []string
+		for _, key := range changed {
+			if matches(key, h.settings) {
+				keys = append(keys, key)
+			}
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		if err := h.fn(c); err != nil {
+			log.WithFields(log.Fields{"subsystem": h.name, "error": errors.WithStack(err)}).Error("config: failed to reconfigure subsystem after reload")
+			for _, key := range keys {
+				failed[key] = true
+			}
+		}
+	}
+	for _, key := range changed {
+		if failed[key] {
+			res.Failed = append(res.Failed, key)
+		} else {
+			res.Applied = append(res.Applied, key)
+		}
+	}
+	log.WithFields(log.Fields{
+		"applied":          res.Applied,
+		"restart_required": res.RestartRequired,
+		"failed":           res.Failed,
+	}).Info("config: reloaded configuration")
+	return res
+}
+
+// walk calls fn for every exported field of the structs that differs between them,
+// descending into nested structs.
+func walk(prefix string, a, b reflect.Value, fn func(key string, a, b reflect.Value)) {
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		fa, fb := a.Field(i), b.Field(i)
+		if f.Type.Kind() == reflect.Struct {
+			walk(name, fa, fb, fn)
+			continue
+		}
+		if !reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			fn(name, fa, fb)
+		}
+	}
+}
+
+// matches returns true if the key is one of the settings, or below one of them.
+func matches(key string, settings []string) bool {
+	for _, s := range settings {
+		if key == s || strings.HasPrefix(key, s+".") {
+			return true
+		}
+	}
+	return false
+}
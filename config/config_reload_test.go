@@ -0,0 +1,67 @@
+package config
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestReload(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Reload", func() {
+		var calls int
+
+		g.BeforeEach(func() {
+			c, err := NewAtPath("")
+			if err != nil {
+				panic(err)
+			}
+			c.AuthenticationToken = "abc"
+			Set(c)
+			calls = 0
+			_hooks = nil
+			OnReload("test", func(_ *Configuration) error {
+				calls++
+				return nil
+			}, "throttles")
+		})
+
+		g.It("does nothing if no settings were changed", func() {
+			res := Reload(Get())
+			g.Assert(res.Applied).Equal([]string{})
+			g.Assert(res.RestartRequired).Equal([]string{})
+			g.Assert(calls).Equal(0)
+		})
+
+		g.It("applies changed settings and calls the hooks using them", func() {
+			c := Get()
+			c.AppName = "Test"
+			c.Throttles.Lines = 10
+			res := Reload(c)
+			g.Assert(res.Applied).Equal([]string{"app_name", "throttles.lines"})
+			g.Assert(calls).Equal(1)
+			g.Assert(Get().Throttles.Lines).Equal(uint64(10))
+		})
+
+		g.It("applies a new token immediately", func() {
+			c := Get()
+			c.AuthenticationToken = "def"
+			res := Reload(c)
+			g.Assert(res.Applied).Equal([]string{"token"})
+			g.Assert(Get().AuthenticationToken).Equal("def")
+		})
+
+		g.It("keeps the running value of settings that require a restart", func() {
+			c := Get()
+			c.Api.Port = 9090
+			c.System.Sftp.Port = 2023
+			c.System.Sftp.ReadOnly = true
+			res := Reload(c)
+			g.Assert(res.Applied).Equal([]string{"system.sftp.read_only"})
+			g.Assert(res.RestartRequired).Equal([]string{"api.port", "system.sftp.bind_port"})
+			g.Assert(Get().Api.Port).Equal(8080)
+			g.Assert(Get().System.Sftp.ReadOnly).IsTrue()
+		})
+	})
+}
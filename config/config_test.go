@@ -0,0 +1,67 @@
+package config
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestLogrotateIncludes(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("logrotateIncludes", func() {
+		const accessLog = "/var/log/pterodactyl/access.log"
+
+		for _, tc := range []struct {
+			name     string
+			conf     string
+			expected bool
+		}{
+			{
+				name:     "matches the file written by wings",
+				conf:     "/var/log/pterodactyl/wings.log /var/log/pterodactyl/access.log {\n    size 10M\n}\n",
+				expected: true,
+			},
+			{
+				name:     "matches a glob pattern",
+				conf:     "/var/log/pterodactyl/*.log {\n    weekly\n}\n",
+				expected: true,
+			},
+			{
+				name:     "matches a path listed on its own line",
+				conf:     "/var/log/pterodactyl/wings.log\n\"/var/log/pterodactyl/access.log\"\n{\n    weekly\n}\n",
+				expected: true,
+			},
+			{
+				name:     "matches a single line block",
+				conf:     "/var/log/pterodactyl/wings.log { weekly }\n/var/log/pterodactyl/access.log { daily }\n",
+				expected: true,
+			},
+			{
+				name:     "does not match other files",
+				conf:     "/var/log/pterodactyl/wings.log {\n    size 10M\n}\n",
+				expected: false,
+			},
+			{
+				name:     "does not match a pattern for other files",
+				conf:     "/var/log/pterodactyl/wings*.log {\n    size 10M\n}\n",
+				expected: false,
+			},
+			{
+				name:     "does not match comments",
+				conf:     "# /var/log/pterodactyl/access.log\n/var/log/pterodactyl/wings.log {\n    size 10M\n}\n",
+				expected: false,
+			},
+			{
+				name:     "does not match paths in scripts",
+				conf:     "/var/log/pterodactyl/wings.log {\n    postrotate\n        echo ${x} >/dev/null\n        touch /var/log/pterodactyl/access.log\n    endscript\n}\n",
+				expected: false,
+			},
+		} {
+			tc := tc
+			g.It(tc.name, func() {
+				g.Assert(logrotateIncludes([]byte(tc.conf), accessLog)).Equal(tc.expected)
+			})
+		}
+	})
+}
@@ -2,7 +2,9 @@ package environment
 
 import (
 	"fmt"
+	"net"
 	"strconv"
+	"strings"
 
 	"github.com/docker/go-connections/nat"
 
@@ -26,10 +28,46 @@ type Allocations struct {
 	} `json:"default"`
 
 	// Mappings contains all the ports that should be assigned to a given server
-	// attached to the IP they correspond to.
+	// attached to the IP they correspond to. IPv6 addresses may optionally be
+	// wrapped in square brackets.
 	Mappings map[string][]int `json:"mappings"`
 }
 
+// NormalizeIP returns the IP address without any square brackets surrounding an
+// IPv6 address, which is the format expected by Docker.
+func NormalizeIP(ip string) string {
+	return strings.TrimSuffix(strings.TrimPrefix(ip, "["), "]")
+}
+
+// IsIPv6 returns true if the IP address is an IPv6 address.
+func IsIPv6(ip string) bool {
+	parsed := net.ParseIP(NormalizeIP(ip))
+	return parsed != nil && parsed.To4() == nil
+}
+
+// DefaultIP returns the IP address of the default allocation for the server.
+func (a *Allocations) DefaultIP() string {
+	return NormalizeIP(a.DefaultMapping.Ip)
+}
+
+// DockerInterface returns the address containers should use in place of the given
+// loopback address so that they operate on a local address while still being
+// accessible by other containers. Any other address is returned unchanged.
+func DockerInterface(ip string) string {
+	nw := config.Get().Docker.Network
+	switch NormalizeIP(ip) {
+	case "127.0.0.1":
+		return nw.Interface
+	case "::1":
+		// When using the host network the loopback address is already correct.
+		if nw.Driver == "host" {
+			return "::1"
+		}
+		return nw.Interfaces.V6.Gateway
+	}
+	return NormalizeIP(ip)
+}
+
 // Converts the server allocation mappings into a format that can be understood by Docker. While
 // we do strive to support multiple environments, using Docker's standardized format for the
 // bindings certainly makes life a little easier for managing things.
@@ -39,6 +77,7 @@ func (a *Allocations) Bindings() nat.PortMap {
 	out := nat.PortMap{}
 
 	for ip, ports := range a.Mappings {
+		ip = NormalizeIP(ip)
 		for _, port := range ports {
 			// Skip over invalid ports.
 			if port < 1 || port > 65535 {
@@ -62,31 +101,31 @@ func (a *Allocations) Bindings() nat.PortMap {
 }
 
 // Returns the bindings for the server in a way that is supported correctly by Docker. This replaces
-// any reference to 127.0.0.1 with the IP of the pterodactyl0 network interface which will allow the
-// server to operate on a local address while still being accessible by other containers.
+// any reference to 127.0.0.1 or ::1 with the IP of the pterodactyl0 network interface which will allow
+// the server to operate on a local address while still being accessible by other containers. If
+// dual-stack bindings are enabled, any binding to 0.0.0.0 is also bound to the same port on ::.
 func (a *Allocations) DockerBindings() nat.PortMap {
-	iface := config.Get().Docker.Network.Interface
+	nw := config.Get().Docker.Network
 
 	out := a.Bindings()
-	// Loop over all the bindings for this container, and convert any that reference 127.0.0.1
-	// to use the pterodactyl0 network interface IP, as that is the true local for what people are
-	// trying to do when creating servers.
 	for p, binds := range out {
-		for i, alloc := range binds {
-			if alloc.HostIP != "127.0.0.1" {
-				continue
-			}
-
-			// If using ISPN just delete the local allocation from the server.
-			if config.Get().Docker.Network.ISPN {
-				out[p] = append(out[p][:i], out[p][i+1:]...)
-			} else {
-				out[p][i] = nat.PortBinding{
-					HostIP:   iface,
-					HostPort: alloc.HostPort,
+		converted := make([]nat.PortBinding, 0, len(binds))
+		for _, alloc := range binds {
+			switch alloc.HostIP {
+			case "127.0.0.1", "::1":
+				// If using ISPN just delete the local allocation from the server.
+				if nw.ISPN {
+					continue
+				}
+				alloc.HostIP = DockerInterface(alloc.HostIP)
+			case "0.0.0.0":
+				if nw.DualStack {
+					converted = append(converted, nat.PortBinding{HostIP: "::", HostPort: alloc.HostPort})
 				}
 			}
+			converted = append(converted, alloc)
 		}
+		out[p] = converted
 	}
 
 	return out
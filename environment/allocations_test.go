@@ -0,0 +1,95 @@
+package environment
+
+import (
+	"testing"
+
+	"github.com/docker/go-connections/nat"
+	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestNormalizeIP(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("NormalizeIP", func() {
+		g.It("removes square brackets from IPv6 addresses", func() {
+			g.Assert(NormalizeIP("[2001:db8::1]")).Equal("2001:db8::1")
+			g.Assert(NormalizeIP("[::]")).Equal("::")
+		})
+
+		g.It("does not modify other addresses", func() {
+			g.Assert(NormalizeIP("2001:db8::1")).Equal("2001:db8::1")
+			g.Assert(NormalizeIP("192.168.1.1")).Equal("192.168.1.1")
+			g.Assert(NormalizeIP("")).Equal("")
+		})
+	})
+}
+
+func TestIsIPv6(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("IsIPv6", func() {
+		g.It("returns true for IPv6 addresses", func() {
+			g.Assert(IsIPv6("2001:db8::1")).IsTrue()
+			g.Assert(IsIPv6("[2001:db8::1]")).IsTrue()
+			g.Assert(IsIPv6("::")).IsTrue()
+		})
+
+		g.It("returns false for IPv4 addresses", func() {
+			g.Assert(IsIPv6("192.168.1.1")).IsFalse()
+			g.Assert(IsIPv6("0.0.0.0")).IsFalse()
+			g.Assert(IsIPv6("::ffff:192.168.1.1")).IsFalse()
+		})
+
+		g.It("returns false for invalid addresses", func() {
+			g.Assert(IsIPv6("")).IsFalse()
+			g.Assert(IsIPv6("example.com")).IsFalse()
+		})
+	})
+}
+
+func TestAllocations_DockerBindings(t *testing.T) {
+	g := Goblin(t)
+
+	setDualStack := func(enabled bool) {
+		c, err := config.NewAtPath("")
+		if err != nil {
+			panic(err)
+		}
+		c.AuthenticationToken = "abc"
+		c.Docker.Network.DualStack = enabled
+		config.Set(c)
+	}
+
+	g.Describe("Allocations#DockerBindings", func() {
+		a := Allocations{Mappings: map[string][]int{
+			"0.0.0.0":       {25565},
+			"[2001:db8::1]": {25566},
+			"192.168.1.1":   {25567},
+		}}
+
+		g.It("binds IPv6 addresses without square brackets", func() {
+			setDualStack(false)
+			b := a.DockerBindings()
+			g.Assert(b[nat.Port("25566/tcp")]).Equal([]nat.PortBinding{{HostIP: "2001:db8::1", HostPort: "25566"}})
+			g.Assert(b[nat.Port("25566/udp")]).Equal([]nat.PortBinding{{HostIP: "2001:db8::1", HostPort: "25566"}})
+		})
+
+		g.It("only binds 0.0.0.0 on IPv4 without dual-stack", func() {
+			setDualStack(false)
+			b := a.DockerBindings()
+			g.Assert(b[nat.Port("25565/tcp")]).Equal([]nat.PortBinding{{HostIP: "0.0.0.0", HostPort: "25565"}})
+		})
+
+		g.It("also binds 0.0.0.0 on :: with dual-stack", func() {
+			setDualStack(true)
+			b := a.DockerBindings()
+			g.Assert(b[nat.Port("25565/tcp")]).Equal([]nat.PortBinding{
+				{HostIP: "::", HostPort: "25565"},
+				{HostIP: "0.0.0.0", HostPort: "25565"},
+			})
+			g.Assert(b[nat.Port("25567/udp")]).Equal([]nat.PortBinding{{HostIP: "192.168.1.1", HostPort: "25567"}})
+		})
+	})
+}
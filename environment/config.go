@@ -2,6 +2,8 @@ package environment
 
 import (
 	"sync"
+
+	"github.com/pterodactyl/wings/config"
 )
 
 type Settings struct {
@@ -9,6 +11,8 @@ type Settings struct {
 	Allocations Allocations
 	Limits      Limits
 	Labels      map[string]string
+	Ulimits     []config.Ulimit
+	Devices     []config.DeviceMapping
 }
 
 // Defines the actual configuration struct for the environment with all of the settings
@@ -77,6 +81,22 @@ func (c *Configuration) Labels() map[string]string {
 	return c.settings.Labels
 }
 
+// Ulimits returns the ulimits requested for this instance.
+func (c *Configuration) Ulimits() []config.Ulimit {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.settings.Ulimits
+}
+
+// Devices returns the host devices passed through to this instance.
+func (c *Configuration) Devices() []config.DeviceMapping {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.settings.Devices
+}
+
 // Returns the environment variables associated with this instance.
 func (c *Configuration) EnvironmentVariables() []string {
 	c.mu.RLock()
@@ -11,7 +11,6 @@ import (
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
-	"github.com/buger/jsonparser"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/mount"
@@ -71,7 +70,10 @@ func (e *Environment) Attach(ctx context.Context) error {
 		defer cancel()
 		defer e.stream.Close()
 		defer func() {
-			e.SetState(environment.ProcessOfflineState)
+			// The container is still running if the stream was closed by detaching.
+			if !e.detached.Load() {
+				e.SetState(environment.ProcessOfflineState)
+			}
 			e.SetStream(nil)
 		}()
 
@@ -89,7 +91,7 @@ func (e *Environment) Attach(ctx context.Context) error {
 			e.logCallbackMx.Lock()
 			defer e.logCallbackMx.Unlock()
 			e.logCallback(v)
-		}); err != nil && err != io.EOF {
+		}); err != nil && err != io.EOF && !e.detached.Load() {
 			log.WithField("error", err).WithField("container_id", e.Id).Warn("error processing scanner line in console output")
 			return
 		}
@@ -155,10 +157,10 @@ func (e *Environment) Create() error {
 	a := e.Configuration.Allocations()
 	evs := e.Configuration.EnvironmentVariables()
 	for i, v := range evs {
-		// Convert 127.0.0.1 to the pterodactyl0 network interface if the environment is Docker
-		// so that the server operates as expected.
-		if v == "SERVER_IP=127.0.0.1" {
-			evs[i] = "SERVER_IP=" + cfg.Docker.Network.Interface
+		// Convert 127.0.0.1 and ::1 to the pterodactyl0 network interface if the environment
+		// is Docker so that the server operates as expected.
+		if ip, ok := strings.CutPrefix(v, "SERVER_IP="); ok {
+			evs[i] = "SERVER_IP=" + environment.DockerInterface(ip)
 		}
 	}
 
@@ -193,33 +195,30 @@ func (e *Environment) Create() error {
 		conf.User = strconv.Itoa(cfg.System.User.Uid) + ":" + strconv.Itoa(cfg.System.User.Gid)
 	}
 
+	// Hold the network lock until the container has been attached to all of its
+	// networks so that they cannot be removed as unused in the meantime.
+	networkMu.RLock()
+	defer networkMu.RUnlock()
+
 	networkMode := container.NetworkMode(cfg.Docker.Network.Mode)
+	if name := e.isolatedNetworkName(); name != "" {
+		if err := e.ensureNetwork(ctx, name); err != nil {
+			return err
+		}
+		networkMode = container.NetworkMode(name)
+	}
 	if a.ForceOutgoingIP {
+		// The forced outgoing IP network replaces the isolated network, leaving the
+		// server on a network shared with every other server using the same IP.
+		if iso := cfg.Docker.Network.Isolation; iso != "" && iso != IsolationNone {
+			e.log().WithField("isolation", iso).Warn("environment/docker: network isolation is not applied to servers forcing their outgoing IP address")
+		}
 		e.log().Debug("environment/docker: forcing outgoing IP address")
-		networkName := "ip-" + strings.ReplaceAll(strings.ReplaceAll(a.DefaultMapping.Ip, ".", "-"), ":", "-")
-		networkMode = container.NetworkMode(networkName)
-
-		if _, err := e.client.NetworkInspect(ctx, networkName, types.NetworkInspectOptions{}); err != nil {
-			if !client.IsErrNotFound(err) {
-				return err
-			}
-
-			if _, err := e.client.NetworkCreate(ctx, networkName, types.NetworkCreate{
-				Driver:     "bridge",
-				EnableIPv6: false,
-				Internal:   false,
-				Attachable: false,
-				Ingress:    false,
-				ConfigOnly: false,
-				Options: map[string]string{
-					"encryption": "false",
-					"com.docker.network.bridge.default_bridge": "false",
-					"com.docker.network.host_ipv4":             a.DefaultMapping.Ip,
-				},
-			}); err != nil {
-				return err
-			}
+		networkName, err := e.ensureOutgoingIPNetwork(ctx, a.DefaultIP())
+		if err != nil {
+			return err
 		}
+		networkMode = container.NetworkMode(networkName)
 	}
 
 	hostConf := &container.HostConfig{
@@ -231,6 +230,7 @@ func (e *Environment) Create() error {
 
 		// Configure the /tmp folder mapping in containers. This is necessary for some
 		// games that need to make use of it for downloads and other installation processes.
+		// The security profile for the server may add further mounts or replace this one.
 		Tmpfs: map[string]string{
 			"/tmp": "rw,exec,nosuid,size=" + strconv.Itoa(int(cfg.Docker.TmpfsSize)) + "M",
 		},
@@ -247,20 +247,44 @@ func (e *Environment) Create() error {
 		// about anything else in it.
 		LogConfig: cfg.Docker.ContainerLogConfig(),
 
-		SecurityOpt:    []string{"no-new-privileges"},
-		ReadonlyRootfs: true,
-		CapDrop: []string{
-			"setpcap", "mknod", "audit_write", "net_raw", "dac_override",
-			"fowner", "fsetid", "net_bind_service", "sys_chroot", "setfcap",
-		},
 		NetworkMode: networkMode,
 		UsernsMode:  container.UsernsMode(cfg.Docker.UsernsMode),
 	}
 
+	// Apply the capabilities, rootfs writability and other security options from
+	// the security profile selected for the server.
+	profile, err := securityProfile(e.meta.SecurityProfile)
+	if err != nil {
+		return err
+	}
+	if err := applySecurityProfile(hostConf, profile); err != nil {
+		return err
+	}
+
+	// Ulimits defined by the security profile take priority over those requested for
+	// the server, which in turn take priority over the defaults for the node.
+	hostConf.Ulimits = ContainerUlimits(cfg.Docker.Ulimits, e.Configuration.Ulimits(), profile.Ulimits)
+	hostConf.Devices = ContainerDevices(cfg.Docker.Devices, e.Configuration.Devices())
+
 	if _, err := e.client.ContainerCreate(ctx, conf, hostConf, nil, nil, e.Id); err != nil {
 		return errors.Wrap(err, "environment/docker: failed to create container")
 	}
 
+	// Linked networks are only available when the container is using a bridge network
+	// of its own, and are attached after creation since the container can only be
+	// created with a single network.
+	if !networkMode.IsHost() {
+		if err := e.connectLinkNetworks(ctx); err != nil {
+			// Remove the container so that the next attempt to create it does not treat
+			// it as existing without being attached to its linked networks.
+			rerr := e.client.ContainerRemove(ctx, e.Id, types.ContainerRemoveOptions{RemoveVolumes: true, Force: true})
+			if rerr != nil && !client.IsErrNotFound(rerr) {
+				e.log().WithField("error", rerr).Warn("environment/docker: failed to remove container after failing to connect networks")
+			}
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -270,6 +294,7 @@ func (e *Environment) Destroy() error {
 	// We set it to stopping than offline to prevent crash detection from being triggered.
 	e.SetState(environment.ProcessStoppingState)
 
+	networks := e.serverNetworks(context.Background())
 	err := e.client.ContainerRemove(context.Background(), e.Id, types.ContainerRemoveOptions{
 		RemoveVolumes: true,
 		RemoveLinks:   false,
@@ -278,6 +303,10 @@ func (e *Environment) Destroy() error {
 
 	e.SetState(environment.ProcessOfflineState)
 
+	if err == nil || client.IsErrNotFound(err) {
+		e.removeUnusedNetworks(context.Background(), networks)
+	}
+
 	// Don't trigger a destroy failure if we try to delete a container that does not
 	// exist on the system. We're just a step ahead of ourselves in that case.
 	//
@@ -358,82 +387,13 @@ func (e *Environment) ensureImageExists(image string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute*15)
 	defer cancel()
 
-	// Get a registry auth configuration from the config.
-	var registryAuth *config.RegistryConfiguration
-	for registry, c := range config.Get().Docker.Registries {
-		if !strings.HasPrefix(image, registry) {
-			continue
-		}
-
-		log.WithField("registry", registry).Debug("using authentication for registry")
-		registryAuth = &c
-		break
-	}
-
-	// Get the ImagePullOptions.
-	imagePullOptions := types.ImagePullOptions{All: false}
-	if registryAuth != nil {
-		b64, err := registryAuth.Base64()
-		if err != nil {
-			log.WithError(err).Error("failed to get registry auth credentials")
-		}
-
-		// b64 is a string so if there is an error it will just be empty, not nil.
-		imagePullOptions.RegistryAuth = b64
-	}
-
-	out, err := e.client.ImagePull(ctx, image, imagePullOptions)
-	if err != nil {
-		images, ierr := e.client.ImageList(ctx, types.ImageListOptions{})
-		if ierr != nil {
-			// Well damn, something has gone really wrong here, just go ahead and abort there
-			// isn't much anything we can do to try and self-recover from this.
-			return errors.Wrap(ierr, "environment/docker: failed to list images")
-		}
-
-		for _, img := range images {
-			for _, t := range img.RepoTags {
-				if t != image {
-					continue
-				}
-
-				log.WithFields(log.Fields{
-					"image":        image,
-					"container_id": e.Id,
-					"err":          err.Error(),
-				}).Warn("unable to pull requested image from remote source, however the image exists locally")
-
-				// Okay, we found a matching container image, in that case just go ahead and return
-				// from this function, since there is nothing else we need to do here.
-				return nil
-			}
-		}
-
-		return errors.Wrapf(err, "environment/docker: failed to pull \"%s\" image for server", image)
-	}
-	defer out.Close()
-
-	log.WithField("image", image).Debug("pulling docker image... this could take a bit of time")
-
-	// I'm not sure what the best approach here is, but this will block execution until the image
-	// is done being pulled, which is what we need.
-	scanner := bufio.NewScanner(out)
-
-	for scanner.Scan() {
-		b := scanner.Bytes()
-		status, _ := jsonparser.GetString(b, "status")
-		progress, _ := jsonparser.GetString(b, "progress")
-
-		e.Events().Publish(environment.DockerImagePullStatus, status+" "+progress)
-	}
-
-	if err := scanner.Err(); err != nil {
-		return err
-	}
-
-	log.WithField("image", image).Debug("completed docker image pull")
+	e.mu.RLock()
+	creds := e.meta.RegistryAuth
+	e.mu.RUnlock()
 
-	return nil
+	return PullImage(ctx, e.client, image, creds, func(p PullProgress) {
+		e.Events().Publish(environment.DockerImagePullStatus, p.Status+" "+p.Progress)
+	})
 }
 
 func (e *Environment) convertMounts() []mount.Mount {
@@ -0,0 +1,57 @@
+package docker
+
+import (
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-units"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// ContainerUlimits merges the lists of ulimits into the format expected by the
+// Docker API. If a ulimit is defined more than once the last definition is used.
+func ContainerUlimits(lists ...[]config.Ulimit) []*units.Ulimit {
+	var out []*units.Ulimit
+	index := make(map[string]int)
+	for _, list := range lists {
+		for _, u := range list {
+			ul := &units.Ulimit{Name: u.Name, Soft: u.Soft, Hard: u.Hard}
+			if i, ok := index[u.Name]; ok {
+				out[i] = ul
+				continue
+			}
+			index[u.Name] = len(out)
+			out = append(out, ul)
+		}
+	}
+	return out
+}
+
+// ContainerDevices merges the lists of devices into the format expected by the
+// Docker API. If a device is mapped to the same path more than once the last
+// mapping is used.
+func ContainerDevices(lists ...[]config.DeviceMapping) []container.DeviceMapping {
+	var out []container.DeviceMapping
+	index := make(map[string]int)
+	for _, list := range lists {
+		for _, d := range list {
+			dm := container.DeviceMapping{
+				PathOnHost:        d.Source,
+				PathInContainer:   d.Target,
+				CgroupPermissions: d.Permissions,
+			}
+			if dm.PathInContainer == "" {
+				dm.PathInContainer = d.Source
+			}
+			if dm.CgroupPermissions == "" {
+				dm.CgroupPermissions = "rwm"
+			}
+			if i, ok := index[dm.PathInContainer]; ok {
+				out[i] = dm
+				continue
+			}
+			index[dm.PathInContainer] = len(out)
+			out = append(out, dm)
+		}
+	}
+	return out
+}
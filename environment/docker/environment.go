@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
@@ -18,8 +19,11 @@ import (
 )
 
 type Metadata struct {
-	Image string
-	Stop  remote.ProcessStopConfiguration
+	Image           string
+	RegistryAuth    *remote.RegistryCredentials
+	SecurityProfile string
+	Network         NetworkSettings
+	Stop            remote.ProcessStopConfiguration
 }
 
 // Ensure that the Docker environment is always implementing all the methods
@@ -55,6 +59,10 @@ type Environment struct {
 
 	// Tracks the environment state.
 	st *system.AtomicString
+
+	// Set once the environment has been detached from the container, after which
+	// the container is managed by another process.
+	detached atomic.Bool
 }
 
 // New creates a new base Docker environment. The ID passed through will be the
@@ -80,7 +88,7 @@ func New(id string, m *Metadata, c *environment.Configuration) (*Environment, er
 }
 
 func (e *Environment) log() *log.Entry {
-	return log.WithField("environment", e.Type()).WithField("container_id", e.Id)
+	return log.WithField("subsystem", "environment").WithField("environment", e.Type()).WithField("container_id", e.Id)
 }
 
 func (e *Environment) Type() string {
@@ -104,6 +112,18 @@ func (e *Environment) IsAttached() bool {
 	return e.stream != nil
 }
 
+// Detach closes the stream attached to the container, which stops following its
+// console output and resource usage. The state of the environment is left as is
+// since the container keeps running.
+func (e *Environment) Detach() {
+	e.detached.Store(true)
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.stream != nil {
+		e.stream.Close()
+	}
+}
+
 // Events returns an event bus for the environment.
 func (e *Environment) Events() *events.Bus {
 	return e.emitter
@@ -189,6 +209,32 @@ func (e *Environment) SetImage(i string) {
 	e.meta.Image = i
 }
 
+// SetRegistryAuth sets the credentials used to pull the image for the environment.
+func (e *Environment) SetRegistryAuth(c *remote.RegistryCredentials) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.meta.RegistryAuth = c
+}
+
+// SetSecurityProfile sets the name of the security profile applied to the
+// container the next time it is created.
+func (e *Environment) SetSecurityProfile(p string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.meta.SecurityProfile = p
+}
+
+// SetNetworkSettings sets the networks the container is attached to the next time
+// it is created.
+func (e *Environment) SetNetworkSettings(n NetworkSettings) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.meta.Network = n
+}
+
 func (e *Environment) State() string {
 	return e.st.Load()
 }
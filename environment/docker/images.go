@@ -0,0 +1,171 @@
+package docker
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+	"github.com/goccy/go-json"
+
+	"github.com/pterodactyl/wings/remote"
+)
+
+// imagesLastUsed tracks the last time each image was pulled, or used to create a
+// container, by this process. This allows images that are only used briefly, such
+// as those used for installations, to be protected from being pruned.
+var imagesLastUsed sync.Map
+
+// PullProgress is a single status message returned by Docker while an image is
+// being pulled.
+type PullProgress struct {
+	ID             string `json:"id"`
+	Status         string `json:"status"`
+	Progress       string `json:"progress"`
+	Error          string `json:"error"`
+	ProgressDetail struct {
+		Current int64 `json:"current"`
+		Total   int64 `json:"total"`
+	} `json:"progressDetail"`
+}
+
+// MarkImageUsed records that the image was used at the current time.
+func MarkImageUsed(image string) {
+	imagesLastUsed.Store(NormalizeImage(image), time.Now())
+}
+
+// SetImageLastUsed records that the image was last used at the given time, unless
+// it has been used more recently. This is used to restore the times the images
+// were last used before Wings was restarted.
+func SetImageLastUsed(image string, t time.Time) {
+	image = NormalizeImage(image)
+	for {
+		v, loaded := imagesLastUsed.LoadOrStore(image, t)
+		if !loaded || !t.After(v.(time.Time)) || imagesLastUsed.CompareAndSwap(image, v, t) {
+			return
+		}
+	}
+}
+
+// ImagesLastUsed returns the last time each image was used, keyed by the
+// normalized image reference.
+func ImagesLastUsed() map[string]time.Time {
+	out := make(map[string]time.Time)
+	imagesLastUsed.Range(func(k, v any) bool {
+		out[k.(string)] = v.(time.Time)
+		return true
+	})
+	return out
+}
+
+// ImageLastUsed returns the last time the image was used by this process.
+func ImageLastUsed(image string) (time.Time, bool) {
+	v, ok := imagesLastUsed.Load(NormalizeImage(image))
+	if !ok {
+		return time.Time{}, false
+	}
+	return v.(time.Time), true
+}
+
+// NormalizeImage returns the image reference with the "latest" tag applied if
+// no tag or digest was provided, matching the format of image tags returned by
+// Docker.
+func NormalizeImage(image string) string {
+	if strings.Contains(image, "@") {
+		return image
+	}
+	if i := strings.LastIndex(image, ":"); i == -1 || strings.Contains(image[i:], "/") {
+		return image + ":latest"
+	}
+	return image
+}
+
+// PullImage pulls the image using the provided registry credentials, or those
+// from the config if there are none, calling fn with each progress update
+// returned by Docker. If the image cannot be pulled but already exists locally,
+// the error is logged and nil is returned.
+func PullImage(ctx context.Context, cli client.APIClient, image string, creds *remote.RegistryCredentials, fn func(PullProgress)) error {
+	MarkImageUsed(image)
+
+	out, err := imagePull(ctx, cli, image, creds)
+	if err != nil {
+		images, ierr := cli.ImageList(ctx, types.ImageListOptions{})
+		if ierr != nil {
+			// Well damn, something has gone really wrong here, just go ahead and abort there
+			// isn't much anything we can do to try and self-recover from this.
+			return errors.Wrap(ierr, "environment/docker: failed to list images")
+		}
+
+		for _, img := range images {
+			for _, t := range img.RepoTags {
+				if t != image {
+					continue
+				}
+
+				log.WithFields(log.Fields{
+					"image": image,
+					"err":   err.Error(),
+				}).Warn("unable to pull requested image from remote source, however the image exists locally")
+
+				// Okay, we found a matching container image, in that case just go ahead and return
+				// from this function, since there is nothing else we need to do here.
+				return nil
+			}
+		}
+
+		return errors.Wrapf(err, "environment/docker: failed to pull \"%s\" image", image)
+	}
+	defer out.Close()
+
+	log.WithField("image", image).Debug("pulling docker image... this could take a bit of time")
+
+	// This will block execution until the image is done being pulled.
+	scanner := bufio.NewScanner(out)
+	for scanner.Scan() {
+		var p PullProgress
+		if err := json.Unmarshal(scanner.Bytes(), &p); err != nil {
+			continue
+		}
+		if p.Error != "" {
+			return errors.Errorf("environment/docker: failed to pull \"%s\" image: %s", image, p.Error)
+		}
+		if fn != nil {
+			fn(p)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	log.WithField("image", image).Debug("completed docker image pull")
+	MarkImageUsed(image)
+
+	return nil
+}
+
+// imagePull starts pulling the image. If the credentials for the registry come
+// from a credential helper and the pull fails, the credentials are refreshed
+// and the pull is attempted once more in case the cached token has expired.
+func imagePull(ctx context.Context, cli client.APIClient, image string, creds *remote.RegistryCredentials) (io.ReadCloser, error) {
+	auth, err := RegistryAuth(ctx, image, creds)
+	if err != nil {
+		log.WithField("image", image).WithField("error", err).Error("failed to get registry auth credentials")
+	}
+	out, err := cli.ImagePull(ctx, image, types.ImagePullOptions{All: false, RegistryAuth: auth})
+	if err == nil || !creds.IsEmpty() {
+		return out, err
+	}
+	if _, c, ok := nodeRegistry(image); ok && c.CredentialHelper != "" {
+		forgetCredentialHelper(c.CredentialHelper, registryHost(image))
+		if auth, aerr := RegistryAuth(ctx, image, nil); aerr == nil {
+			return cli.ImagePull(ctx, image, types.ImagePullOptions{All: false, RegistryAuth: auth})
+		}
+	}
+	return nil, err
+}
@@ -0,0 +1,338 @@
+package docker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"emperror.dev/errors"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/versions"
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/environment"
+)
+
+// minimumHostIPv6APIVersion is the API version of Docker 27, the first release
+// that applies the host_ipv6 option of bridge networks.
+const minimumHostIPv6APIVersion = "1.46"
+
+// ErrHostIPv6Unsupported is returned when forcing the outgoing IPv6 address of a
+// server on a version of Docker that does not support it.
+var ErrHostIPv6Unsupported = errors.Sentinel("environment/docker: docker 27 or newer is required to force an outgoing IPv6 address")
+
+// ensureOutgoingIPNetwork creates the bridge network used to force outgoing
+// traffic from the container to use the given IP address if it does not already
+// exist, and returns the name of the network.
+func (e *Environment) ensureOutgoingIPNetwork(ctx context.Context, ip string) (string, error) {
+	name := "ip-" + strings.ReplaceAll(strings.ReplaceAll(ip, ".", "-"), ":", "-")
+	if _, err := e.client.NetworkInspect(ctx, name, types.NetworkInspectOptions{}); err == nil {
+		return name, nil
+	} else if !client.IsErrNotFound(err) {
+		return "", err
+	}
+
+	opts := types.NetworkCreate{
+		Driver:     "bridge",
+		EnableIPv6: false,
+		Internal:   false,
+		Attachable: false,
+		Ingress:    false,
+		ConfigOnly: false,
+		Options: map[string]string{
+			"encryption": "false",
+			"com.docker.network.bridge.default_bridge": "false",
+			"com.docker.network.host_ipv4":             ip,
+		},
+	}
+	if environment.IsIPv6(ip) {
+		// Older versions of Docker silently ignore the host_ipv6 option, which would
+		// leave the container using the default outgoing IP address of the host.
+		v, err := e.client.ServerVersion(ctx)
+		if err != nil {
+			return "", errors.Wrap(err, "environment/docker: failed to get docker version")
+		}
+		if versions.LessThan(v.APIVersion, minimumHostIPv6APIVersion) {
+			return "", errors.Wrapf(ErrHostIPv6Unsupported, "environment/docker: docker %s does not support forcing an outgoing IPv6 address", v.Version)
+		}
+		// Docker requires an IPv6 subnet to be assigned to the network, and there is
+		// no default pool for these, so a unique local subnet is derived from the IP.
+		delete(opts.Options, "com.docker.network.host_ipv4")
+		opts.Options["com.docker.network.host_ipv6"] = ip
+		opts.EnableIPv6 = true
+		opts.IPAM = &network.IPAM{
+			Config: []network.IPAMConfig{{Subnet: uniqueLocalSubnet(ip)}},
+		}
+	}
+
+	if _, err := e.client.NetworkCreate(ctx, name, opts); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// uniqueLocalSubnet returns a unique local /64 IPv6 subnet derived from the key,
+// so that each network it is used for receives its own subnet.
+func uniqueLocalSubnet(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return fmt.Sprintf("fd%02x:%02x%02x:%02x%02x::/64", h[0], h[1], h[2], h[3], h[4])
+}
+
+// The network isolation modes that can be configured for the node.
+const (
+	IsolationNone   = "none"
+	IsolationServer = "server"
+	IsolationOwner  = "owner"
+)
+
+// NetworkSettings defines the networks a server container is attached to in
+// addition to its primary network.
+type NetworkSettings struct {
+	// Owner is the UUID of the user that owns the server, used to name the shared
+	// network when isolating servers by owner.
+	Owner string
+	// Links are the identifiers of the private networks the Panel has linked the
+	// server into, such as a proxy and its backend servers.
+	Links []string
+}
+
+// networkName returns a Docker network name using the prefix and identifier,
+// removing any characters that are not valid in a network name.
+func networkName(prefix string, id string) string {
+	var b strings.Builder
+	b.WriteString(prefix)
+	for _, r := range id {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isolatedNetworkName returns the name of the network the server container should
+// use as its primary network based on the isolation mode configured for the node,
+// or an empty string if the default network should be used. Servers forcing their
+// outgoing IP address are never isolated, since the container can only use one of
+// these networks as its primary network.
+func (e *Environment) isolatedNetworkName() string {
+	nw := config.Get().Docker.Network
+	if nw.Driver == "host" || nw.ISPN || e.Configuration.Allocations().ForceOutgoingIP {
+		return ""
+	}
+	switch nw.Isolation {
+	case IsolationServer:
+		return networkName("pterodactyl_srv_", e.Id)
+	case IsolationOwner:
+		if owner := e.networkSettings().Owner; owner != "" {
+			return networkName("pterodactyl_usr_", owner)
+		}
+		return networkName("pterodactyl_srv_", e.Id)
+	}
+	return ""
+}
+
+// linkNetworkNames returns the names of the private networks the server has been
+// linked into.
+func (e *Environment) linkNetworkNames() []string {
+	var out []string
+	for _, l := range e.networkSettings().Links {
+		if n := networkName("pterodactyl_link_", l); n != "pterodactyl_link_" {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+func (e *Environment) networkSettings() NetworkSettings {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.meta.Network
+}
+
+// networkMu is held for reading while a container is being created and attached
+// to its networks, and for writing while unused server networks are removed, so
+// that a network is never removed between being created and attached.
+// networkAllocMu serializes the allocation of subnets from the isolation pool.
+var (
+	networkMu      sync.RWMutex
+	networkAllocMu sync.Mutex
+)
+
+// ErrNetworkPoolExhausted is returned when every subnet in the isolation pool is
+// already in use by another network.
+var ErrNetworkPoolExhausted = errors.Sentinel("environment/docker: no free subnets left in the network isolation pool")
+
+// ensureNetwork creates a bridge network for server containers with the given
+// name if it does not already exist, using a subnet allocated from the isolation
+// pool. Like the default network, it is also given an IPv6 subnet, derived from
+// the name of the network since there is no pool to allocate these from, so that
+// servers keep their IPv6 connectivity and dual-stack bindings when isolated.
+func (e *Environment) ensureNetwork(ctx context.Context, name string) error {
+	if _, err := e.client.NetworkInspect(ctx, name, types.NetworkInspectOptions{}); err == nil {
+		return nil
+	} else if !client.IsErrNotFound(err) {
+		return err
+	}
+
+	networkAllocMu.Lock()
+	defer networkAllocMu.Unlock()
+
+	nw := config.Get().Docker.Network
+	subnet, err := e.allocateSubnet(ctx, nw.IsolationPool.Subnet, nw.IsolationPool.Size)
+	if err != nil {
+		return errors.Wrapf(err, "environment/docker: failed to allocate subnet for network \"%s\"", name)
+	}
+	_, err = e.client.NetworkCreate(ctx, name, types.NetworkCreate{
+		Driver:     "bridge",
+		EnableIPv6: true,
+		Labels: map[string]string{
+			"Service":       "Pterodactyl",
+			"ContainerType": "server_network",
+		},
+		IPAM: &network.IPAM{
+			Driver: "default",
+			Config: []network.IPAMConfig{{Subnet: subnet.String()}, {Subnet: uniqueLocalSubnet(name)}},
+		},
+		Options: map[string]string{
+			"com.docker.network.bridge.default_bridge":       "false",
+			"com.docker.network.bridge.enable_icc":           "true",
+			"com.docker.network.bridge.enable_ip_masquerade": "true",
+			"com.docker.network.driver.mtu":                  strconv.FormatInt(nw.NetworkMTU, 10),
+		},
+	})
+	// Another server may have created the same network at the same time.
+	if err != nil && !errdefs.IsConflict(err) {
+		return errors.Wrapf(err, "environment/docker: failed to create network \"%s\"", name)
+	}
+	return nil
+}
+
+// allocateSubnet returns the first subnet of the given size in the pool that does
+// not overlap the subnet of any existing Docker network.
+func (e *Environment) allocateSubnet(ctx context.Context, pool string, size int) (netip.Prefix, error) {
+	networks, err := e.client.NetworkList(ctx, types.NetworkListOptions{})
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	var used []netip.Prefix
+	for _, n := range networks {
+		for _, c := range n.IPAM.Config {
+			if p, err := netip.ParsePrefix(c.Subnet); err == nil {
+				used = append(used, p.Masked())
+			}
+		}
+	}
+	return nextSubnet(pool, size, used)
+}
+
+// nextSubnet returns the first subnet of the given size in the IPv4 pool that does
+// not overlap any of the used subnets.
+func nextSubnet(pool string, size int, used []netip.Prefix) (netip.Prefix, error) {
+	p, err := netip.ParsePrefix(pool)
+	if err != nil {
+		return netip.Prefix{}, errors.Wrap(err, "environment/docker: invalid network isolation pool")
+	}
+	p = p.Masked()
+	if !p.Addr().Is4() {
+		return netip.Prefix{}, errors.New("environment/docker: network isolation pool must be an IPv4 subnet")
+	}
+	if size < p.Bits() || size > 30 {
+		return netip.Prefix{}, errors.Errorf("environment/docker: network isolation subnet size must be between /%d and /30", p.Bits())
+	}
+
+	a := p.Addr().As4()
+	start := binary.BigEndian.Uint32(a[:])
+	step := uint32(1) << (32 - size)
+	count := uint64(1) << (size - p.Bits())
+outer:
+	for i := uint64(0); i < count; i++ {
+		binary.BigEndian.PutUint32(a[:], start+uint32(i)*step)
+		candidate := netip.PrefixFrom(netip.AddrFrom4(a), size)
+		for _, u := range used {
+			if candidate.Overlaps(u) {
+				continue outer
+			}
+		}
+		return candidate, nil
+	}
+	return netip.Prefix{}, errors.WithStack(ErrNetworkPoolExhausted)
+}
+
+// connectLinkNetworks attaches the container to each of the private networks the
+// server has been linked into, creating them if necessary. The container is
+// reachable by other servers on these networks using its identifier.
+func (e *Environment) connectLinkNetworks(ctx context.Context) error {
+	for _, name := range e.linkNetworkNames() {
+		if err := e.ensureNetwork(ctx, name); err != nil {
+			return err
+		}
+		err := e.client.NetworkConnect(ctx, name, e.Id, &network.EndpointSettings{Aliases: []string{e.Id}})
+		if err != nil {
+			return errors.Wrapf(err, "environment/docker: failed to connect container to network \"%s\"", name)
+		}
+	}
+	return nil
+}
+
+// isServerNetwork returns true if the network is one Wings creates for isolating
+// or linking server containers.
+func isServerNetwork(name string) bool {
+	for _, prefix := range []string{"pterodactyl_srv_", "pterodactyl_usr_", "pterodactyl_link_"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// serverNetworks returns the names of the isolated and linked networks the server
+// container is currently attached to, along with the dedicated network for the
+// server so that it is cleaned up even if the isolation mode has changed.
+func (e *Environment) serverNetworks(ctx context.Context) []string {
+	out := []string{networkName("pterodactyl_srv_", e.Id)}
+	c, err := e.ContainerInspect(ctx)
+	if err != nil || c.NetworkSettings == nil {
+		return out
+	}
+	for name := range c.NetworkSettings.Networks {
+		if isServerNetwork(name) && !slices.Contains(out, name) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// removeUnusedNetworks removes each of the isolated or linked networks that no
+// longer have any containers attached to them, except for those being kept.
+func (e *Environment) removeUnusedNetworks(ctx context.Context, networks []string, keep ...string) {
+	networkMu.Lock()
+	defer networkMu.Unlock()
+
+	for _, name := range networks {
+		if !isServerNetwork(name) || slices.Contains(keep, name) {
+			continue
+		}
+		res, err := e.client.NetworkInspect(ctx, name, types.NetworkInspectOptions{})
+		if err != nil {
+			if !client.IsErrNotFound(err) {
+				e.log().WithField("network", name).WithField("error", err).Warn("environment/docker: failed to inspect server network")
+			}
+			continue
+		}
+		if len(res.Containers) > 0 || res.Labels["ContainerType"] != "server_network" {
+			continue
+		}
+		if err := e.client.NetworkRemove(ctx, name); err != nil && !client.IsErrNotFound(err) {
+			e.log().WithField("network", name).WithField("error", err).Warn("environment/docker: failed to remove unused server network")
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package docker
+
+import (
+	"net/netip"
+	"testing"
+
+	"emperror.dev/errors"
+	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/environment"
+)
+
+func TestNextSubnet(t *testing.T) {
+	g := Goblin(t)
+
+	prefixes := func(s ...string) []netip.Prefix {
+		var out []netip.Prefix
+		for _, p := range s {
+			out = append(out, netip.MustParsePrefix(p))
+		}
+		return out
+	}
+
+	g.Describe("nextSubnet", func() {
+		for _, tc := range []struct {
+			name     string
+			pool     string
+			size     int
+			used     []netip.Prefix
+			expected string
+		}{
+			{"returns the first subnet of an empty pool", "172.30.0.0/16", 26, nil, "172.30.0.0/26"},
+			{"masks the pool", "172.30.1.5/16", 26, nil, "172.30.0.0/26"},
+			{"skips used subnets", "172.30.0.0/16", 26, prefixes("172.30.0.0/26", "172.30.0.64/26"), "172.30.0.128/26"},
+			{"skips subnets overlapping a larger network", "172.30.0.0/16", 26, prefixes("172.30.0.0/24"), "172.30.1.0/26"},
+			{"skips subnets containing a smaller network", "172.30.0.0/16", 24, prefixes("172.30.0.128/28"), "172.30.1.0/24"},
+			{"ignores networks outside of the pool", "172.30.0.0/16", 26, prefixes("172.18.0.0/16", "fdba:17c8:6c94::/64"), "172.30.0.0/26"},
+			{"uses the whole pool as a single subnet", "10.0.0.0/30", 30, nil, "10.0.0.0/30"},
+		} {
+			tc := tc
+			g.It(tc.name, func() {
+				p, err := nextSubnet(tc.pool, tc.size, tc.used)
+				g.Assert(err).IsNil()
+				g.Assert(p.String()).Equal(tc.expected)
+			})
+		}
+
+		g.It("returns an error when the pool is exhausted", func() {
+			_, err := nextSubnet("172.30.0.0/24", 25, prefixes("172.30.0.0/25", "172.30.0.128/25"))
+			g.Assert(errors.Is(err, ErrNetworkPoolExhausted)).IsTrue()
+		})
+
+		for _, tc := range []struct {
+			name string
+			pool string
+			size int
+		}{
+			{"returns an error for an invalid pool", "not-a-subnet", 26},
+			{"returns an error for an IPv6 pool", "fd00::/48", 64},
+			{"returns an error for a subnet larger than the pool", "172.30.0.0/16", 15},
+			{"returns an error for a subnet smaller than a /30", "172.30.0.0/16", 31},
+		} {
+			tc := tc
+			g.It(tc.name, func() {
+				_, err := nextSubnet(tc.pool, tc.size, nil)
+				g.Assert(err == nil).IsFalse()
+				g.Assert(errors.Is(err, ErrNetworkPoolExhausted)).IsFalse()
+			})
+		}
+	})
+}
+
+func TestNetworkName(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("networkName", func() {
+		for _, tc := range []struct {
+			prefix   string
+			id       string
+			expected string
+		}{
+			{"pterodactyl_srv_", "d8ef0e3c-5b7a-4f0e-9b1c-2d3e4f5a6b7c", "pterodactyl_srv_d8ef0e3c-5b7a-4f0e-9b1c-2d3e4f5a6b7c"},
+			{"pterodactyl_usr_", "User_01", "pterodactyl_usr_User_01"},
+			{"pterodactyl_link_", "proxy.backend/../ 1", "pterodactyl_link_proxybackend1"},
+			{"pterodactyl_link_", "üñí", "pterodactyl_link_"},
+			{"pterodactyl_link_", "", "pterodactyl_link_"},
+		} {
+			tc := tc
+			g.It("returns "+tc.expected+" for \""+tc.id+"\"", func() {
+				g.Assert(networkName(tc.prefix, tc.id)).Equal(tc.expected)
+			})
+		}
+	})
+}
+
+func TestIsolatedNetworkName(t *testing.T) {
+	g := Goblin(t)
+
+	newEnvironment := func(owner string, force bool) *Environment {
+		var s environment.Settings
+		s.Allocations.ForceOutgoingIP = force
+		return &Environment{
+			Id:            "d8ef0e3c",
+			Configuration: environment.NewConfiguration(s, nil),
+			meta:          &Metadata{Network: NetworkSettings{Owner: owner}},
+		}
+	}
+
+	g.Describe("isolatedNetworkName", func() {
+		for _, tc := range []struct {
+			name      string
+			driver    string
+			ispn      bool
+			isolation string
+			owner     string
+			force     bool
+			expected  string
+		}{
+			{"uses the default network without isolation", "bridge", false, IsolationNone, "u1", false, ""},
+			{"uses the default network for an unknown mode", "bridge", false, "unknown", "u1", false, ""},
+			{"uses a network for the server", "bridge", false, IsolationServer, "u1", false, "pterodactyl_srv_d8ef0e3c"},
+			{"uses a network for the owner", "bridge", false, IsolationOwner, "u1", false, "pterodactyl_usr_u1"},
+			{"uses a network for the server without an owner", "bridge", false, IsolationOwner, "", false, "pterodactyl_srv_d8ef0e3c"},
+			{"does not isolate host networking", "host", false, IsolationServer, "u1", false, ""},
+			{"does not isolate when using ISPN", "overlay", true, IsolationServer, "u1", false, ""},
+			{"does not isolate servers forcing their outgoing IP", "bridge", false, IsolationServer, "u1", true, ""},
+		} {
+			tc := tc
+			g.It(tc.name, func() {
+				config.Set(&config.Configuration{
+					AuthenticationToken: "abc",
+					Docker: config.DockerConfiguration{
+						Network: config.DockerNetworkConfiguration{Driver: tc.driver, ISPN: tc.ispn, Isolation: tc.isolation},
+					},
+				})
+				g.Assert(newEnvironment(tc.owner, tc.force).isolatedNetworkName()).Equal(tc.expected)
+			})
+		}
+	})
+}
+
+func TestIsServerNetwork(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("isServerNetwork", func() {
+		for name, expected := range map[string]bool{
+			"pterodactyl_srv_d8ef0e3c": true,
+			"pterodactyl_usr_u1":       true,
+			"pterodactyl_link_proxy":   true,
+			"pterodactyl_nw":           false,
+			"ip-192-168-1-1":           false,
+			"bridge":                   false,
+			"my_pterodactyl_srv_1":     false,
+		} {
+			name, expected := name, expected
+			g.It("returns the expected value for "+name, func() {
+				g.Assert(isServerNetwork(name)).Equal(expected)
+			})
+		}
+	})
+}
+
+func TestUniqueLocalSubnet(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("uniqueLocalSubnet", func() {
+		g.It("returns a stable unique local /64 subnet", func() {
+			p, err := netip.ParsePrefix(uniqueLocalSubnet("pterodactyl_srv_d8ef0e3c"))
+			g.Assert(err).IsNil()
+			g.Assert(p.Bits()).Equal(64)
+			g.Assert(netip.MustParsePrefix("fd00::/8").Contains(p.Addr())).IsTrue()
+			g.Assert(uniqueLocalSubnet("pterodactyl_srv_d8ef0e3c")).Equal(uniqueLocalSubnet("pterodactyl_srv_d8ef0e3c"))
+		})
+
+		g.It("returns a different subnet for each key", func() {
+			g.Assert(uniqueLocalSubnet("pterodactyl_srv_a") == uniqueLocalSubnet("pterodactyl_srv_b")).IsFalse()
+		})
+	})
+}
@@ -27,11 +27,15 @@ import (
 // is running does not result in the server becoming un-bootable.
 func (e *Environment) OnBeforeStart(ctx context.Context) error {
 	// Always destroy and re-create the server container to ensure that synced data from the Panel is used.
+	networks := e.serverNetworks(ctx)
 	if err := e.client.ContainerRemove(ctx, e.Id, types.ContainerRemoveOptions{RemoveVolumes: true}); err != nil {
 		if !client.IsErrNotFound(err) {
 			return errors.WrapIf(err, "environment/docker: failed to remove container during pre-boot")
 		}
 	}
+	// Remove the networks the server has left, such as those it is no longer linked
+	// into, keeping the ones the new container is attached to.
+	e.removeUnusedNetworks(ctx, networks, append(e.linkNetworkNames(), e.isolatedNetworkName())...)
 
 	// The Create() function will check if the container exists in the first place, and if
 	// so just silently return without an error. Otherwise, it will try to create the necessary
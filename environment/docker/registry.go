@@ -0,0 +1,189 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/docker/docker/api/types/registry"
+	"github.com/goccy/go-json"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/remote"
+)
+
+// The amount of time credentials returned by a credential helper are reused for
+// before the helper is called again. Registries such as ECR and GCR issue tokens
+// that expire, so this is kept well below the lifetime of those tokens.
+const credentialHelperTTL = time.Minute * 10
+
+// The amount of time a credential helper is allowed to run for.
+const credentialHelperTimeout = time.Second * 30
+
+// The server URL Docker uses for credentials for Docker Hub.
+const dockerHubServer = "https://index.docker.io/v1/"
+
+type cachedCredentials struct {
+	auth    registry.AuthConfig
+	expires time.Time
+}
+
+var helperCache = struct {
+	sync.Mutex
+	m map[string]cachedCredentials
+}{m: make(map[string]cachedCredentials)}
+
+// registryHost returns the hostname of the registry the image is pulled from,
+// following the same rules Docker uses when parsing image references.
+func registryHost(image string) string {
+	i := strings.Index(image, "/")
+	if i == -1 {
+		return "docker.io"
+	}
+	host := image[:i]
+	if !strings.ContainsAny(host, ".:") && host != "localhost" {
+		return "docker.io"
+	}
+	return host
+}
+
+// credentialHelperServer returns the server URL passed to credential helpers for
+// the given registry host.
+func credentialHelperServer(host string) string {
+	if host == "docker.io" || host == "index.docker.io" || host == "registry-1.docker.io" {
+		return dockerHubServer
+	}
+	return host
+}
+
+// credentialHelper returns the credentials for a registry from a Docker
+// credential helper, reusing credentials retrieved within the last few minutes.
+func credentialHelper(ctx context.Context, helper string, host string) (registry.AuthConfig, error) {
+	key := helper + "|" + host
+	helperCache.Lock()
+	c, ok := helperCache.m[key]
+	helperCache.Unlock()
+	if ok && time.Now().Before(c.expires) {
+		return c.auth, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, credentialHelperTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(credentialHelperServer(host))
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stdout.String() + " " + stderr.String())
+		return registry.AuthConfig{}, errors.Wrapf(err, "environment/docker: credential helper \"%s\" failed: %s", helper, msg)
+	}
+
+	auth, err := parseCredentialHelperOutput(host, stdout.Bytes())
+	if err != nil {
+		return registry.AuthConfig{}, errors.Wrapf(err, "environment/docker: failed to parse response from credential helper \"%s\"", helper)
+	}
+
+	helperCache.Lock()
+	helperCache.m[key] = cachedCredentials{auth: auth, expires: time.Now().Add(credentialHelperTTL)}
+	helperCache.Unlock()
+
+	return auth, nil
+}
+
+// parseCredentialHelperOutput returns the credentials for the registry host from
+// the output of a credential helper.
+func parseCredentialHelperOutput(host string, b []byte) (registry.AuthConfig, error) {
+	var res struct {
+		ServerURL string
+		Username  string
+		Secret    string
+	}
+	if err := json.Unmarshal(b, &res); err != nil {
+		return registry.AuthConfig{}, errors.WithStack(err)
+	}
+
+	auth := registry.AuthConfig{ServerAddress: host}
+	// Helpers return this username when the secret is an identity token rather
+	// than a password, matching the behavior of the Docker CLI.
+	if res.Username == "<token>" {
+		auth.IdentityToken = res.Secret
+	} else {
+		auth.Username = res.Username
+		auth.Password = res.Secret
+	}
+	return auth, nil
+}
+
+// forgetCredentialHelper removes any cached credential helper credentials for
+// the registry so that they are retrieved again on the next pull.
+func forgetCredentialHelper(helper string, host string) {
+	helperCache.Lock()
+	delete(helperCache.m, helper+"|"+host)
+	helperCache.Unlock()
+}
+
+// encodeAuth returns the credentials encoded in the format expected by the
+// Docker API.
+func encodeAuth(auth registry.AuthConfig) (string, error) {
+	b, err := json.Marshal(auth)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return base64.URLEncoding.EncodeToString(b), nil
+}
+
+// nodeRegistry returns the registry configured on the node whose prefix matches
+// the image. If multiple registries match, the longest prefix is used.
+func nodeRegistry(image string) (string, config.RegistryConfiguration, bool) {
+	var (
+		name  string
+		match config.RegistryConfiguration
+		found bool
+	)
+	for prefix, c := range config.Get().Docker.Registries {
+		if strings.HasPrefix(image, prefix) && len(prefix) >= len(name) {
+			name, match, found = prefix, c, true
+		}
+	}
+	return name, match, found
+}
+
+// RegistryAuth returns the base64 encoded registry credentials to use when
+// pulling the given image, or an empty string if there are none. Credentials
+// provided for the server or installation take priority over the registries
+// configured on the node, which may either contain static credentials or the
+// name of a credential helper.
+func RegistryAuth(ctx context.Context, image string, creds *remote.RegistryCredentials) (string, error) {
+	if !creds.IsEmpty() {
+		log.WithField("image", image).Debug("using server provided authentication for registry")
+		return encodeAuth(registry.AuthConfig{
+			Username:      creds.Username,
+			Password:      creds.Password,
+			IdentityToken: creds.IdentityToken,
+			RegistryToken: creds.RegistryToken,
+			ServerAddress: registryHost(image),
+		})
+	}
+
+	name, c, ok := nodeRegistry(image)
+	if !ok {
+		return "", nil
+	}
+	log.WithField("registry", name).Debug("using authentication for registry")
+	if c.CredentialHelper == "" {
+		return c.Base64()
+	}
+	auth, err := credentialHelper(ctx, c.CredentialHelper, registryHost(image))
+	if err != nil {
+		return "", err
+	}
+	return encodeAuth(auth)
+}
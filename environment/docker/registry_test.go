@@ -0,0 +1,178 @@
+package docker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/api/types/registry"
+	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestRegistryHost(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("registryHost", func() {
+		for image, host := range map[string]string{
+			"alpine":                                  "docker.io",
+			"alpine:3.19":                             "docker.io",
+			"library/alpine":                          "docker.io",
+			"pterodactyl/yolks:java_17":               "docker.io",
+			"ghcr.io/pterodactyl/yolks:java_17":       "ghcr.io",
+			"registry.example.com:5000/app:1.0":       "registry.example.com:5000",
+			"localhost/app":                           "localhost",
+			"localhost:5000/app":                      "localhost:5000",
+			"123.dkr.ecr.us-east-1.amazonaws.com/app": "123.dkr.ecr.us-east-1.amazonaws.com",
+			"ghcr.io/pterodactyl/yolks@sha256:abc":    "ghcr.io",
+		} {
+			image, host := image, host
+			g.It("returns "+host+" for "+image, func() {
+				g.Assert(registryHost(image)).Equal(host)
+			})
+		}
+	})
+
+	g.Describe("credentialHelperServer", func() {
+		for host, server := range map[string]string{
+			"docker.io":            dockerHubServer,
+			"index.docker.io":      dockerHubServer,
+			"registry-1.docker.io": dockerHubServer,
+			"ghcr.io":              "ghcr.io",
+		} {
+			host, server := host, server
+			g.It("returns "+server+" for "+host, func() {
+				g.Assert(credentialHelperServer(host)).Equal(server)
+			})
+		}
+	})
+}
+
+func TestNodeRegistry(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("nodeRegistry", func() {
+		g.BeforeEach(func() {
+			config.Set(&config.Configuration{
+				AuthenticationToken: "abc",
+				Docker: config.DockerConfiguration{
+					Registries: map[string]config.RegistryConfiguration{
+						"ghcr.io":                 {Username: "node"},
+						"ghcr.io/pterodactyl":     {Username: "pterodactyl"},
+						"ghcr.io/pterodactyl/app": {Username: "app"},
+						"registry.example.com":    {CredentialHelper: "example"},
+					},
+				},
+			})
+		})
+
+		for _, tc := range []struct {
+			image string
+			name  string
+			found bool
+		}{
+			{"ghcr.io/parkervcp/yolks:java", "ghcr.io", true},
+			{"ghcr.io/pterodactyl/yolks:java", "ghcr.io/pterodactyl", true},
+			{"ghcr.io/pterodactyl/app:latest", "ghcr.io/pterodactyl/app", true},
+			{"registry.example.com/app", "registry.example.com", true},
+			{"docker.io/library/alpine", "", false},
+			{"alpine", "", false},
+		} {
+			tc := tc
+			g.It("returns the registry for "+tc.image, func() {
+				name, c, ok := nodeRegistry(tc.image)
+				g.Assert(ok).Equal(tc.found)
+				g.Assert(name).Equal(tc.name)
+				if tc.found {
+					g.Assert(c).Equal(config.Get().Docker.Registries[tc.name])
+				}
+			})
+		}
+	})
+}
+
+func TestCredentialHelper(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("parseCredentialHelperOutput", func() {
+		for _, tc := range []struct {
+			name   string
+			output string
+			want   registry.AuthConfig
+		}{
+			{
+				name:   "a username and password",
+				output: `{"ServerURL":"ghcr.io","Username":"user","Secret":"pass"}`,
+				want:   registry.AuthConfig{ServerAddress: "ghcr.io", Username: "user", Password: "pass"},
+			},
+			{
+				name:   "an identity token",
+				output: `{"ServerURL":"ghcr.io","Username":"<token>","Secret":"refresh"}`,
+				want:   registry.AuthConfig{ServerAddress: "ghcr.io", IdentityToken: "refresh"},
+			},
+			{
+				name:   "output without credentials",
+				output: `{}`,
+				want:   registry.AuthConfig{ServerAddress: "ghcr.io"},
+			},
+		} {
+			tc := tc
+			g.It("parses "+tc.name, func() {
+				auth, err := parseCredentialHelperOutput("ghcr.io", []byte(tc.output))
+				g.Assert(err).IsNil()
+				g.Assert(auth).Equal(tc.want)
+			})
+		}
+
+		g.It("returns an error for invalid output", func() {
+			_, err := parseCredentialHelperOutput("ghcr.io", []byte("credentials not found in native keychain"))
+			g.Assert(err == nil).IsFalse()
+		})
+	})
+
+	g.Describe("credentialHelper", func() {
+		var path string
+		g.Before(func() {
+			dir := t.TempDir()
+			// The helper returns the server it was asked for as the username, and
+			// fails for any registry other than ghcr.io.
+			script := "#!/bin/sh\nread server\nif [ \"$server\" != \"ghcr.io\" ] && [ \"$server\" != \"" + dockerHubServer + "\" ]; then echo 'credentials not found'; exit 1; fi\n" +
+				"echo \"{\\\"Username\\\":\\\"$server\\\",\\\"Secret\\\":\\\"$(date +%s%N)\\\"}\"\n"
+			if err := os.WriteFile(filepath.Join(dir, "docker-credential-test"), []byte(script), 0o755); err != nil {
+				panic(err)
+			}
+			path = os.Getenv("PATH")
+			_ = os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+		})
+		g.After(func() {
+			_ = os.Setenv("PATH", path)
+		})
+
+		g.It("returns the credentials from the helper", func() {
+			auth, err := credentialHelper(context.Background(), "test", "docker.io")
+			g.Assert(err).IsNil()
+			g.Assert(auth.ServerAddress).Equal("docker.io")
+			g.Assert(auth.Username).Equal(dockerHubServer)
+		})
+
+		g.It("reuses the credentials until they are forgotten", func() {
+			first, err := credentialHelper(context.Background(), "test", "ghcr.io")
+			g.Assert(err).IsNil()
+			second, err := credentialHelper(context.Background(), "test", "ghcr.io")
+			g.Assert(err).IsNil()
+			g.Assert(second.Password).Equal(first.Password)
+
+			forgetCredentialHelper("test", "ghcr.io")
+			third, err := credentialHelper(context.Background(), "test", "ghcr.io")
+			g.Assert(err).IsNil()
+			g.Assert(third.Password == first.Password).IsFalse()
+		})
+
+		g.It("returns an error if the helper fails", func() {
+			_, err := credentialHelper(context.Background(), "test", "registry.example.com")
+			g.Assert(err == nil).IsFalse()
+		})
+	})
+}
@@ -0,0 +1,82 @@
+package docker
+
+import (
+	"os"
+
+	"emperror.dev/errors"
+	"github.com/docker/docker/api/types/container"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// The name of the security profile used for servers that do not select one, if
+// it is defined in the configuration.
+const defaultSecurityProfile = "default"
+
+// ErrUnknownSecurityProfile is returned when a server selects a security profile
+// that is not defined in the configuration.
+var ErrUnknownSecurityProfile = errors.Sentinel("environment/docker: unknown security profile")
+
+// DefaultCapDrop is the list of capabilities dropped from server containers when
+// the security profile does not define its own list.
+var DefaultCapDrop = []string{
+	"setpcap", "mknod", "audit_write", "net_raw", "dac_override",
+	"fowner", "fsetid", "net_bind_service", "sys_chroot", "setfcap",
+}
+
+// securityProfile returns the security profile with the given name from the
+// configuration. If no name is provided the "default" profile is returned if it
+// exists, otherwise an empty profile is returned so the built-in defaults apply.
+func securityProfile(name string) (config.SecurityProfile, error) {
+	profiles := config.Get().Docker.SecurityProfiles
+	if name == "" {
+		return profiles[defaultSecurityProfile], nil
+	}
+	p, ok := profiles[name]
+	if !ok {
+		return p, errors.WithStack(errors.WithDetails(ErrUnknownSecurityProfile, "profile", name))
+	}
+	return p, nil
+}
+
+// applySecurityProfile configures the security options of the container using
+// the security profile. Ulimits defined by the profile are not applied here since
+// they need to be merged with those for the node and server.
+func applySecurityProfile(hc *container.HostConfig, p config.SecurityProfile) error {
+	hc.SecurityOpt = nil
+	if !p.AllowNewPrivileges {
+		hc.SecurityOpt = append(hc.SecurityOpt, "no-new-privileges")
+	}
+	if p.AppArmor != "" {
+		hc.SecurityOpt = append(hc.SecurityOpt, "apparmor="+p.AppArmor)
+	}
+	if p.Seccomp == "unconfined" {
+		hc.SecurityOpt = append(hc.SecurityOpt, "seccomp=unconfined")
+	} else if p.Seccomp != "" {
+		// The Docker API expects the contents of the profile rather than a path to it,
+		// unlike the Docker CLI.
+		b, err := os.ReadFile(p.Seccomp)
+		if err != nil {
+			return errors.Wrapf(err, "environment/docker: failed to read seccomp profile \"%s\"", p.Seccomp)
+		}
+		hc.SecurityOpt = append(hc.SecurityOpt, "seccomp="+string(b))
+	}
+
+	hc.CapAdd = p.CapAdd
+	hc.CapDrop = DefaultCapDrop
+	if p.CapDrop != nil {
+		hc.CapDrop = p.CapDrop
+	}
+	hc.ReadonlyRootfs = !p.WritableRootfs
+
+	if hc.Tmpfs == nil {
+		hc.Tmpfs = make(map[string]string, len(p.Tmpfs))
+	}
+	for path, opts := range p.Tmpfs {
+		hc.Tmpfs[path] = opts
+	}
+	if len(p.Sysctls) > 0 {
+		hc.Sysctls = p.Sysctls
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"emperror.dev/errors"
+	"github.com/docker/docker/api/types/container"
+	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestSecurityProfile(t *testing.T) {
+	g := Goblin(t)
+
+	setProfiles := func(profiles map[string]config.SecurityProfile) {
+		config.Set(&config.Configuration{
+			AuthenticationToken: "abc",
+			Docker:              config.DockerConfiguration{SecurityProfiles: profiles},
+		})
+	}
+
+	g.Describe("securityProfile", func() {
+		g.It("returns an empty profile if no default profile is defined", func() {
+			setProfiles(nil)
+			p, err := securityProfile("")
+			g.Assert(err).IsNil()
+			g.Assert(p).Equal(config.SecurityProfile{})
+		})
+
+		g.It("returns the default profile if no profile is selected", func() {
+			setProfiles(map[string]config.SecurityProfile{
+				"default": {AppArmor: "wings-default"},
+				"custom":  {AppArmor: "wings-custom"},
+			})
+			p, err := securityProfile("")
+			g.Assert(err).IsNil()
+			g.Assert(p.AppArmor).Equal("wings-default")
+		})
+
+		g.It("returns the selected profile", func() {
+			setProfiles(map[string]config.SecurityProfile{
+				"default": {AppArmor: "wings-default"},
+				"custom":  {AppArmor: "wings-custom"},
+			})
+			p, err := securityProfile("custom")
+			g.Assert(err).IsNil()
+			g.Assert(p.AppArmor).Equal("wings-custom")
+		})
+
+		g.It("returns an error for a profile that is not defined", func() {
+			setProfiles(map[string]config.SecurityProfile{"default": {}})
+			_, err := securityProfile("missing")
+			g.Assert(errors.Is(err, ErrUnknownSecurityProfile)).IsTrue()
+		})
+	})
+
+	g.Describe("applySecurityProfile", func() {
+		g.It("applies the built-in defaults for an empty profile", func() {
+			hc := &container.HostConfig{SecurityOpt: []string{"label=disable"}}
+			g.Assert(applySecurityProfile(hc, config.SecurityProfile{})).IsNil()
+
+			g.Assert(hc.SecurityOpt).Equal([]string{"no-new-privileges"})
+			g.Assert([]string(hc.CapDrop)).Equal(DefaultCapDrop)
+			g.Assert(len(hc.CapAdd)).Equal(0)
+			g.Assert(hc.ReadonlyRootfs).IsTrue()
+			g.Assert(len(hc.Tmpfs)).Equal(0)
+			g.Assert(len(hc.Sysctls)).Equal(0)
+		})
+
+		g.It("applies a custom profile", func() {
+			hc := &container.HostConfig{Tmpfs: map[string]string{"/tmp": "rw,exec,nosuid,size=100M"}}
+			err := applySecurityProfile(hc, config.SecurityProfile{
+				Seccomp:            "unconfined",
+				AppArmor:           "wings-custom",
+				AllowNewPrivileges: true,
+				CapAdd:             []string{"SYS_NICE"},
+				CapDrop:            []string{"ALL"},
+				WritableRootfs:     true,
+				Tmpfs:              map[string]string{"/tmp": "rw,size=1G", "/run": "rw"},
+				Sysctls:            map[string]string{"net.ipv4.ip_unprivileged_port_start": "0"},
+			})
+			g.Assert(err).IsNil()
+
+			g.Assert(hc.SecurityOpt).Equal([]string{"apparmor=wings-custom", "seccomp=unconfined"})
+			g.Assert([]string(hc.CapAdd)).Equal([]string{"SYS_NICE"})
+			g.Assert([]string(hc.CapDrop)).Equal([]string{"ALL"})
+			g.Assert(hc.ReadonlyRootfs).IsFalse()
+			g.Assert(hc.Tmpfs).Equal(map[string]string{"/tmp": "rw,size=1G", "/run": "rw"})
+			g.Assert(hc.Sysctls).Equal(map[string]string{"net.ipv4.ip_unprivileged_port_start": "0"})
+		})
+
+		g.It("keeps every capability if the profile drops none", func() {
+			hc := &container.HostConfig{}
+			g.Assert(applySecurityProfile(hc, config.SecurityProfile{CapDrop: []string{}})).IsNil()
+			g.Assert(len(hc.CapDrop)).Equal(0)
+		})
+
+		g.It("sends the contents of a seccomp profile file", func() {
+			p := filepath.Join(t.TempDir(), "seccomp.json")
+			g.Assert(os.WriteFile(p, []byte(`{"defaultAction":"SCMP_ACT_ERRNO"}`), 0o644)).IsNil()
+
+			hc := &container.HostConfig{}
+			g.Assert(applySecurityProfile(hc, config.SecurityProfile{Seccomp: p})).IsNil()
+			g.Assert(hc.SecurityOpt).Equal([]string{"no-new-privileges", `seccomp={"defaultAction":"SCMP_ACT_ERRNO"}`})
+		})
+
+		g.It("returns an error if the seccomp profile cannot be read", func() {
+			hc := &container.HostConfig{}
+			err := applySecurityProfile(hc, config.SecurityProfile{Seccomp: filepath.Join(t.TempDir(), "missing.json")})
+			g.Assert(errors.Is(err, os.ErrNotExist)).IsTrue()
+		})
+	})
+}
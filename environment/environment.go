@@ -113,4 +113,9 @@ type ProcessEnvironment interface {
 
 	// SetLogCallback sets the callback that the container's log output will be passed to.
 	SetLogCallback(func([]byte))
+
+	// Detach stops following the output and resource usage of the server process
+	// without affecting the process itself or the state of the environment. This
+	// is used when another Wings process takes over managing the environment.
+	Detach()
 }
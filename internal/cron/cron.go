@@ -17,6 +17,15 @@ const ErrCronRunning = errors.Sentinel("cron: job already running")
 
 var o system.AtomicBool
 
+// The locks preventing each job from running while a previous run of it has not
+// finished. These are shared by every job created for the scheduler, since the jobs
+// are replaced when the scheduler is reloaded even if a run is still in progress.
+var (
+	activityRunning = system.NewAtomicBool(false)
+	sftpRunning     = system.NewAtomicBool(false)
+	imagesRunning   = system.NewAtomicBool(false)
+)
+
 // Scheduler configures the internal cronjob system for Wings and returns the scheduler
 // instance to the caller. This should only be called once per application lifecycle, additional
 // calls will result in an error being returned.
@@ -29,19 +38,40 @@ func Scheduler(ctx context.Context, m *server.Manager) (*gocron.Scheduler, error
 		return nil, errors.Wrap(err, "cron: failed to parse configured system timezone")
 	}
 
+	s := gocron.NewScheduler(location)
+	schedule(ctx, s, m)
+	return s, nil
+}
+
+// Reload replaces the jobs of the scheduler with jobs using the current intervals
+// and timezone, for use after the configuration has been reloaded. Runs of the
+// previous jobs that are still in progress are not interrupted, and the new jobs
+// do not run until they have finished.
+func Reload(ctx context.Context, s *gocron.Scheduler, m *server.Manager) error {
+	location, err := time.LoadLocation(config.Get().System.Timezone)
+	if err != nil {
+		return errors.Wrap(err, "cron: failed to parse configured system timezone")
+	}
+	s.Clear()
+	s.ChangeLocation(location)
+	schedule(ctx, s, m)
+	return nil
+}
+
+// schedule adds the system jobs to the scheduler using the configured intervals.
+func schedule(ctx context.Context, s *gocron.Scheduler, m *server.Manager) {
 	activity := activityCron{
-		mu:      system.NewAtomicBool(false),
+		mu:      activityRunning,
 		manager: m,
 		max:     config.Get().System.ActivitySendCount,
 	}
 
 	sftp := sftpCron{
-		mu:      system.NewAtomicBool(false),
+		mu:      sftpRunning,
 		manager: m,
 		max:     config.Get().System.ActivitySendCount,
 	}
 
-	s := gocron.NewScheduler(location)
 	l := log.WithField("subsystem", "cron")
 
 	interval := time.Duration(config.Get().System.ActivitySendInterval) * time.Second
@@ -69,5 +99,20 @@ func Scheduler(ctx context.Context, m *server.Manager) (*gocron.Scheduler, error
 		}
 	})
 
-	return s, nil
+	if v := config.Get().Docker.Images.PruneInterval; v > 0 {
+		if len(config.Get().Docker.Images.PrunePrefixes) == 0 {
+			l.WithField("cron", "images").Warn("image pruning is enabled but no prune prefixes are configured, no images will be removed")
+		}
+		images := imagesCron{mu: imagesRunning, manager: m}
+		_, _ = s.Tag("images").Every(time.Duration(v) * time.Minute).Do(func() {
+			l.WithField("cron", "images").Debug("removing unused docker images")
+			if err := images.Run(ctx); err != nil {
+				if errors.Is(err, ErrCronRunning) {
+					l.WithField("cron", "images").Warn("image prune process is already running, skipping...")
+				} else {
+					l.WithField("cron", "images").WithField("error", err).Error("image prune process failed to execute")
+				}
+			}
+		})
+	}
 }
@@ -0,0 +1,89 @@
+package cron
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apex/log"
+	. "github.com/franela/goblin"
+	"github.com/go-co-op/gocron"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/server"
+)
+
+func TestReload(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Reload", func() {
+		var messages chan string
+		var handler log.Handler
+
+		g.BeforeEach(func() {
+			config.Set(&config.Configuration{
+				AuthenticationToken: "abc",
+				System: config.SystemConfiguration{
+					Timezone:             "UTC",
+					ActivitySendInterval: 60,
+					ActivitySendCount:    100,
+				},
+			})
+
+			messages = make(chan string, 16)
+			handler = log.Log.(*log.Logger).Handler
+			log.SetHandler(log.HandlerFunc(func(e *log.Entry) error {
+				select {
+				case messages <- e.Message:
+				default:
+				}
+				return nil
+			}))
+		})
+
+		g.AfterEach(func() {
+			log.SetHandler(handler)
+			activityRunning.Store(false)
+			sftpRunning.Store(false)
+		})
+
+		g.It("replaces the jobs of the scheduler", func() {
+			s := gocron.NewScheduler(time.UTC)
+			schedule(context.Background(), s, server.NewEmptyManager(nil))
+			g.Assert(len(s.Jobs())).Equal(2)
+
+			g.Assert(Reload(context.Background(), s, server.NewEmptyManager(nil))).IsNil()
+			g.Assert(len(s.Jobs())).Equal(2)
+			g.Assert(s.Location().String()).Equal("UTC")
+		})
+
+		g.It("does not run a job while a run started before reloading is in progress", func() {
+			s := gocron.NewScheduler(time.UTC)
+			schedule(context.Background(), s, server.NewEmptyManager(nil))
+
+			// Both jobs are still running when the scheduler is reloaded.
+			g.Assert(activityRunning.SwapIf(true)).IsTrue()
+			g.Assert(sftpRunning.SwapIf(true)).IsTrue()
+			g.Assert(Reload(context.Background(), s, server.NewEmptyManager(nil))).IsNil()
+
+			// The new jobs run immediately once the scheduler is started, and must be
+			// skipped rather than running alongside the previous runs.
+			s.StartAsync()
+			defer s.Stop()
+
+			skipped := map[string]bool{}
+			timeout := time.After(time.Second * 5)
+			for len(skipped) < 2 {
+				select {
+				case m := <-messages:
+					switch m {
+					case "activity process is already running, skipping...", "sftp events process already running, skipping...":
+						skipped[m] = true
+					}
+				case <-timeout:
+					g.Fail("jobs were not skipped")
+				}
+			}
+		})
+	})
+}
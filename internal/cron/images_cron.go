@@ -0,0 +1,39 @@
+package cron
+
+import (
+	"context"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/internal/images"
+	"github.com/pterodactyl/wings/server"
+	"github.com/pterodactyl/wings/system"
+)
+
+type imagesCron struct {
+	mu      *system.AtomicBool
+	manager *server.Manager
+}
+
+// Run removes any images on the system that have not been used by a server for
+// the configured grace period.
+func (ic *imagesCron) Run(ctx context.Context) error {
+	if !ic.mu.SwapIf(true) {
+		return errors.WithStack(ErrCronRunning)
+	}
+	defer ic.mu.Store(false)
+
+	removed, err := images.Get(ic.manager).Prune(ctx, false)
+	if err != nil {
+		return err
+	}
+	for _, u := range removed {
+		log.WithField("subsystem", "cron").
+			WithField("image", u.ID).
+			WithField("tags", u.Tags).
+			WithField("size", u.Size).
+			Info("removed unused docker image")
+	}
+	return nil
+}
@@ -0,0 +1,498 @@
+// Package images manages the Docker images present on the node, allowing them to
+// be pulled ahead of time and removing them once they are no longer used by any
+// server.
+package images
+
+import (
+	"context"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/client"
+	"github.com/goccy/go-json"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/environment"
+	"github.com/pterodactyl/wings/environment/docker"
+	"github.com/pterodactyl/wings/server"
+)
+
+// The amount of time a single image pull requested through the API is allowed to
+// run for, and how long the status of a finished pull is kept.
+const (
+	pullTimeout   = time.Minute * 30
+	pullRetention = time.Hour
+)
+
+// The states of an image pull.
+const (
+	PullPending   = "pending"
+	PullPulling   = "pulling"
+	PullCompleted = "completed"
+	PullFailed    = "failed"
+)
+
+// Manager tracks image pulls requested through the API, and the images that are
+// no longer referenced by any server on the node.
+type Manager struct {
+	mu           sync.Mutex
+	manager      *server.Manager
+	pulls        map[string]*Pull
+	unreferenced map[string]time.Time
+}
+
+var (
+	once     sync.Once
+	instance *Manager
+)
+
+// Get returns the image manager for the node. The first time it is called the
+// state stored on the disk by the previous run of Wings is restored.
+func Get(m *server.Manager) *Manager {
+	once.Do(func() {
+		instance = newManager(m)
+		if err := instance.load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.WithField("subsystem", "images").WithField("error", err).Warn("failed to load image state from disk")
+		}
+	})
+	return instance
+}
+
+func newManager(m *server.Manager) *Manager {
+	return &Manager{
+		manager:      m,
+		pulls:        make(map[string]*Pull),
+		unreferenced: make(map[string]time.Time),
+	}
+}
+
+// state is the information about the images on the node that is stored on the
+// disk, so that the grace period of an image is not reset when Wings restarts.
+type state struct {
+	// Unreferenced is the time each image was first seen without any servers
+	// using it, keyed by the image ID.
+	Unreferenced map[string]time.Time `json:"unreferenced"`
+	// LastUsed is the last time each image was pulled or used to create a
+	// container, keyed by the image reference.
+	LastUsed map[string]time.Time `json:"last_used"`
+}
+
+// load restores the state stored on the disk.
+func (m *Manager) load() error {
+	b, err := os.ReadFile(config.Get().System.GetImagesStatePath())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var st state
+	if err := json.Unmarshal(b, &st); err != nil {
+		return errors.Wrap(err, "images: failed to parse image state")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, t := range st.Unreferenced {
+		if _, ok := m.unreferenced[id]; !ok {
+			m.unreferenced[id] = t
+		}
+	}
+	for ref, t := range st.LastUsed {
+		docker.SetImageLastUsed(ref, t)
+	}
+	return nil
+}
+
+// save stores the state on the disk. The caller must hold the lock.
+func (m *Manager) save() error {
+	b, err := json.Marshal(state{Unreferenced: m.unreferenced, LastUsed: docker.ImagesLastUsed()})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	p := config.Get().System.GetImagesStatePath()
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp, p))
+}
+
+// PullStatus is the status of an image pull requested through the API.
+type PullStatus struct {
+	Image       string     `json:"image"`
+	Status      string     `json:"status"`
+	Current     int64      `json:"current"`
+	Total       int64      `json:"total"`
+	Error       string     `json:"error,omitempty"`
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// Pull tracks the pull of a single image requested through the API.
+type Pull struct {
+	mu     sync.RWMutex
+	status PullStatus
+	layers map[string]docker.PullProgress
+}
+
+// update records a progress message for a layer of the image.
+func (p *Pull) update(pp docker.PullProgress) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if pp.ID == "" || pp.ProgressDetail.Total == 0 {
+		return
+	}
+	p.layers[pp.ID] = pp
+	p.status.Current, p.status.Total = 0, 0
+	for _, l := range p.layers {
+		p.status.Current += l.ProgressDetail.Current
+		p.status.Total += l.ProgressDetail.Total
+	}
+}
+
+// start marks the pull as in progress.
+func (p *Pull) start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	p.status.Status = PullPulling
+	p.status.StartedAt = &now
+}
+
+// finish marks the pull as completed, or failed if an error is provided.
+func (p *Pull) finish(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := time.Now()
+	p.status.CompletedAt = &now
+	p.status.Status = PullCompleted
+	if err != nil {
+		p.status.Status = PullFailed
+		p.status.Error = err.Error()
+	} else if p.status.Total > 0 {
+		p.status.Current = p.status.Total
+	}
+}
+
+// Status returns the current status of the pull.
+func (p *Pull) Status() PullStatus {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.status
+}
+
+// done returns true if the pull has finished.
+func (p *Pull) done() bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.status.CompletedAt != nil
+}
+
+// Pull starts pulling each of the given images in the background, one at a time.
+// Images that are already being pulled are not pulled again.
+func (m *Manager) Pull(images []string) []PullStatus {
+	m.mu.Lock()
+	var queued []*Pull
+	out := make([]PullStatus, 0, len(images))
+	for _, img := range images {
+		img = strings.TrimSpace(img)
+		if img == "" || strings.HasPrefix(img, "~") {
+			continue
+		}
+		if p, ok := m.pulls[img]; ok && !p.done() {
+			out = append(out, p.Status())
+			continue
+		}
+		p := &Pull{status: PullStatus{Image: img, Status: PullPending}, layers: make(map[string]docker.PullProgress)}
+		m.pulls[img] = p
+		queued = append(queued, p)
+		out = append(out, p.Status())
+	}
+	m.mu.Unlock()
+
+	if len(queued) > 0 {
+		go m.pull(queued)
+	}
+	return out
+}
+
+func (m *Manager) pull(pulls []*Pull) {
+	cli, err := environment.Docker()
+	for _, p := range pulls {
+		if err != nil {
+			p.finish(err)
+			continue
+		}
+
+		p.start()
+		img := p.Status().Image
+		ctx, cancel := context.WithTimeout(context.Background(), pullTimeout)
+		perr := docker.PullImage(ctx, cli, img, nil, p.update)
+		cancel()
+		if perr != nil {
+			log.WithField("subsystem", "images").WithField("image", img).WithField("error", perr).Warn("failed to pre-pull image")
+		}
+		p.finish(perr)
+	}
+}
+
+// Pulls returns the status of every image pull that is in progress or finished
+// recently.
+func (m *Manager) Pulls() []PullStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]PullStatus, 0, len(m.pulls))
+	for k, p := range m.pulls {
+		c := p.Status()
+		if c.CompletedAt != nil && time.Since(*c.CompletedAt) > pullRetention {
+			delete(m.pulls, k)
+			continue
+		}
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Image < out[j].Image
+	})
+	return out
+}
+
+// Usage describes the disk used by a single image on the node.
+type Usage struct {
+	ID         string     `json:"id"`
+	Tags       []string   `json:"tags"`
+	Created    time.Time  `json:"created"`
+	Size       int64      `json:"size"`
+	SharedSize int64      `json:"shared_size"`
+	UniqueSize int64      `json:"unique_size"`
+	Containers int64      `json:"containers"`
+	Servers    []string   `json:"servers"`
+	LastUsed   *time.Time `json:"last_used"`
+}
+
+// references returns the UUIDs of the servers using each image, keyed by the
+// normalized image reference. This includes the image used to run the install
+// script of each server, which is retrieved from the Panel.
+func (m *Manager) references(ctx context.Context) (map[string][]string, error) {
+	var mu sync.Mutex
+	refs := make(map[string][]string)
+	add := func(img string, id string) {
+		if img == "" {
+			return
+		}
+		img = docker.NormalizeImage(strings.TrimPrefix(img, "~"))
+		mu.Lock()
+		defer mu.Unlock()
+		if !slices.Contains(refs[img], id) {
+			refs[img] = append(refs[img], id)
+		}
+	}
+
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(4)
+	for _, s := range m.manager.All() {
+		s := s
+		add(s.Config().Container.Image, s.ID())
+		g.Go(func() error {
+			script, err := m.manager.Client().GetInstallationScript(ctx, s.ID())
+			if err != nil {
+				return errors.Wrapf(err, "images: failed to get installation script for server %s", s.ID())
+			}
+			add(script.ContainerImage, s.ID())
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return refs, nil
+}
+
+// Report returns the disk used by every image on the node, along with the servers
+// that use each image.
+func (m *Manager) Report(ctx context.Context) ([]Usage, error) {
+	cli, err := environment.Docker()
+	if err != nil {
+		return nil, err
+	}
+	du, err := cli.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.ImageObject}})
+	if err != nil {
+		return nil, errors.Wrap(err, "images: failed to get image disk usage")
+	}
+
+	refs, err := m.references(ctx)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Usage, 0, len(du.Images))
+	for _, img := range du.Images {
+		out = append(out, usageFor(img, refs))
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Size > out[j].Size
+	})
+	return out, nil
+}
+
+func usageFor(img *image.Summary, refs map[string][]string) Usage {
+	u := Usage{
+		ID:         img.ID,
+		Tags:       img.RepoTags,
+		Created:    time.Unix(img.Created, 0),
+		Size:       img.Size,
+		SharedSize: img.SharedSize,
+		UniqueSize: img.Size,
+		Containers: img.Containers,
+		Servers:    []string{},
+	}
+	if u.Tags == nil {
+		u.Tags = []string{}
+	}
+	if img.SharedSize > 0 {
+		u.UniqueSize = img.Size - img.SharedSize
+	}
+	for _, ref := range append(append([]string{}, img.RepoTags...), img.RepoDigests...) {
+		u.Servers = append(u.Servers, refs[ref]...)
+		if t, ok := docker.ImageLastUsed(ref); ok && (u.LastUsed == nil || t.After(*u.LastUsed)) {
+			u.LastUsed = &t
+		}
+	}
+	return u
+}
+
+// imageClient is the part of the Docker client used to prune images.
+type imageClient interface {
+	DiskUsage(ctx context.Context, options types.DiskUsageOptions) (types.DiskUsage, error)
+	ImageRemove(ctx context.Context, image string, options types.ImageRemoveOptions) ([]image.DeleteResponse, error)
+}
+
+// Prune removes images that are not used by any server or container, have not
+// been used for the configured grace period, and match the configured prefixes.
+// If dryRun is true the images that would be removed are returned without
+// removing them.
+func (m *Manager) Prune(ctx context.Context, dryRun bool) ([]Usage, error) {
+	cli, err := environment.Docker()
+	if err != nil {
+		return nil, err
+	}
+	return m.prune(ctx, cli, dryRun)
+}
+
+func (m *Manager) prune(ctx context.Context, cli imageClient, dryRun bool) ([]Usage, error) {
+	du, err := cli.DiskUsage(ctx, types.DiskUsageOptions{Types: []types.DiskUsageObject{types.ImageObject}})
+	if err != nil {
+		return nil, errors.Wrap(err, "images: failed to get image disk usage")
+	}
+
+	// If the images used by the servers cannot be determined nothing is removed,
+	// since an image that is still in use could be removed otherwise.
+	refs, err := m.references(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := config.Get().Docker.Images
+	grace := time.Duration(cfg.PruneGracePeriod) * time.Hour
+	now := time.Now()
+
+	m.mu.Lock()
+	present := make(map[string]bool, len(du.Images))
+	var candidates []Usage
+	for _, img := range du.Images {
+		present[img.ID] = true
+		u := usageFor(img, refs)
+		if u.Containers > 0 || len(u.Servers) > 0 || (u.LastUsed != nil && now.Sub(*u.LastUsed) < grace) {
+			delete(m.unreferenced, img.ID)
+			continue
+		}
+		if !matchesPrefixes(img, cfg.PrunePrefixes) {
+			continue
+		}
+		since, ok := m.unreferenced[img.ID]
+		if !ok {
+			m.unreferenced[img.ID] = now
+			since = now
+		}
+		if now.Sub(since) < grace {
+			continue
+		}
+		candidates = append(candidates, u)
+	}
+	// Stop tracking images that no longer exist on the system.
+	for id := range m.unreferenced {
+		if !present[id] {
+			delete(m.unreferenced, id)
+		}
+	}
+	m.saveLocked()
+	m.mu.Unlock()
+
+	if dryRun {
+		return candidates, nil
+	}
+
+	removed := make([]Usage, 0, len(candidates))
+	for _, u := range candidates {
+		if err := removeImage(ctx, cli, u); err != nil {
+			log.WithField("subsystem", "images").WithField("image", u.ID).WithField("error", err).Warn("failed to remove unused image")
+			continue
+		}
+		m.mu.Lock()
+		delete(m.unreferenced, u.ID)
+		m.mu.Unlock()
+		removed = append(removed, u)
+	}
+	if len(removed) > 0 {
+		m.mu.Lock()
+		m.saveLocked()
+		m.mu.Unlock()
+	}
+	return removed, nil
+}
+
+// saveLocked stores the state on the disk, logging any error. The caller must
+// hold the lock.
+func (m *Manager) saveLocked() {
+	if err := m.save(); err != nil {
+		log.WithField("subsystem", "images").WithField("error", err).Warn("failed to save image state to disk")
+	}
+}
+
+// removeImage removes each of the tags of the image, and then the image itself.
+// The removal is never forced, so Docker refuses to remove an image that is used
+// by any container, including ones that are not managed by Wings.
+func removeImage(ctx context.Context, cli imageClient, u Usage) error {
+	opts := types.ImageRemoveOptions{PruneChildren: true}
+	for _, tag := range u.Tags {
+		if _, err := cli.ImageRemove(ctx, tag, opts); err != nil && !client.IsErrNotFound(err) {
+			return err
+		}
+	}
+	if _, err := cli.ImageRemove(ctx, u.ID, opts); err != nil && !client.IsErrNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// matchesPrefixes returns true if any of the repositories for the image start with
+// one of the prefixes. No images match if there are no prefixes, and every image
+// matches a prefix of "*". Images without any tags are matched using the repository
+// of their digests.
+func matchesPrefixes(img *image.Summary, prefixes []string) bool {
+	if slices.Contains(prefixes, "*") {
+		return true
+	}
+	for _, ref := range append(append([]string{}, img.RepoTags...), img.RepoDigests...) {
+		for _, p := range prefixes {
+			if p != "" && strings.HasPrefix(ref, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package images
+
+import (
+	"context"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/image"
+	. "github.com/franela/goblin"
+	"github.com/goccy/go-json"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/environment/docker"
+	"github.com/pterodactyl/wings/remote"
+	"github.com/pterodactyl/wings/server"
+)
+
+// testPanel is a Panel client that returns the installation script image for
+// each server.
+type testPanel struct {
+	remote.Client
+	installers map[string]string
+	err        error
+}
+
+func (p *testPanel) GetInstallationScript(_ context.Context, uuid string) (remote.InstallationScript, error) {
+	if p.err != nil {
+		return remote.InstallationScript{}, p.err
+	}
+	return remote.InstallationScript{ContainerImage: p.installers[uuid]}, nil
+}
+
+// testDocker is a Docker client that returns the configured images, and records
+// the images that are removed.
+type testDocker struct {
+	mu      sync.Mutex
+	images  []*image.Summary
+	removed []string
+	// fail is the references that cannot be removed.
+	fail map[string]bool
+}
+
+func (d *testDocker) DiskUsage(_ context.Context, _ types.DiskUsageOptions) (types.DiskUsage, error) {
+	return types.DiskUsage{Images: d.images}, nil
+}
+
+func (d *testDocker) ImageRemove(_ context.Context, ref string, opts types.ImageRemoveOptions) ([]image.DeleteResponse, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if opts.Force {
+		return nil, errors.New("images must not be removed forcefully")
+	}
+	if d.fail[ref] {
+		return nil, errors.New("conflict: unable to remove image")
+	}
+	d.removed = append(d.removed, ref)
+	return nil, nil
+}
+
+// newTestManager returns an image manager for a node with a server using each
+// of the given images.
+func newTestManager(p *testPanel, images map[string]string) *Manager {
+	m := server.NewEmptyManager(p)
+	for id, img := range images {
+		s, err := server.New(p)
+		if err != nil {
+			panic(err)
+		}
+		s.Config().Uuid = id
+		s.Config().Container.Image = img
+		m.Add(s)
+	}
+	return newManager(m)
+}
+
+func TestMatchesPrefixes(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("matchesPrefixes", func() {
+		tagged := &image.Summary{RepoTags: []string{"ghcr.io/pterodactyl/yolks:java_17"}}
+		untagged := &image.Summary{RepoDigests: []string{"ghcr.io/parkervcp/installers@sha256:abc"}}
+
+		for _, tc := range []struct {
+			name     string
+			img      *image.Summary
+			prefixes []string
+			want     bool
+		}{
+			{"no prefixes", tagged, nil, false},
+			{"empty prefix", tagged, []string{""}, false},
+			{"wildcard", tagged, []string{"*"}, true},
+			{"matching prefix", tagged, []string{"docker.io/", "ghcr.io/pterodactyl/"}, true},
+			{"other prefix", tagged, []string{"ghcr.io/parkervcp/"}, false},
+			{"digest of an untagged image", untagged, []string{"ghcr.io/parkervcp/"}, true},
+			{"image without references", &image.Summary{}, []string{"ghcr.io/"}, false},
+		} {
+			tc := tc
+			g.It("returns "+map[bool]string{true: "true", false: "false"}[tc.want]+" for "+tc.name, func() {
+				g.Assert(matchesPrefixes(tc.img, tc.prefixes)).Equal(tc.want)
+			})
+		}
+	})
+}
+
+func TestManager_Prune(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Manager#Prune", func() {
+		var dir string
+		g.BeforeEach(func() {
+			var err error
+			dir, err = os.MkdirTemp(os.TempDir(), "pterodactyl")
+			g.Assert(err).IsNil()
+			config.Set(&config.Configuration{
+				AuthenticationToken: "abc",
+				System:              config.SystemConfiguration{RootDirectory: dir},
+				Docker: config.DockerConfiguration{
+					Images: config.DockerImagesConfiguration{PruneGracePeriod: 0, PrunePrefixes: []string{"ghcr.io/"}},
+				},
+			})
+		})
+		g.AfterEach(func() {
+			_ = os.RemoveAll(dir)
+		})
+
+		g.It("removes images that are not used by any server", func() {
+			p := &testPanel{installers: map[string]string{"server-a": "ghcr.io/installers/java"}}
+			m := newTestManager(p, map[string]string{"server-a": "ghcr.io/yolks/java:17"})
+			cli := &testDocker{images: []*image.Summary{
+				{ID: "sha256:used", RepoTags: []string{"ghcr.io/yolks/java:17"}},
+				{ID: "sha256:installer", RepoTags: []string{"ghcr.io/installers/java:latest"}},
+				{ID: "sha256:container", RepoTags: []string{"ghcr.io/other:latest"}, Containers: 1},
+				{ID: "sha256:unused", RepoTags: []string{"ghcr.io/yolks/java:8", "ghcr.io/yolks/java:legacy"}},
+				{ID: "sha256:prefix", RepoTags: []string{"docker.io/library/alpine:latest"}},
+			}}
+
+			removed, err := m.prune(context.Background(), cli, false)
+			g.Assert(err).IsNil()
+			g.Assert(len(removed)).Equal(1)
+			g.Assert(removed[0].ID).Equal("sha256:unused")
+			g.Assert(cli.removed).Equal([]string{"ghcr.io/yolks/java:8", "ghcr.io/yolks/java:legacy", "sha256:unused"})
+		})
+
+		g.It("does not remove anything during a dry run", func() {
+			m := newTestManager(&testPanel{}, nil)
+			cli := &testDocker{images: []*image.Summary{{ID: "sha256:unused", RepoTags: []string{"ghcr.io/unused:latest"}}}}
+
+			removed, err := m.prune(context.Background(), cli, true)
+			g.Assert(err).IsNil()
+			g.Assert(len(removed)).Equal(1)
+			g.Assert(len(cli.removed)).Equal(0)
+		})
+
+		g.It("does not remove anything if the installation images cannot be retrieved", func() {
+			m := newTestManager(&testPanel{err: errors.New("panel is unreachable")}, map[string]string{"server-a": "ghcr.io/yolks/java:17"})
+			cli := &testDocker{images: []*image.Summary{{ID: "sha256:unused", RepoTags: []string{"ghcr.io/unused:latest"}}}}
+
+			_, err := m.prune(context.Background(), cli, false)
+			g.Assert(err == nil).IsFalse()
+			g.Assert(len(cli.removed)).Equal(0)
+		})
+
+		g.It("keeps images that Docker refuses to remove", func() {
+			m := newTestManager(&testPanel{}, nil)
+			cli := &testDocker{
+				images: []*image.Summary{{ID: "sha256:unused", RepoTags: []string{"ghcr.io/unused:latest"}}},
+				fail:   map[string]bool{"ghcr.io/unused:latest": true},
+			}
+
+			removed, err := m.prune(context.Background(), cli, false)
+			g.Assert(err).IsNil()
+			g.Assert(len(removed)).Equal(0)
+			g.Assert(len(cli.removed)).Equal(0)
+		})
+
+		g.It("only removes images once they have been unused for the grace period", func() {
+			cfg := config.Get()
+			cfg.Docker.Images.PruneGracePeriod = 1
+			config.Set(cfg)
+
+			m := newTestManager(&testPanel{}, nil)
+			cli := &testDocker{images: []*image.Summary{{ID: "sha256:unused", RepoTags: []string{"ghcr.io/unused:latest"}}}}
+
+			removed, err := m.prune(context.Background(), cli, false)
+			g.Assert(err).IsNil()
+			g.Assert(len(removed)).Equal(0)
+
+			m.unreferenced["sha256:unused"] = time.Now().Add(-time.Hour * 2)
+			removed, err = m.prune(context.Background(), cli, false)
+			g.Assert(err).IsNil()
+			g.Assert(len(removed)).Equal(1)
+		})
+
+		g.It("keeps images that were used recently", func() {
+			cfg := config.Get()
+			cfg.Docker.Images.PruneGracePeriod = 1
+			config.Set(cfg)
+
+			docker.MarkImageUsed("ghcr.io/recent:latest")
+			m := newTestManager(&testPanel{}, nil)
+			m.unreferenced["sha256:recent"] = time.Now().Add(-time.Hour * 2)
+			cli := &testDocker{images: []*image.Summary{{ID: "sha256:recent", RepoTags: []string{"ghcr.io/recent:latest"}}}}
+
+			removed, err := m.prune(context.Background(), cli, false)
+			g.Assert(err).IsNil()
+			g.Assert(len(removed)).Equal(0)
+		})
+
+		g.It("restores the grace periods after a restart", func() {
+			cfg := config.Get()
+			cfg.Docker.Images.PruneGracePeriod = 1
+			config.Set(cfg)
+
+			since := time.Now().Add(-time.Minute * 30).Round(0)
+			used := time.Now().Add(-time.Hour * 48).Round(0)
+			docker.SetImageLastUsed("ghcr.io/old:latest", used)
+
+			m := newTestManager(&testPanel{}, nil)
+			m.unreferenced["sha256:unused"] = since
+			cli := &testDocker{images: []*image.Summary{{ID: "sha256:unused", RepoTags: []string{"ghcr.io/unused:latest"}}}}
+			_, err := m.prune(context.Background(), cli, true)
+			g.Assert(err).IsNil()
+
+			b, err := os.ReadFile(config.Get().System.GetImagesStatePath())
+			g.Assert(err).IsNil()
+			var st state
+			g.Assert(json.Unmarshal(b, &st)).IsNil()
+			g.Assert(st.LastUsed["ghcr.io/old:latest"].Equal(used)).IsTrue()
+
+			m2 := newTestManager(&testPanel{}, nil)
+			g.Assert(m2.load()).IsNil()
+			g.Assert(m2.unreferenced["sha256:unused"].Equal(since)).IsTrue()
+
+			// An image used since the state was stored keeps the later time.
+			docker.MarkImageUsed("ghcr.io/old")
+			g.Assert(m2.load()).IsNil()
+			t, ok := docker.ImageLastUsed("ghcr.io/old")
+			g.Assert(ok).IsTrue()
+			g.Assert(t.After(used)).IsTrue()
+		})
+	})
+}
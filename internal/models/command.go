@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Command is a console command that was sent to a server by a user. A fixed number
+// of the most recent commands are retained for each server so that clients are
+// able to restore their input history when reconnecting, and so that commands can
+// be audited locally. Unlike Activity entries these are not purged once they are
+// sent to the Panel.
+type Command struct {
+	ID int `gorm:"primaryKey;not null" json:"-"`
+	// Server is the UUID of the server the command was sent to.
+	Server string `gorm:"type:uuid;index;not null" json:"server"`
+	// User is the UUID of the user that sent the command, or a null value if the
+	// command was sent by the system or the Panel directly.
+	User JsonNullString `gorm:"type:uuid" json:"user"`
+	// Command is the raw command that was written to the server's stdin.
+	Command   string    `gorm:"not null" json:"command"`
+	Timestamp time.Time `gorm:"not null" json:"timestamp"`
+}
+
+// BeforeCreate ensures that the timestamp is set and stored as UTC.
+func (c *Command) BeforeCreate(_ *gorm.DB) error {
+	if c.Timestamp.IsZero() {
+		c.Timestamp = time.Now()
+	}
+	c.Timestamp = c.Timestamp.UTC()
+	return nil
+}
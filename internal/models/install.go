@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Install is a single run of the installation process for a server. A fixed number
+// of the most recent runs are retained for each server so that failed or stuck
+// installations can be debugged after the installer container has been removed.
+type Install struct {
+	ID int `gorm:"primaryKey;not null" json:"id"`
+	// Server is the UUID of the server the installation process was run for.
+	Server string `gorm:"type:uuid;index;not null" json:"server"`
+	// Attempt is the number of the attempt within a single installation, starting
+	// at 1. Runs that fail due to a network error may be retried automatically.
+	Attempt int `gorm:"not null" json:"attempt"`
+	// Image is the container image used to run the installation script.
+	Image string `gorm:"not null" json:"image"`
+	// ExitCode is the exit code of the installation script, or -1 if the script
+	// never finished running.
+	ExitCode   int64  `gorm:"not null" json:"exit_code"`
+	Successful bool   `gorm:"not null" json:"successful"`
+	TimedOut   bool   `gorm:"not null" json:"timed_out"`
+	Error      string `gorm:"not null" json:"error"`
+	// Log is the output of the installation script. Only the end of the output is
+	// stored for scripts that produce a large amount of output.
+	Log string `gorm:"not null" json:"log,omitempty"`
+	// Duration is the time in milliseconds taken by the run.
+	Duration  int64     `gorm:"not null" json:"duration"`
+	Timestamp time.Time `gorm:"not null" json:"timestamp"`
+}
+
+// BeforeCreate ensures that the timestamp is set and stored as UTC.
+func (i *Install) BeforeCreate(_ *gorm.DB) error {
+	if i.Timestamp.IsZero() {
+		i.Timestamp = time.Now()
+	}
+	i.Timestamp = i.Timestamp.UTC()
+	return nil
+}
@@ -0,0 +1,255 @@
+// Package restart allows Wings to be restarted gracefully by starting a new copy
+// of the process that inherits the listening sockets of the running process. The
+// running process continues to serve requests until the new process has finished
+// booting, so that no connections are refused while Wings is upgraded.
+//
+// When Wings is run by systemd the new process must become the main process of the
+// service, otherwise systemd stops the service once the previous process exits. This
+// requires the notification socket to be enabled for the unit, by adding the following
+// to the [Service] section of wings.service and running "systemctl daemon-reload":
+//
+//	NotifyAccess=main
+//
+// Restarting is refused when running under systemd without the notification socket.
+package restart
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+)
+
+// The environment variables used to pass the inherited listeners and the pipe used
+// to signal that the new process is ready to the new process.
+const (
+	envListeners = "WINGS_INHERITED_LISTENERS"
+	envReady     = "WINGS_RESTART_READY_FD"
+)
+
+// The amount of time to wait for the new process to finish booting, the amount of
+// time the previous process is given to finish the requests in progress, and the
+// amount of time it then waits for server tasks such as backups to finish before
+// it exits.
+const (
+	readyTimeout = time.Minute * 5
+	DrainTimeout = time.Minute
+	TaskTimeout  = time.Minute * 30
+)
+
+const ErrNotifyUnavailable = errors.Sentinel("restart: running under systemd without NotifyAccess= set for the unit, the new process would be stopped with the service")
+
+type filer interface {
+	File() (*os.File, error)
+}
+
+var (
+	mu        sync.Mutex
+	listeners = make(map[string]net.Listener)
+	inherited map[string]*os.File
+	once      sync.Once
+)
+
+// Listen returns a TCP listener for the address. If the process was started by a
+// graceful restart the listener with the same name inherited from the previous
+// process is used, otherwise a new listener is created. The listener is passed on
+// to the next process when it is restarted.
+func Listen(name string, addr string) (net.Listener, error) {
+	once.Do(parseInherited)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if f, ok := inherited[name]; ok {
+		delete(inherited, name)
+		l, err := net.FileListener(f)
+		_ = f.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "restart: failed to use inherited listener")
+		}
+		if sameAddress(l.Addr(), addr) {
+			log.WithFields(log.Fields{"listener": name, "address": addr}).Debug("restart: using listener inherited from previous process")
+			listeners[name] = l
+			return l, nil
+		}
+		// The address was changed before restarting, so the inherited listener can't
+		// be used.
+		_ = l.Close()
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	listeners[name] = l
+	return l, nil
+}
+
+// Ready notifies the previous process that this process has finished booting and
+// is accepting connections, after which the previous process stops accepting
+// connections and exits. Does nothing if the process was not started by a graceful
+// restart.
+func Ready() {
+	v := os.Getenv(envReady)
+	if v == "" {
+		return
+	}
+	_ = os.Unsetenv(envReady)
+	fd, err := strconv.Atoi(v)
+	if err != nil {
+		return
+	}
+	f := os.NewFile(uintptr(fd), "restart-ready")
+	if _, err := f.Write([]byte{1}); err != nil {
+		log.WithField("error", err).Warn("restart: failed to notify previous process that boot has completed")
+	}
+	_ = f.Close()
+}
+
+// Start starts a new copy of the process which inherits all the listeners created
+// using Listen, and waits for it to finish booting. Once this returns without an
+// error the current process must stop accepting connections, finish the requests
+// in progress and exit. If an error is returned the current process should keep
+// running.
+func Start() error {
+	systemd := os.Getenv("INVOCATION_ID") != ""
+	if systemd && os.Getenv("NOTIFY_SOCKET") == "" {
+		return errors.WithStack(ErrNotifyUnavailable)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return errors.Wrap(err, "restart: failed to find executable")
+	}
+
+	mu.Lock()
+	var files []*os.File
+	var spec []string
+	for name, l := range listeners {
+		fl, ok := l.(filer)
+		if !ok {
+			continue
+		}
+		f, err := fl.File()
+		if err != nil {
+			mu.Unlock()
+			return errors.Wrap(err, "restart: failed to get file for listener")
+		}
+		// Files passed to the new process are numbered starting at 3, after stdin,
+		// stdout and stderr.
+		spec = append(spec, fmt.Sprintf("%s:%d", name, 3+len(files)))
+		files = append(files, f)
+	}
+	mu.Unlock()
+	defer func() {
+		for _, f := range files {
+			_ = f.Close()
+		}
+	}()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer r.Close()
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(files, w)
+	cmd.Env = append(os.Environ(), envListeners+"="+strings.Join(spec, ","), fmt.Sprintf("%s=%d", envReady, 3+len(files)))
+	err = cmd.Start()
+	_ = w.Close()
+	if err != nil {
+		return errors.Wrap(err, "restart: failed to start new process")
+	}
+	log.WithField("pid", cmd.Process.Pid).Info("restart: started new process, waiting for it to finish booting")
+
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+	ready := make(chan error, 1)
+	go func() {
+		_, err := r.Read(make([]byte, 1))
+		ready <- err
+	}()
+
+	select {
+	case err := <-ready:
+		if err != nil {
+			_ = cmd.Process.Kill()
+			return errors.Wrap(err, "restart: new process did not finish booting")
+		}
+	case err := <-exited:
+		return errors.Wrap(err, "restart: new process exited before it finished booting")
+	case <-time.After(readyTimeout):
+		_ = cmd.Process.Kill()
+		return errors.New("restart: timed out waiting for new process to finish booting")
+	}
+
+	// Let systemd know that the new process is now the main process of the service,
+	// otherwise it would be stopped once this process exits. This requires the unit to
+	// be configured with NotifyAccess=main or NotifyAccess=all. If systemd cannot be
+	// told, the new process is stopped again since systemd would stop it anyway.
+	if err := notify("MAINPID=" + strconv.Itoa(cmd.Process.Pid)); err != nil && systemd {
+		_ = cmd.Process.Kill()
+		return errors.Wrap(err, "restart: failed to notify systemd of the new main process")
+	}
+	return nil
+}
+
+// parseInherited parses the listeners inherited from the previous process.
+func parseInherited() {
+	inherited = make(map[string]*os.File)
+	v := os.Getenv(envListeners)
+	if v == "" {
+		return
+	}
+	_ = os.Unsetenv(envListeners)
+	for _, s := range strings.Split(v, ",") {
+		name, fd, ok := strings.Cut(s, ":")
+		n, err := strconv.Atoi(fd)
+		if !ok || err != nil {
+			continue
+		}
+		inherited[name] = os.NewFile(uintptr(n), "listener-"+name)
+	}
+}
+
+// sameAddress returns true if the listener is bound to the address.
+func sameAddress(a net.Addr, addr string) bool {
+	want, err := net.ResolveTCPAddr("tcp", addr)
+	if err != nil {
+		return false
+	}
+	got, ok := a.(*net.TCPAddr)
+	if !ok || got.Port != want.Port {
+		return false
+	}
+	if want.IP == nil || want.IP.IsUnspecified() {
+		return got.IP == nil || got.IP.IsUnspecified()
+	}
+	return got.IP.Equal(want.IP)
+}
+
+// notify sends the state to the systemd notification socket, if there is one.
+func notify(state string) error {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte(state))
+	return errors.WithStack(err)
+}
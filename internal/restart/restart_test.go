@@ -0,0 +1,73 @@
+package restart
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+
+	"emperror.dev/errors"
+	. "github.com/franela/goblin"
+)
+
+func TestListen(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Listen", func() {
+		var original net.Listener
+
+		g.BeforeEach(func() {
+			var err error
+			original, err = net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				panic(err)
+			}
+			f, err := original.(*net.TCPListener).File()
+			if err != nil {
+				panic(err)
+			}
+			_ = os.Setenv(envListeners, "http:"+strconv.Itoa(int(f.Fd())))
+			once = sync.Once{}
+		})
+
+		g.AfterEach(func() {
+			_ = original.Close()
+		})
+
+		g.It("uses the inherited listener for the same address", func() {
+			l, err := Listen("http", original.Addr().String())
+			g.Assert(err).IsNil()
+			defer l.Close()
+			g.Assert(l.Addr().String()).Equal(original.Addr().String())
+			g.Assert(os.Getenv(envListeners)).Equal("")
+		})
+
+		g.It("creates a new listener if the address has changed", func() {
+			l, err := Listen("http", "127.0.0.1:0")
+			g.Assert(err).IsNil()
+			defer l.Close()
+			g.Assert(l.Addr().String() == original.Addr().String()).IsFalse()
+		})
+
+		g.It("creates a new listener if none was inherited", func() {
+			l, err := Listen("sftp", "127.0.0.1:0")
+			g.Assert(err).IsNil()
+			defer l.Close()
+		})
+	})
+}
+
+func TestStart(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Start", func() {
+		g.It("refuses to restart under systemd without a notification socket", func() {
+			t.Setenv("INVOCATION_ID", "abc")
+			t.Setenv("NOTIFY_SOCKET", "")
+
+			err := Start()
+			g.Assert(errors.Is(err, ErrNotifyUnavailable)).IsTrue()
+		})
+	})
+}
@@ -0,0 +1,236 @@
+// Package templates provides the installation templates unpacked into a server's
+// data directory, caching templates downloaded from a URL on the node so they are
+// only downloaded once.
+package templates
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/remote"
+)
+
+const (
+	ErrInvalidTemplate  = errors.Sentinel("templates: invalid installation template")
+	ErrChecksumMismatch = errors.Sentinel("templates: checksum of installation template does not match")
+)
+
+var client = &http.Client{Timeout: time.Hour * 2}
+
+// Locks for each template in the cache, preventing the same template from being
+// downloaded multiple times when many servers using it are installed at once.
+var (
+	mu    sync.Mutex
+	locks = make(map[string]*sync.Mutex)
+)
+
+// Open opens the template, downloading it to the cache first if it is stored at a
+// URL. Returns the file along with its name, which is used to identify the format
+// of the archive. The caller is responsible for closing the file.
+func Open(ctx context.Context, t *remote.InstallTemplate) (*os.File, string, error) {
+	if t.Name != "" {
+		f, err := openLocal(t)
+		return f, t.Name, err
+	}
+	u, err := url.Parse(t.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, "", errors.Wrap(ErrInvalidTemplate, "templates: template must have a name or a valid URL")
+	}
+	f, err := openCached(ctx, t)
+	return f, path.Base(u.Path), err
+}
+
+// openLocal opens a template stored in the node's template directory, verifying
+// its checksum if one was provided.
+func openLocal(t *remote.InstallTemplate) (*os.File, error) {
+	if t.Name != filepath.Base(t.Name) || strings.HasPrefix(t.Name, ".") {
+		return nil, errors.Wrap(ErrInvalidTemplate, "templates: template name must be a file name")
+	}
+	f, err := os.Open(filepath.Join(config.Get().System.InstallTemplates.Directory, t.Name))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if t.Checksum == "" {
+		return f, nil
+	}
+	if err := verify(f, checksum(t)); err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		_ = f.Close()
+		return nil, errors.WithStack(err)
+	}
+	return f, nil
+}
+
+// openCached opens a template from the cache, downloading it if it has not been
+// cached yet. Templates in the cache are stored using their checksum as the name,
+// and their modification time is updated whenever they are used.
+func openCached(ctx context.Context, t *remote.InstallTemplate) (*os.File, error) {
+	sum := checksum(t)
+	if b, err := hex.DecodeString(sum); err != nil || len(b) != sha256.Size {
+		return nil, errors.Wrap(ErrInvalidTemplate, "templates: a SHA-256 checksum is required for templates downloaded from a URL")
+	}
+
+	dir := config.Get().System.InstallTemplates.CacheDirectory
+	p := filepath.Join(dir, sum)
+
+	l := lock(sum)
+	l.Lock()
+	defer l.Unlock()
+
+	f, err := os.Open(p)
+	if err == nil {
+		now := time.Now()
+		_ = os.Chtimes(p, now, now)
+		return f, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, errors.WithStack(err)
+	}
+
+	log.WithFields(log.Fields{"url": t.URL, "checksum": sum}).Info("downloading installation template to cache")
+	if err := download(ctx, t.URL, dir, p, sum); err != nil {
+		return nil, err
+	}
+	if f, err = os.Open(p); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// Evict after opening the template so that it remains readable even if the
+	// cache is too small to keep it.
+	if err := Evict(); err != nil {
+		log.WithField("error", err).Warn("failed to evict installation templates from cache")
+	}
+	return f, nil
+}
+
+// download downloads the template to the given path, removing it again if its
+// checksum does not match.
+func download(ctx context.Context, u string, dir string, p string, sum string) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return errors.WithStack(err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return errors.WrapIf(err, "templates: failed to create request")
+	}
+	req.Header.Set("User-Agent", "Pterodactyl Panel (https://pterodactyl.io)")
+	res, err := client.Do(req)
+	if err != nil {
+		return errors.WrapIf(err, "templates: failed to download template")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return errors.New("templates: got bad response status from endpoint: " + res.Status)
+	}
+
+	// Templates are downloaded to a hidden file first, so a partial download is never
+	// mistaken for a cached template.
+	tmp, err := os.CreateTemp(dir, ".download-*")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.Remove(tmp.Name())
+
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, h), res.Body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return errors.WrapIf(err, "templates: failed to download template")
+	}
+	if hex.EncodeToString(h.Sum(nil)) != sum {
+		return errors.WithStack(ErrChecksumMismatch)
+	}
+	return errors.WithStack(os.Rename(tmp.Name(), p))
+}
+
+// Evict removes the least recently used templates from the cache until it is no
+// larger than the configured cache size.
+func Evict() error {
+	cfg := config.Get().System.InstallTemplates
+	entries, err := os.ReadDir(cfg.CacheDirectory)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.WithStack(err)
+	}
+
+	var total int64
+	files := make([]os.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, info)
+		total += info.Size()
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+
+	limit := cfg.CacheSize * 1024 * 1024
+	for _, f := range files {
+		if total <= limit {
+			break
+		}
+		log.WithFields(log.Fields{"checksum": f.Name(), "size": f.Size()}).Debug("evicting installation template from cache")
+		if err := os.Remove(filepath.Join(cfg.CacheDirectory, f.Name())); err != nil && !os.IsNotExist(err) {
+			return errors.WithStack(err)
+		}
+		total -= f.Size()
+	}
+	return nil
+}
+
+// verify checks that the SHA-256 checksum of the contents of the reader matches
+// the expected checksum.
+func verify(r io.Reader, sum string) error {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return errors.WithStack(err)
+	}
+	if hex.EncodeToString(h.Sum(nil)) != sum {
+		return errors.WithStack(ErrChecksumMismatch)
+	}
+	return nil
+}
+
+// checksum returns the checksum of the template in lowercase, without the optional
+// "sha256:" prefix.
+func checksum(t *remote.InstallTemplate) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(t.Checksum)), "sha256:")
+}
+
+func lock(sum string) *sync.Mutex {
+	mu.Lock()
+	defer mu.Unlock()
+	l, ok := locks[sum]
+	if !ok {
+		l = &sync.Mutex{}
+		locks[sum] = l
+	}
+	return l
+}
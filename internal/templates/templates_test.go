@@ -0,0 +1,116 @@
+package templates
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/remote"
+)
+
+func TestOpen(t *testing.T) {
+	g := Goblin(t)
+
+	body := []byte("template contents")
+	h := sha256.Sum256(body)
+	sum := hex.EncodeToString(h[:])
+
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+
+	g.Describe("Open", func() {
+		var dir string
+
+		g.BeforeEach(func() {
+			dir = t.TempDir()
+			requests.Store(0)
+			config.Set(&config.Configuration{
+				AuthenticationToken: "abc",
+				System: config.SystemConfiguration{
+					InstallTemplates: config.InstallTemplates{
+						Directory:      dir,
+						CacheDirectory: filepath.Join(dir, ".cache"),
+						CacheSize:      1,
+					},
+				},
+			})
+		})
+
+		g.It("opens a template stored on the node", func() {
+			_ = os.WriteFile(filepath.Join(dir, "modpack.zip"), body, 0o644)
+
+			f, name, err := Open(ctx, &remote.InstallTemplate{Name: "modpack.zip", Checksum: "sha256:" + sum})
+			g.Assert(err).IsNil()
+			defer f.Close()
+			b, _ := io.ReadAll(f)
+			g.Assert(name).Equal("modpack.zip")
+			g.Assert(b).Equal(body)
+		})
+
+		g.It("rejects names outside of the template directory", func() {
+			_, _, err := Open(ctx, &remote.InstallTemplate{Name: "../modpack.zip"})
+			g.Assert(errors.Is(err, ErrInvalidTemplate)).IsTrue()
+		})
+
+		g.It("downloads a template only once", func() {
+			for i := 0; i < 2; i++ {
+				f, name, err := Open(ctx, &remote.InstallTemplate{URL: srv.URL + "/modpack.tar.gz", Checksum: sum})
+				g.Assert(err).IsNil()
+				b, _ := io.ReadAll(f)
+				_ = f.Close()
+				g.Assert(name).Equal("modpack.tar.gz")
+				g.Assert(b).Equal(body)
+			}
+			g.Assert(requests.Load()).Equal(int64(1))
+		})
+
+		g.It("requires a checksum for downloaded templates", func() {
+			_, _, err := Open(ctx, &remote.InstallTemplate{URL: srv.URL + "/modpack.tar.gz"})
+			g.Assert(errors.Is(err, ErrInvalidTemplate)).IsTrue()
+		})
+
+		g.It("does not cache a template with a mismatched checksum", func() {
+			bad := sha256.Sum256([]byte("other"))
+			_, _, err := Open(ctx, &remote.InstallTemplate{URL: srv.URL + "/modpack.tar.gz", Checksum: hex.EncodeToString(bad[:])})
+			g.Assert(errors.Is(err, ErrChecksumMismatch)).IsTrue()
+
+			entries, _ := os.ReadDir(filepath.Join(dir, ".cache"))
+			g.Assert(len(entries)).Equal(0)
+		})
+
+		g.It("evicts the least recently used templates", func() {
+			cache := filepath.Join(dir, ".cache")
+			_ = os.MkdirAll(cache, 0o700)
+			for i, name := range []string{"old", "new"} {
+				p := filepath.Join(cache, name)
+				_ = os.WriteFile(p, make([]byte, 768*1024), 0o600)
+				mod := time.Now().Add(time.Duration(i-2) * time.Hour)
+				_ = os.Chtimes(p, mod, mod)
+			}
+
+			g.Assert(Evict()).IsNil()
+			_, err := os.Stat(filepath.Join(cache, "old"))
+			g.Assert(os.IsNotExist(err)).IsTrue()
+			_, err = os.Stat(filepath.Join(cache, "new"))
+			g.Assert(err).IsNil()
+		})
+	})
+}
@@ -0,0 +1,57 @@
+// Package json implements a log handler that writes each entry as a single line of
+// JSON, for use with log aggregation systems that cannot parse the CLI output.
+package json
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/apex/log"
+	gojson "github.com/goccy/go-json"
+)
+
+type Handler struct {
+	mu     sync.Mutex
+	Writer io.Writer
+}
+
+func New(w io.Writer) *Handler {
+	return &Handler{Writer: w}
+}
+
+// HandleLog implements log.Handler. The fields of the entry are written at the top
+// level of the object alongside the timestamp, level and message. Errors are
+// written using their message.
+func (h *Handler) HandleLog(e *log.Entry) error {
+	m := make(map[string]interface{}, len(e.Fields)+3)
+	for k, v := range e.Fields {
+		if err, ok := v.(error); ok {
+			v = err.Error()
+		}
+		m[k] = v
+	}
+	m["timestamp"] = e.Timestamp.Format(time.RFC3339Nano)
+	m["level"] = e.Level.String()
+	m["message"] = e.Message
+
+	b, err := gojson.Marshal(m)
+	if err != nil {
+		// Fall back to the string representation of every field if any of them cannot
+		// be encoded, rather than losing the entry entirely.
+		for k, v := range e.Fields {
+			if _, ok := m[k].(string); !ok {
+				m[k] = fmt.Sprint(v)
+			}
+		}
+		if b, err = gojson.Marshal(m); err != nil {
+			return err
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err = h.Writer.Write(append(b, '\n'))
+	return err
+}
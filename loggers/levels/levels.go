@@ -0,0 +1,43 @@
+// Package levels implements a log handler that filters entries using a different
+// minimum level for each subsystem, identified by the "subsystem" field of an entry.
+package levels
+
+import (
+	"github.com/apex/log"
+)
+
+type Handler struct {
+	Handler    log.Handler
+	Default    log.Level
+	Subsystems map[string]log.Level
+}
+
+func New(h log.Handler, def log.Level, subsystems map[string]log.Level) *Handler {
+	return &Handler{Handler: h, Default: def, Subsystems: subsystems}
+}
+
+// HandleLog implements log.Handler.
+func (h *Handler) HandleLog(e *log.Entry) error {
+	level := h.Default
+	if s, ok := e.Fields["subsystem"].(string); ok {
+		if l, ok := h.Subsystems[s]; ok {
+			level = l
+		}
+	}
+	if e.Level < level {
+		return nil
+	}
+	return h.Handler.HandleLog(e)
+}
+
+// MinLevel returns the lowest level of any subsystem. The level of the logger must
+// be set to this, otherwise entries are discarded before reaching the handler.
+func (h *Handler) MinLevel() log.Level {
+	min := h.Default
+	for _, l := range h.Subsystems {
+		if l < min {
+			min = l
+		}
+	}
+	return min
+}
@@ -0,0 +1,49 @@
+package levels
+
+import (
+	"testing"
+
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
+	. "github.com/franela/goblin"
+)
+
+func TestHandler(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Handler", func() {
+		var m *memory.Handler
+		var h *Handler
+
+		g.BeforeEach(func() {
+			m = memory.New()
+			h = New(m, log.InfoLevel, map[string]log.Level{"sftp": log.DebugLevel, "cron": log.ErrorLevel})
+		})
+
+		logger := func(subsystem string) log.Interface {
+			l := &log.Logger{Handler: h, Level: h.MinLevel()}
+			if subsystem == "" {
+				return l
+			}
+			return l.WithField("subsystem", subsystem)
+		}
+
+		g.It("uses the default level for entries without a subsystem", func() {
+			logger("").Debug("test")
+			logger("").Info("test")
+			g.Assert(len(m.Entries)).Equal(1)
+		})
+
+		g.It("uses the level configured for the subsystem", func() {
+			logger("sftp").Debug("test")
+			logger("cron").Warn("test")
+			logger("backup").Debug("test")
+			g.Assert(len(m.Entries)).Equal(1)
+			g.Assert(m.Entries[0].Fields["subsystem"]).Equal("sftp")
+		})
+
+		g.It("returns the lowest configured level", func() {
+			g.Assert(h.MinLevel()).Equal(log.DebugLevel)
+		})
+	})
+}
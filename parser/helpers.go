@@ -2,7 +2,9 @@ package parser
 
 import (
 	"bytes"
+	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -10,7 +12,10 @@ import (
 	"github.com/Jeffail/gabs/v2"
 	"github.com/apex/log"
 	"github.com/buger/jsonparser"
+	"github.com/goccy/go-json"
 	"github.com/iancoleman/strcase"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pelletier/go-toml/v2/unstable"
 )
 
 // Regex to match anything that has a value matching the format of {{ config.$1 }} which
@@ -252,3 +257,301 @@ func (f *ConfigurationFile) LookupConfigurationValue(cfr ConfigurationFileReplac
 		return configMatchRegex.ReplaceAllString(cfr.ReplaceWith.String(), string(match)), nil
 	}
 }
+
+// Checks the current value of a key against the IfValue defined for the replacement
+// and returns the value that should be set, along with a boolean indicating if the
+// replacement should be performed at all. This follows the same rules as
+// SetAtPathway: an empty IfValue always replaces, a "regex:" prefixed IfValue
+// performs a regex replacement on the current value, and any other value must
+// match the current value exactly.
+func (cfr *ConfigurationFileReplacement) matchValue(current string, value string) (string, bool) {
+	if cfr.IfValue == "" {
+		return value, true
+	}
+
+	if strings.HasPrefix(cfr.IfValue, "regex:") {
+		r, err := regexp.Compile(strings.TrimPrefix(cfr.IfValue, "regex:"))
+		if err != nil {
+			log.WithFields(log.Fields{"if_value": strings.TrimPrefix(cfr.IfValue, "regex:"), "error": err}).
+				Warn("configuration if_value using invalid regexp, cannot perform replacement")
+			return "", false
+		}
+		if r.MatchString(current) {
+			return r.ReplaceAllString(current, value), true
+		}
+		return "", false
+	}
+
+	return value, current == cfr.IfValue
+}
+
+// Walks the data that was updated by IterateOverJson and replaces any values that
+// are identical to the original toml data with the original value. This restores
+// the integer, float and date types that are lost when converting toml into JSON.
+// Replacement values are also converted into floats if they replace a float value.
+func restoreTomlTypes(orig interface{}, updated interface{}) interface{} {
+	switch u := updated.(type) {
+	case map[string]interface{}:
+		o, _ := orig.(map[string]interface{})
+		for k, v := range u {
+			if ov, ok := o[k]; ok {
+				u[k] = restoreTomlTypes(ov, v)
+			}
+		}
+		return u
+	case []interface{}:
+		o, _ := orig.([]interface{})
+		for i, v := range u {
+			if i < len(o) {
+				u[i] = restoreTomlTypes(o[i], v)
+			}
+		}
+		return u
+	}
+
+	ob, err := json.Marshal(orig)
+	if err != nil {
+		return updated
+	}
+	ub, err := json.Marshal(updated)
+	if err != nil {
+		return updated
+	}
+	if bytes.Equal(ob, ub) {
+		return orig
+	}
+
+	if _, ok := orig.(float64); ok {
+		switch v := updated.(type) {
+		case int:
+			return float64(v)
+		case string:
+			if fv, err := strconv.ParseFloat(v, 64); err == nil {
+				return fv
+			}
+		}
+	}
+
+	return updated
+}
+
+// envLine is a single KEY=value line in an env file.
+type envLine struct {
+	export  bool
+	key     string
+	value   string
+	quote   byte
+	comment string
+}
+
+// Parses a single line of an env file. Lines that are empty, comments, or do not
+// contain a key/value pair return false.
+func parseEnvLine(line string) (envLine, bool) {
+	t := strings.TrimSpace(line)
+	if t == "" || t[0] == '#' {
+		return envLine{}, false
+	}
+
+	var l envLine
+	if strings.HasPrefix(t, "export ") {
+		l.export = true
+		t = strings.TrimSpace(strings.TrimPrefix(t, "export "))
+	}
+
+	k, v, ok := strings.Cut(t, "=")
+	if !ok {
+		return envLine{}, false
+	}
+	l.key = strings.TrimSpace(k)
+	v = strings.TrimSpace(v)
+
+	if len(v) > 0 && (v[0] == '"' || v[0] == '\'') {
+		q := v[0]
+		for i := 1; i < len(v); i++ {
+			if q == '"' && v[i] == '\\' {
+				i++
+				continue
+			}
+			if v[i] == q {
+				l.quote = q
+				l.value = v[1:i]
+				if q == '"' {
+					l.value = unescapeEnvValue(l.value)
+				}
+				l.comment = v[i+1:]
+				return l, true
+			}
+		}
+	}
+
+	// Unquoted values end at the first whitespace preceded comment marker.
+	if i := strings.Index(v, " #"); i >= 0 {
+		l.comment = v[i:]
+		v = strings.TrimSpace(v[:i])
+	}
+	l.value = v
+
+	return l, true
+}
+
+// String returns the line as it should be written to the env file, quoting the
+// value if required.
+func (l envLine) String() string {
+	var b strings.Builder
+	if l.export {
+		b.WriteString("export ")
+	}
+	b.WriteString(l.key)
+	b.WriteByte('=')
+
+	q := l.quote
+	if q == 0 && strings.ContainsAny(l.value, " \t\n#'\"\\") {
+		q = '"'
+	}
+	if q == '\'' && strings.ContainsAny(l.value, "'\n") {
+		q = '"'
+	}
+
+	switch q {
+	case '"':
+		b.WriteByte('"')
+		b.WriteString(escapeEnvValue(l.value))
+		b.WriteByte('"')
+	case '\'':
+		b.WriteByte('\'')
+		b.WriteString(l.value)
+		b.WriteByte('\'')
+	default:
+		b.WriteString(l.value)
+	}
+	b.WriteString(l.comment)
+
+	return b.String()
+}
+
+var envEscaper = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\n", "\\n")
+
+var envUnescaper = strings.NewReplacer("\\\\", "\\", "\\\"", "\"", "\\n", "\n")
+
+func escapeEnvValue(v string) string {
+	return envEscaper.Replace(v)
+}
+
+func unescapeEnvValue(v string) string {
+	return envUnescaper.Replace(v)
+}
+
+// tomlChange is a value in a toml document that was changed by a replacement.
+type tomlChange struct {
+	path  []string
+	value interface{}
+}
+
+// tomlChanges returns every value in the updated toml data that differs from the
+// original data.
+func tomlChanges(path []string, orig interface{}, updated interface{}) []tomlChange {
+	u, uok := updated.(map[string]interface{})
+	o, ook := orig.(map[string]interface{})
+	if uok && ook {
+		var out []tomlChange
+		for k, v := range u {
+			out = append(out, tomlChanges(append(path[:len(path):len(path)], k), o[k], v)...)
+		}
+		return out
+	}
+	if reflect.DeepEqual(orig, updated) {
+		return nil
+	}
+	return []tomlChange{{path: path, value: updated}}
+}
+
+// editToml applies the changed values to the original toml document without
+// rewriting the rest of it, so that comments, formatting and the order of the keys
+// are kept. False is returned if any value cannot be changed in place, such as a
+// key that does not exist in the document yet, an array or an inline table.
+func editToml(b []byte, changes []tomlChange) ([]byte, bool) {
+	type span struct {
+		start, end int
+	}
+
+	spans := make(map[string]span)
+	p := unstable.Parser{}
+	p.Reset(b)
+	var table []string
+	var inArray bool
+	for p.NextExpression() {
+		e := p.Expression()
+		switch e.Kind {
+		case unstable.Table, unstable.ArrayTable:
+			table = tomlKey(e.Key())
+			inArray = e.Kind == unstable.ArrayTable
+		case unstable.KeyValue:
+			if inArray {
+				continue
+			}
+			v := e.Value()
+			r := v.Raw
+			switch v.Kind {
+			case unstable.String, unstable.Integer, unstable.Float:
+			case unstable.Bool, unstable.LocalDate, unstable.LocalTime, unstable.LocalDateTime, unstable.DateTime:
+				r = p.Range(v.Data)
+			default:
+				continue
+			}
+			key := append(table[:len(table):len(table)], tomlKey(e.Key())...)
+			spans[strings.Join(key, "\x00")] = span{int(r.Offset), int(r.Offset + r.Length)}
+		}
+	}
+	if p.Error() != nil {
+		return nil, false
+	}
+
+	type edit struct {
+		span
+		value []byte
+	}
+	edits := make([]edit, 0, len(changes))
+	for _, c := range changes {
+		s, ok := spans[strings.Join(c.path, "\x00")]
+		if !ok {
+			return nil, false
+		}
+		v, ok := tomlValue(c.value)
+		if !ok {
+			return nil, false
+		}
+		edits = append(edits, edit{s, v})
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		return edits[i].start > edits[j].start
+	})
+
+	out := bytes.Clone(b)
+	for _, e := range edits {
+		out = append(out[:e.start], append(e.value, out[e.end:]...)...)
+	}
+	return out, true
+}
+
+// tomlKey returns the parts of a dotted toml key.
+func tomlKey(it unstable.Iterator) []string {
+	var key []string
+	for it.Next() {
+		key = append(key, string(it.Node().Data))
+	}
+	return key
+}
+
+// tomlValue encodes a single value as toml, returning false if the value cannot
+// be written on a single line.
+func tomlValue(v interface{}) ([]byte, bool) {
+	b, err := toml.Marshal(map[string]interface{}{"v": v})
+	if err != nil {
+		return nil, false
+	}
+	b = bytes.TrimSuffix(b, []byte("\n"))
+	if !bytes.HasPrefix(b, []byte("v = ")) || bytes.ContainsRune(b, '\n') {
+		return nil, false
+	}
+	return b[len("v = "):], true
+}
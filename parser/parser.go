@@ -14,6 +14,7 @@ import (
 	"github.com/goccy/go-json"
 	"github.com/icza/dyno"
 	"github.com/magiconair/properties"
+	"github.com/pelletier/go-toml/v2"
 	"gopkg.in/ini.v1"
 	"gopkg.in/yaml.v3"
 
@@ -29,6 +30,8 @@ const (
 	Ini        = "ini"
 	Json       = "json"
 	Xml        = "xml"
+	Toml       = "toml"
+	Env        = "env"
 )
 
 type ReplaceValue struct {
@@ -123,6 +126,12 @@ func (f *ConfigurationFile) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
+	for _, k := range []string{"file", "parser"} {
+		if m[k] == nil {
+			return errors.Errorf("parser: configuration file is missing the \"%s\" key", k)
+		}
+	}
+
 	if err := json.Unmarshal(*m["file"], &f.FileName); err != nil {
 		return err
 	}
@@ -131,10 +140,13 @@ func (f *ConfigurationFile) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
-	if err := json.Unmarshal(*m["replace"], &f.Replace); err != nil {
-		log.WithField("file", f.FileName).WithField("error", err).Warn("failed to unmarshal configuration file replacement")
+	f.Replace = []ConfigurationFileReplacement{}
+	if m["replace"] != nil {
+		if err := json.Unmarshal(*m["replace"], &f.Replace); err != nil {
+			log.WithField("file", f.FileName).WithField("error", err).Warn("failed to unmarshal configuration file replacement")
 
-		f.Replace = []ConfigurationFileReplacement{}
+			f.Replace = []ConfigurationFileReplacement{}
+		}
 	}
 
 	return nil
@@ -188,9 +200,21 @@ func (cfr *ConfigurationFileReplacement) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// parserFile is the subset of file operations used by the individual parsers.
+// This allows the parsers to operate on an in-memory copy of a file when
+// previewing the changes that would be made to it.
+type parserFile interface {
+	io.ReadWriteSeeker
+	Truncate(size int64) error
+}
+
 // Parse parses a given configuration file and updates all the values within
 // as defined in the API response from the Panel.
 func (f *ConfigurationFile) Parse(file ufs.File) error {
+	return f.parse(file)
+}
+
+func (f *ConfigurationFile) parse(file parserFile) error {
 	//log.WithField("path", path).WithField("parser", f.Parser.String()).Debug("parsing server configuration file")
 
 	// What the fuck is going on here?
@@ -215,12 +239,16 @@ func (f *ConfigurationFile) Parse(file ufs.File) error {
 		err = f.parseIniFile(file)
 	case Xml:
 		err = f.parseXmlFile(file)
+	case Toml:
+		err = f.parseTomlFile(file)
+	case Env, "dotenv":
+		err = f.parseEnvFile(file)
 	}
 	return err
 }
 
 // Parses an xml file.
-func (f *ConfigurationFile) parseXmlFile(file ufs.File) error {
+func (f *ConfigurationFile) parseXmlFile(file parserFile) error {
 	doc := etree.NewDocument()
 	if _, err := doc.ReadFrom(file); err != nil {
 		return err
@@ -299,7 +327,7 @@ func (f *ConfigurationFile) parseXmlFile(file ufs.File) error {
 }
 
 // Parses an ini file.
-func (f *ConfigurationFile) parseIniFile(file ufs.File) error {
+func (f *ConfigurationFile) parseIniFile(file parserFile) error {
 	// Wrap the file in a NopCloser so the ini package doesn't close the file.
 	cfg, err := ini.Load(io.NopCloser(file))
 	if err != nil {
@@ -307,29 +335,7 @@ func (f *ConfigurationFile) parseIniFile(file ufs.File) error {
 	}
 
 	for _, replacement := range f.Replace {
-		var (
-			path         []string
-			bracketDepth int
-			v            []int32
-		)
-		for _, c := range replacement.Match {
-			switch c {
-			case '[':
-				bracketDepth++
-			case ']':
-				bracketDepth--
-			case '.':
-				if bracketDepth > 0 || len(path) == 1 {
-					v = append(v, c)
-					continue
-				}
-				path = append(path, string(v))
-				v = v[:0]
-			default:
-				v = append(v, c)
-			}
-		}
-		path = append(path, string(v))
+		path := iniPath(replacement.Match)
 
 		value, err := f.LookupConfigurationValue(replacement)
 		if err != nil {
@@ -377,10 +383,39 @@ func (f *ConfigurationFile) parseIniFile(file ufs.File) error {
 	return nil
 }
 
+// iniPath splits the match key of a replacement for an ini file into the section
+// and the key within it. A key of "foo.bar" refers to "bar" in the "[foo]"
+// section, a key without a dot refers to a key outside any section.
+func iniPath(match string) []string {
+	var (
+		path         []string
+		bracketDepth int
+		v            []int32
+	)
+	for _, c := range match {
+		switch c {
+		case '[':
+			bracketDepth++
+		case ']':
+			bracketDepth--
+		case '.':
+			if bracketDepth > 0 || len(path) == 1 {
+				v = append(v, c)
+				continue
+			}
+			path = append(path, string(v))
+			v = v[:0]
+		default:
+			v = append(v, c)
+		}
+	}
+	return append(path, string(v))
+}
+
 // Parses a json file updating any matching key/value pairs. If a match is not found, the
 // value is set regardless in the file. See the commentary in parseYamlFile for more details
 // about what is happening during this process.
-func (f *ConfigurationFile) parseJsonFile(file ufs.File) error {
+func (f *ConfigurationFile) parseJsonFile(file parserFile) error {
 	b, err := io.ReadAll(file)
 	if err != nil {
 		return err
@@ -407,7 +442,7 @@ func (f *ConfigurationFile) parseJsonFile(file ufs.File) error {
 
 // Parses a yaml file and updates any matching key/value pairs before persisting
 // it back to the disk.
-func (f *ConfigurationFile) parseYamlFile(file ufs.File) error {
+func (f *ConfigurationFile) parseYamlFile(file parserFile) error {
 	b, err := io.ReadAll(file)
 	if err != nil {
 		return err
@@ -453,10 +488,122 @@ func (f *ConfigurationFile) parseYamlFile(file ufs.File) error {
 	return nil
 }
 
+// Parses a toml file and updates any matching key/value pairs before persisting
+// it back to the disk. Just like with yaml files the data is converted into JSON
+// so that the same replacement logic can be used. The changed values are then
+// edited in place, keeping comments and the order of the keys in the file. If a
+// replacement adds a key that is not in the file yet, or changes an array or
+// inline table, the whole file is rewritten instead, which removes any comments
+// and sorts the keys.
+func (f *ConfigurationFile) parseTomlFile(file parserFile) error {
+	b, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	i := make(map[string]interface{})
+	if err := toml.Unmarshal(b, &i); err != nil {
+		return err
+	}
+
+	jsonBytes, err := json.Marshal(i)
+	if err != nil {
+		return err
+	}
+
+	data, err := f.IterateOverJson(jsonBytes)
+	if err != nil {
+		return err
+	}
+
+	// Converting the data into JSON loses the distinction between integers, floats
+	// and dates that toml makes, so restore the original values for anything that
+	// was not changed by a replacement before writing it back out.
+	updated := restoreTomlTypes(i, data.Data())
+	marshaled, ok := editToml(b, tomlChanges(nil, i, updated))
+	if !ok {
+		if marshaled, err = toml.Marshal(updated); err != nil {
+			return err
+		}
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+
+	// Write the data to the file.
+	if _, err := io.Copy(file, bytes.NewReader(marshaled)); err != nil {
+		return errors.Wrap(err, "parser: failed to write toml file to disk")
+	}
+	return nil
+}
+
+// Parses an env file (KEY=value pairs, as used by Docker and dotenv) and updates
+// the values of any matching keys. Unlike the properties parser this keeps all
+// comments, blank lines and the order of the keys intact, and retains the quoting
+// style used for each value. Keys that are not found in the file are appended to
+// the end of it, unless the replacement has an if_value set.
+func (f *ConfigurationFile) parseEnvFile(file parserFile) error {
+	b, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if len(b) == 0 {
+		lines = nil
+	}
+
+	for _, replace := range f.Replace {
+		value, err := f.LookupConfigurationValue(replace)
+		if err != nil {
+			return errors.Wrap(err, "parser: failed to lookup configuration value")
+		}
+
+		var found bool
+		for i, line := range lines {
+			l, ok := parseEnvLine(line)
+			if !ok || l.key != replace.Match {
+				continue
+			}
+			found = true
+			v, ok := replace.matchValue(l.value, value)
+			if !ok {
+				continue
+			}
+			l.value = v
+			lines[i] = l.String()
+		}
+
+		if !found && replace.IfValue == "" {
+			lines = append(lines, envLine{key: replace.Match, value: value}.String())
+		}
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+
+	out := strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		out += "\n"
+	}
+	if _, err := io.Copy(file, strings.NewReader(out)); err != nil {
+		return errors.Wrap(err, "parser: failed to write env file to disk")
+	}
+	return nil
+}
+
 // Parses a text file using basic find and replace. This is a highly inefficient method of
 // scanning a file and performing a replacement. You should attempt to use anything other
 // than this function where possible.
-func (f *ConfigurationFile) parseTextFile(file ufs.File) error {
+func (f *ConfigurationFile) parseTextFile(file parserFile) error {
 	b := bytes.NewBuffer(nil)
 	s := bufio.NewScanner(file)
 	var replaced bool
@@ -518,7 +665,7 @@ func (f *ConfigurationFile) parseTextFile(file ufs.File) error {
 //
 // @see https://github.com/pterodactyl/panel/issues/2308 (original)
 // @see https://github.com/pterodactyl/panel/issues/3009 ("bug" introduced as result)
-func (f *ConfigurationFile) parsePropertiesFile(file ufs.File) error {
+func (f *ConfigurationFile) parsePropertiesFile(file parserFile) error {
 	b, err := io.ReadAll(file)
 	if err != nil {
 		return err
@@ -0,0 +1,212 @@
+package parser
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/franela/goblin"
+	"github.com/goccy/go-json"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// Runs the given configuration file parser against the contents provided and
+// returns the resulting file contents.
+func parseContents(t *testing.T, cf ConfigurationFile, contents string) string {
+	f, err := os.Create(filepath.Join(t.TempDir(), "config"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(contents); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if err := cf.Parse(f); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+// Builds a configuration file from its JSON representation, the same way it
+// would be received from the Panel.
+func newConfigurationFile(t *testing.T, data string) ConfigurationFile {
+	var cf ConfigurationFile
+	if err := json.Unmarshal([]byte(data), &cf); err != nil {
+		t.Fatal(err)
+	}
+	return cf
+}
+
+func TestParser(t *testing.T) {
+	g := Goblin(t)
+
+	config.Set(&config.Configuration{
+		AuthenticationToken: "abc",
+		Docker: config.DockerConfiguration{
+			Network: config.DockerNetworkConfiguration{Interface: "172.18.0.1"},
+		},
+	})
+
+	g.Describe("ConfigurationFile#UnmarshalJSON", func() {
+		g.It("returns an error if the file or parser is missing", func() {
+			for _, v := range []string{`{"parser":"toml"}`, `{"file":"config.toml"}`, `{"file":null,"parser":"toml"}`, `{}`} {
+				var cf ConfigurationFile
+				g.Assert(json.Unmarshal([]byte(v), &cf) == nil).IsFalse(v)
+			}
+		})
+
+		g.It("allows the replacements to be omitted", func() {
+			var cf ConfigurationFile
+			err := json.Unmarshal([]byte(`{"file":"config.toml","parser":"toml"}`), &cf)
+			g.Assert(err).IsNil()
+			g.Assert(cf.FileName).Equal("config.toml")
+			g.Assert(len(cf.Replace)).Equal(0)
+		})
+	})
+
+	g.Describe("ConfigurationFile#parseTomlFile", func() {
+		g.It("replaces values and keeps the original types", func() {
+			cf := newConfigurationFile(t, `{"file":"config.toml","parser":"toml","replace":[
+				{"match":"server.port","replace_with":"25570"},
+				{"match":"server.ip","replace_with":"{{config.docker.network.interface}}"},
+				{"match":"server.rate","replace_with":"2"}
+			]}`)
+
+			out := parseContents(t, cf, "title = \"test\"\nstarted = 1979-05-27T07:32:00Z\n\n[server]\nport = 25565\nip = \"0.0.0.0\"\nrate = 1.5\nmax = 10\n")
+
+			g.Assert(out).Equal("title = \"test\"\nstarted = 1979-05-27T07:32:00Z\n\n[server]\nport = 25570\nip = '172.18.0.1'\nrate = 2.0\nmax = 10\n")
+		})
+
+		g.It("keeps comments and the order of the keys", func() {
+			cf := newConfigurationFile(t, `{"file":"config.toml","parser":"toml","replace":[
+				{"match":"server.port","replace_with":"25570"},
+				{"match":"database.host","replace_with":"db"},
+				{"match":"database.limits.connections","replace_with":"20"}
+			]}`)
+
+			in := "# Server settings\n[server]\nport = 25565 # the port\nonline = true\n\n[database]\nhost = \"localhost\"\nlimits.connections = 10\n\n[[worlds]]\nport = 1\n"
+			out := parseContents(t, cf, in)
+
+			g.Assert(out).Equal("# Server settings\n[server]\nport = 25570 # the port\nonline = true\n\n[database]\nhost = 'db'\nlimits.connections = 20\n\n[[worlds]]\nport = 1\n")
+		})
+
+		g.It("rewrites the file when a key is added", func() {
+			cf := newConfigurationFile(t, `{"file":"config.toml","parser":"toml","replace":[
+				{"match":"server.port","replace_with":"25570"}
+			]}`)
+
+			out := parseContents(t, cf, "# Server settings\nname = 'test'\n")
+			g.Assert(out).Equal("name = 'test'\n\n[server]\nport = 25570\n")
+		})
+	})
+
+	g.Describe("ConfigurationFile#parseEnvFile", func() {
+		g.It("replaces values and keeps comments and quoting", func() {
+			cf := newConfigurationFile(t, `{"file":".env","parser":"env","replace":[
+				{"match":"PORT","replace_with":"25570"},
+				{"match":"NAME","replace_with":"My \"cool\" server"},
+				{"match":"MOTD","replace_with":"hello"},
+				{"match":"MISSING","replace_with":"value"}
+			]}`)
+
+			out := parseContents(t, cf, "# Server settings\nexport PORT=25565 # the port\n\nNAME=\"Old name\"\nMOTD='welcome'\nOTHER=a=b\n")
+
+			g.Assert(out).Equal("# Server settings\nexport PORT=25570 # the port\n\nNAME=\"My \\\"cool\\\" server\"\nMOTD='hello'\nOTHER=a=b\nMISSING=value\n")
+		})
+
+		g.It("quotes values when required", func() {
+			cf := newConfigurationFile(t, `{"file":".env","parser":"env","replace":[
+				{"match":"NAME","replace_with":"two words"}
+			]}`)
+
+			g.Assert(parseContents(t, cf, "NAME=old\n")).Equal("NAME=\"two words\"\n")
+		})
+
+		g.It("respects if_value", func() {
+			cf := newConfigurationFile(t, `{"file":".env","parser":"env","replace":[
+				{"match":"A","if_value":"1","replace_with":"2"},
+				{"match":"B","if_value":"1","replace_with":"2"},
+				{"match":"C","if_value":"regex:^foo(.*)$","replace_with":"baz$1"},
+				{"match":"D","if_value":"1","replace_with":"2"}
+			]}`)
+
+			out := parseContents(t, cf, "A=1\nB=3\nC=foobar\n")
+			g.Assert(out).Equal("A=2\nB=3\nC=bazbar\n")
+		})
+	})
+}
+
+func TestPreview(t *testing.T) {
+	g := Goblin(t)
+
+	config.Set(&config.Configuration{AuthenticationToken: "abc"})
+
+	g.Describe("ConfigurationFile#Preview", func() {
+		g.It("returns a diff and unmatched replacements", func() {
+			cf := newConfigurationFile(t, `{"file":"server.properties","parser":"file","replace":[
+				{"match":"server-port=","replace_with":"server-port=25570"},
+				{"match":"query.port=","replace_with":"query.port=25570"}
+			]}`)
+
+			p, err := cf.Preview([]byte("motd=hello\nserver-port=25565\n"))
+			g.Assert(err).IsNil()
+			g.Assert(p.Unmatched).Equal([]string{"query.port="})
+			g.Assert(p.Diff).Equal("--- a/server.properties\n+++ b/server.properties\n@@ -1,2 +1,2 @@\n motd=hello\n-server-port=25565\n+server-port=25570\n")
+		})
+
+		g.It("returns replacements for keys missing from structured files", func() {
+			for _, tc := range []struct {
+				parser   string
+				replace  string
+				contents string
+			}{
+				{"json", `{"match":"server.port","replace_with":"25570"},{"match":"query.port","replace_with":"25570"},{"match":"worlds.*.seed","replace_with":"1"}`, `{"server":{"port":25565},"worlds":[{"name":"a"}]}`},
+				{"yaml", `{"match":"server.port","replace_with":"25570"},{"match":"query.port","replace_with":"25570"},{"match":"worlds.*.seed","replace_with":"1"}`, "server:\n  port: 25565\nworlds:\n  - name: a\n"},
+				{"toml", `{"match":"server.port","replace_with":"25570"},{"match":"query.port","replace_with":"25570"},{"match":"worlds.*.seed","replace_with":"1"}`, "[server]\nport = 25565\n\n[[worlds]]\nname = 'a'\n"},
+				{"ini", `{"match":"server.port","replace_with":"25570"},{"match":"query.port","replace_with":"25570"},{"match":"server.seed","replace_with":"1"}`, "[server]\nport = 25565\n"},
+				{"xml", `{"match":"config.server.port","replace_with":"25570"},{"match":"config.query.port","replace_with":"25570"},{"match":"config.server.seed","replace_with":"1"}`, "<config><server><port>25565</port></server></config>"},
+				{"properties", `{"match":"server-port","replace_with":"25570"},{"match":"query.port","replace_with":"25570"},{"match":"level-seed","replace_with":"1"}`, "server-port=25565\n"},
+				{"env", `{"match":"SERVER_PORT","replace_with":"25570"},{"match":"QUERY_PORT","replace_with":"25570"},{"match":"SEED","replace_with":"1"}`, "SERVER_PORT=25565\n"},
+			} {
+				cf := newConfigurationFile(t, `{"file":"config","parser":"`+tc.parser+`","replace":[`+tc.replace+`]}`)
+
+				p, err := cf.Preview([]byte(tc.contents))
+				g.Assert(err).IsNil(tc.parser)
+				g.Assert(p.Unmatched).Equal([]string{cf.Replace[1].Match, cf.Replace[2].Match}, tc.parser)
+			}
+		})
+
+		g.It("returns replacements whose if_value does not match", func() {
+			cf := newConfigurationFile(t, `{"file":"config.json","parser":"json","replace":[
+				{"match":"server.port","if_value":"1","replace_with":"25570"}
+			]}`)
+
+			p, err := cf.Preview([]byte(`{"server":{"port":25565}}`))
+			g.Assert(err).IsNil()
+			g.Assert(p.Unmatched).Equal([]string{"server.port"})
+		})
+
+		g.It("returns an empty diff when nothing changes", func() {
+			cf := newConfigurationFile(t, `{"file":".env","parser":"env","replace":[
+				{"match":"PORT","replace_with":"25565"}
+			]}`)
+
+			p, err := cf.Preview([]byte("PORT=25565\n"))
+			g.Assert(err).IsNil()
+			g.Assert(p.Diff).Equal("")
+			g.Assert(p.Unmatched).Equal([]string{})
+		})
+	})
+}
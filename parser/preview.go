@@ -0,0 +1,273 @@
+package parser
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/Jeffail/gabs/v2"
+	"github.com/beevik/etree"
+	"github.com/buger/jsonparser"
+	"github.com/goccy/go-json"
+	"github.com/icza/dyno"
+	"github.com/magiconair/properties"
+	"github.com/pelletier/go-toml/v2"
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/ini.v1"
+	"gopkg.in/yaml.v3"
+)
+
+// The value used in place of a replacement when probing a file to determine if
+// the replacement matched anything in it.
+const previewProbeValue = "__wings_configuration_preview_probe__"
+
+// Preview is the result of running the replacements for a configuration file
+// against its current contents without writing the changes back to the disk.
+type Preview struct {
+	File   string `json:"file"`
+	Parser string `json:"parser"`
+	// Diff is a unified diff between the current contents of the file and the
+	// contents it would have after the replacements are applied. This is empty
+	// if the file would not be changed.
+	Diff string `json:"diff"`
+	// Unmatched contains the match keys of any replacements that did not match
+	// anything in the file. This includes replacements for keys that are missing
+	// from the file, which most parsers add to the file instead of replacing an
+	// existing value.
+	Unmatched []string `json:"unmatched"`
+	// Error is set if the file could not be read or parsed, in which case no
+	// diff will be present.
+	Error string `json:"error,omitempty"`
+}
+
+// Preview runs the configured replacements against the provided file contents and
+// returns a diff of the changes that would be made to the file. The contents are
+// never written anywhere, so this is safe to call against a running server.
+func (f *ConfigurationFile) Preview(contents []byte) (*Preview, error) {
+	p := &Preview{File: f.FileName, Parser: f.Parser.String(), Unmatched: []string{}}
+
+	updated, err := f.parseBytes(f.Replace, contents)
+	if err != nil {
+		return nil, err
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(contents),
+		B:        splitLines(updated),
+		FromFile: "a/" + f.FileName,
+		ToFile:   "b/" + f.FileName,
+		Context:  3,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "parser: failed to generate diff")
+	}
+	p.Diff = diff
+
+	// Parsing the file without any replacements gives us the baseline for the file
+	// since most parsers will re-format the contents regardless. Each replacement is
+	// then run on its own with a probe value, if the result is identical to the
+	// baseline the replacement did not match anything in the file.
+	baseline, err := f.parseBytes(nil, contents)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range f.Replace {
+		probe := r
+		probe.ReplaceWith = ReplaceValue{value: []byte(previewProbeValue), valueType: jsonparser.String}
+		out, err := f.parseBytes([]ConfigurationFileReplacement{probe}, contents)
+		if err != nil {
+			return nil, err
+		}
+		// Most parsers add the key to the file if it does not exist yet rather than
+		// leaving the file unchanged, so also check that the key is already present.
+		ok, err := f.exists(r, contents)
+		if err != nil {
+			return nil, err
+		}
+		if !ok || bytes.Equal(out, baseline) {
+			p.Unmatched = append(p.Unmatched, r.Match)
+		}
+	}
+
+	return p, nil
+}
+
+// exists checks if the key matched by a replacement is present in the contents of
+// the file. Text files never add lines that are missing, so they are always
+// considered to contain the key.
+func (f *ConfigurationFile) exists(r ConfigurationFileReplacement, contents []byte) (bool, error) {
+	switch f.Parser {
+	case Properties:
+		p, err := properties.Load(contents, properties.UTF8)
+		if err != nil {
+			return false, errors.Wrap(err, "parser: could not load properties file")
+		}
+		_, ok := p.Get(r.Match)
+		return ok, nil
+	case Env, "dotenv":
+		for _, line := range strings.Split(string(contents), "\n") {
+			if l, ok := parseEnvLine(line); ok && l.key == r.Match {
+				return true, nil
+			}
+		}
+		return false, nil
+	case Ini:
+		cfg, err := ini.Load(contents)
+		if err != nil {
+			return false, errors.Wrap(err, "parser: could not load ini file")
+		}
+		path := iniPath(r.Match)
+		if len(path) == 1 {
+			return cfg.Section("").HasKey(path[0]), nil
+		}
+		s, err := cfg.GetSection(path[0])
+		return err == nil && s.HasKey(path[1]), nil
+	case Xml:
+		doc := etree.NewDocument()
+		if err := doc.ReadFromBytes(contents); err != nil {
+			return false, errors.Wrap(err, "parser: could not load xml file")
+		}
+		return len(doc.FindElements("./"+strings.Replace(r.Match, ".", "/", -1))) > 0, nil
+	case Json, Yaml, "yml", Toml:
+		b, err := f.toJson(contents)
+		if err != nil {
+			return false, err
+		}
+		c, err := gabs.ParseJSON(b)
+		if err != nil {
+			return false, errors.Wrap(err, "parser: could not parse json")
+		}
+		if !strings.Contains(r.Match, ".*") {
+			return existsAtPath(c, r.Match), nil
+		}
+		parts := strings.SplitN(r.Match, ".*", 2)
+		for _, child := range c.Path(strings.Trim(parts[0], ".")).Children() {
+			if existsAtPath(child, strings.Trim(parts[1], ".")) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// toJson converts the contents of a json, yaml or toml file into JSON in the same
+// way the parsers do before running the replacements.
+func (f *ConfigurationFile) toJson(contents []byte) ([]byte, error) {
+	i := make(map[string]interface{})
+	switch f.Parser {
+	case Yaml, "yml":
+		if err := yaml.Unmarshal(contents, &i); err != nil {
+			return nil, errors.Wrap(err, "parser: could not parse yaml")
+		}
+		return json.Marshal(dyno.ConvertMapI2MapS(i))
+	case Toml:
+		if err := toml.Unmarshal(contents, &i); err != nil {
+			return nil, errors.Wrap(err, "parser: could not parse toml")
+		}
+		return json.Marshal(i)
+	}
+	return contents, nil
+}
+
+// existsAtPath checks if a value exists at the path, which may refer to an array
+// element such as "something[1]" in the same way as setValueAtPath.
+func existsAtPath(c *gabs.Container, path string) bool {
+	matches := checkForArrayElement.FindStringSubmatch(path)
+	if len(matches) < 3 {
+		return c.ExistsP(path)
+	}
+	i, _ := strconv.Atoi(matches[2])
+	el, err := c.ArrayElementP(i, matches[1])
+	if err != nil {
+		return false
+	}
+	if matches[3] == "" {
+		return true
+	}
+	return el.ExistsP(strings.TrimPrefix(matches[3], "."))
+}
+
+// Runs the given replacements against an in-memory copy of the contents using the
+// parser defined for the file and returns the resulting contents.
+func (f *ConfigurationFile) parseBytes(replace []ConfigurationFileReplacement, contents []byte) ([]byte, error) {
+	c := *f
+	c.Replace = replace
+	m := &memFile{buf: append([]byte{}, contents...)}
+	if err := c.parse(m); err != nil {
+		return nil, errors.WrapIf(err, "parser: failed to parse configuration file")
+	}
+	return m.buf, nil
+}
+
+// Splits the contents into lines, keeping the trailing newline on each line. This
+// differs from difflib.SplitLines which adds an extra empty line to the output. A
+// final line without a newline is given one so that the diff output stays readable.
+func splitLines(b []byte) []string {
+	lines := strings.SplitAfter(string(b), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+		lines[n-1] += "\n"
+	}
+	return lines
+}
+
+// memFile is an in-memory file used when previewing the changes to a file.
+type memFile struct {
+	buf []byte
+	off int64
+}
+
+func (m *memFile) Read(p []byte) (int, error) {
+	if m.off >= int64(len(m.buf)) {
+		return 0, io.EOF
+	}
+	n := copy(p, m.buf[m.off:])
+	m.off += int64(n)
+	return n, nil
+}
+
+func (m *memFile) Write(p []byte) (int, error) {
+	end := m.off + int64(len(p))
+	if end > int64(len(m.buf)) {
+		m.buf = append(m.buf, make([]byte, end-int64(len(m.buf)))...)
+	}
+	copy(m.buf[m.off:], p)
+	m.off = end
+	return len(p), nil
+}
+
+func (m *memFile) Seek(offset int64, whence int) (int64, error) {
+	var abs int64
+	switch whence {
+	case io.SeekStart:
+		abs = offset
+	case io.SeekCurrent:
+		abs = m.off + offset
+	case io.SeekEnd:
+		abs = int64(len(m.buf)) + offset
+	default:
+		return 0, errors.New("parser: invalid whence")
+	}
+	if abs < 0 {
+		return 0, errors.New("parser: negative position")
+	}
+	m.off = abs
+	return abs, nil
+}
+
+func (m *memFile) Truncate(size int64) error {
+	if size < 0 {
+		return errors.New("parser: negative size")
+	}
+	if size <= int64(len(m.buf)) {
+		m.buf = m.buf[:size]
+	} else {
+		m.buf = append(m.buf, make([]byte, size-int64(len(m.buf)))...)
+	}
+	return nil
+}
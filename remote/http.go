@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/pterodactyl/wings/internal/models"
@@ -31,13 +32,16 @@ type Client interface {
 	SendRestorationStatus(ctx context.Context, backup string, successful bool) error
 	SetInstallationStatus(ctx context.Context, uuid string, data InstallStatusRequest) error
 	SetTransferStatus(ctx context.Context, uuid string, successful bool) error
+	SendTransferProgress(ctx context.Context, uuid string, data TransferProgressRequest) error
 	ValidateSftpCredentials(ctx context.Context, request SftpAuthRequest) (SftpAuthResponse, error)
 	SendActivityLogs(ctx context.Context, activity []models.Activity) error
+	SetCredentials(id, token string)
 }
 
 type client struct {
 	httpClient  *http.Client
 	baseUrl     string
+	mu          sync.RWMutex
 	tokenId     string
 	token       string
 	maxAttempts int
@@ -59,6 +63,15 @@ func New(base string, opts ...ClientOption) Client {
 	return &c
 }
 
+// SetCredentials replaces the credentials used when making requests to the remote
+// API endpoint, such as after the token was reset in the Panel.
+func (c *client) SetCredentials(id, token string) {
+	c.mu.Lock()
+	c.tokenId = id
+	c.token = token
+	c.mu.Unlock()
+}
+
 // WithCredentials sets the credentials to use when making request to the remote
 // API endpoint.
 func WithCredentials(id, token string) ClientOption {
@@ -105,10 +118,13 @@ func (c *client) requestOnce(ctx context.Context, method, path string, body io.R
 		return nil, err
 	}
 
-	req.Header.Set("User-Agent", fmt.Sprintf("Pterodactyl Wings/v%s (id:%s)", system.Version, c.tokenId))
+	c.mu.RLock()
+	id, token := c.tokenId, c.token
+	c.mu.RUnlock()
+	req.Header.Set("User-Agent", fmt.Sprintf("Pterodactyl Wings/v%s (id:%s)", system.Version, id))
 	req.Header.Set("Accept", "application/vnd.pterodactyl.v1+json")
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s.%s", c.tokenId, c.token))
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s.%s", id, token))
 
 	// Call all opts functions to allow modifying the request
 	for _, o := range opts {
@@ -123,6 +123,17 @@ func (c *client) SetTransferStatus(ctx context.Context, uuid string, successful
 	return nil
 }
 
+// SendTransferProgress reports the progress of an outgoing server transfer to
+// the Panel.
+func (c *client) SendTransferProgress(ctx context.Context, uuid string, data TransferProgressRequest) error {
+	resp, err := c.Post(ctx, fmt.Sprintf("/servers/%s/transfer/progress", uuid), data)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
 // ValidateSftpCredentials makes a request to determine if the username and
 // password combination provided is associated with a valid server on the instance
 // using the Panel's authentication control mechanisms. This will get itself
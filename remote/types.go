@@ -59,6 +59,42 @@ type InstallationScript struct {
 	ContainerImage string `json:"container_image"`
 	Entrypoint     string `json:"entrypoint"`
 	Script         string `json:"script"`
+	// RegistryAuth is the credentials to use when pulling the installation image,
+	// if it is hosted in a private registry.
+	RegistryAuth *RegistryCredentials `json:"registry_auth,omitempty"`
+	// Template is an archive of pre-built files that is unpacked into the server's
+	// data directory before the installation script is run.
+	Template *InstallTemplate `json:"template,omitempty"`
+}
+
+// InstallTemplate is a tar or zip archive unpacked into a server's data directory
+// as part of the installation process. The template is either stored in the
+// node's template directory, or downloaded from a URL and cached on the node.
+type InstallTemplate struct {
+	// Name is the file name of a template in the node's template directory.
+	Name string `json:"name"`
+	// URL is the location to download the template from if no name is provided.
+	URL string `json:"url"`
+	// Checksum is the hex encoded SHA-256 checksum of the template. It is required
+	// for templates downloaded from a URL and is used as the key in the cache.
+	Checksum string `json:"checksum"`
+	// SkipScript unpacks the template instead of running the installation script.
+	SkipScript bool `json:"skip_script"`
+}
+
+// RegistryCredentials are the credentials provided by the Panel for pulling an
+// image from a private registry. These take priority over any credentials for
+// the registry configured on the node.
+type RegistryCredentials struct {
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identity_token"`
+	RegistryToken string `json:"registry_token"`
+}
+
+// IsEmpty returns true if no credentials have been provided.
+func (rc *RegistryCredentials) IsEmpty() bool {
+	return rc == nil || (rc.Username == "" && rc.Password == "" && rc.IdentityToken == "" && rc.RegistryToken == "")
 }
 
 // RawServerData is a raw response from the API for a server.
@@ -174,3 +210,18 @@ type InstallStatusRequest struct {
 	Successful bool `json:"successful"`
 	Reinstall  bool `json:"reinstall"`
 }
+
+// TransferProgressRequest is sent to the Panel periodically while a server is
+// being sent to another node.
+type TransferProgressRequest struct {
+	Status string `json:"status"`
+	// Bytes is the number of bytes that have been sent to the target node.
+	Bytes uint64 `json:"bytes"`
+	// Total is the estimated total number of bytes that will be sent.
+	Total uint64 `json:"total"`
+	// Rate is the current send rate in bytes per second.
+	Rate uint64 `json:"rate"`
+	// Eta is the estimated number of seconds until all data has been sent, or -1
+	// if it cannot yet be estimated.
+	Eta int64 `json:"eta"`
+}
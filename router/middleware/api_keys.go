@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"crypto/subtle"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// routeScopes maps each route that can be accessed using an API key to the scope
+// required to do so. Routes that are not listed here can only be accessed using
+// the node's authentication token.
+var routeScopes = map[string]string{
+	"GET /api/system":      config.ScopeSystemRead,
+	"GET /api/servers":     config.ScopeSystemRead,
+	"GET /api/images":      config.ScopeSystemRead,
+	"GET /api/images/pull": config.ScopeSystemRead,
+
+	"GET /api/servers/:server":                      config.ScopeServersRead,
+	"GET /api/servers/:server/logs":                 config.ScopeServersRead,
+	"GET /api/servers/:server/commands":             config.ScopeServersRead,
+	"GET /api/servers/:server/install/history":      config.ScopeServersRead,
+	"GET /api/servers/:server/install/history/:run": config.ScopeServersRead,
+
+	"POST /api/servers/:server/power":     config.ScopePower,
+	"POST /api/servers/:server/commands":  config.ScopeConsole,
+	"POST /api/servers/:server/install":   config.ScopeInstall,
+	"POST /api/servers/:server/reinstall": config.ScopeInstall,
+
+	"GET /api/servers/:server/files/contents":          config.ScopeFilesRead,
+	"GET /api/servers/:server/files/list-directory":    config.ScopeFilesRead,
+	"GET /api/servers/:server/files/usage":             config.ScopeFilesRead,
+	"GET /api/servers/:server/files/pull":              config.ScopeFilesRead,
+	"PUT /api/servers/:server/files/rename":            config.ScopeFilesWrite,
+	"POST /api/servers/:server/files/copy":             config.ScopeFilesWrite,
+	"POST /api/servers/:server/files/write":            config.ScopeFilesWrite,
+	"POST /api/servers/:server/files/create-directory": config.ScopeFilesWrite,
+	"POST /api/servers/:server/files/delete":           config.ScopeFilesWrite,
+	"POST /api/servers/:server/files/compress":         config.ScopeFilesWrite,
+	"POST /api/servers/:server/files/decompress":       config.ScopeFilesWrite,
+	"POST /api/servers/:server/files/chmod":            config.ScopeFilesWrite,
+	"POST /api/servers/:server/files/pull":             config.ScopeFilesWrite,
+	"DELETE /api/servers/:server/files/pull/:download": config.ScopeFilesWrite,
+
+	"POST /api/servers/:server/backup":                 config.ScopeBackups,
+	"POST /api/servers/:server/backup/:backup/restore": config.ScopeBackups,
+	"DELETE /api/servers/:server/backup/:backup":       config.ScopeBackups,
+}
+
+// findApiKey returns the API key matching the token, or nil if there is none.
+func findApiKey(keys []config.ApiKey, token string) *config.ApiKey {
+	var found *config.ApiKey
+	for i := range keys {
+		// Compare against every key so that the time taken does not reveal which
+		// key matched.
+		if keys[i].Token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(keys[i].Token)) == 1 {
+			found = &keys[i]
+		}
+	}
+	return found
+}
+
+// authorizeApiKey checks that the API key is allowed to access the requested route
+// and server. Returns the reason the request was denied, or an empty string if the
+// request is allowed.
+func authorizeApiKey(c *gin.Context, key *config.ApiKey) string {
+	if key.Expired() {
+		return "key has expired"
+	}
+	scope, ok := routeScopes[c.Request.Method+" "+c.FullPath()]
+	if !ok {
+		return "route cannot be accessed using an api key"
+	}
+	if !key.HasScope(scope) {
+		return "key does not have the " + scope + " scope"
+	}
+	if id := c.Param("server"); id != "" && !key.CanAccessServer(id) {
+		return "key cannot access the server"
+	}
+	return ""
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+	"github.com/gin-gonic/gin"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestRequireAuthorization(t *testing.T) {
+	g := Goblin(t)
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(AttachRequestID(), RequireAuthorization())
+	router.GET("/api/system", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	router.POST("/api/update", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	router.POST("/api/servers/:server/power", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	request := func(method, path, token string) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(method, path, nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	g.Describe("RequireAuthorization", func() {
+		g.BeforeEach(func() {
+			config.Set(&config.Configuration{
+				AuthenticationToken: "node",
+				ApiKeys: []config.ApiKey{
+					{Name: "monitoring", Token: "monitoring", Scopes: []string{config.ScopeSystemRead}},
+					{Name: "bot", Token: "bot", Scopes: []string{config.ScopePower}, Servers: []string{"allowed"}},
+					{Name: "expired", Token: "expired", Scopes: []string{config.ScopeSystemRead}, ExpiresAt: time.Now().Add(-time.Hour)},
+				},
+			})
+		})
+
+		g.It("allows the node token to access every route", func() {
+			g.Assert(request("POST", "/api/update", "node")).Equal(http.StatusNoContent)
+			g.Assert(request("POST", "/api/servers/other/power", "node")).Equal(http.StatusNoContent)
+		})
+
+		g.It("allows an api key to access routes within its scopes", func() {
+			g.Assert(request("GET", "/api/system", "monitoring")).Equal(http.StatusNoContent)
+			g.Assert(request("POST", "/api/servers/allowed/power", "monitoring")).Equal(http.StatusForbidden)
+		})
+
+		g.It("denies api keys access to routes without a scope", func() {
+			g.Assert(request("POST", "/api/update", "monitoring")).Equal(http.StatusForbidden)
+		})
+
+		g.It("limits api keys to their servers", func() {
+			g.Assert(request("POST", "/api/servers/allowed/power", "bot")).Equal(http.StatusNoContent)
+			g.Assert(request("POST", "/api/servers/other/power", "bot")).Equal(http.StatusForbidden)
+		})
+
+		g.It("denies expired and unknown keys", func() {
+			g.Assert(request("GET", "/api/system", "expired")).Equal(http.StatusForbidden)
+			g.Assert(request("GET", "/api/system", "unknown")).Equal(http.StatusForbidden)
+		})
+	})
+}
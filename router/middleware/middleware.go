@@ -5,6 +5,7 @@ import (
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
@@ -28,12 +29,34 @@ func AttachRequestID() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		id := uuid.New().String()
 		c.Set("request_id", id)
-		c.Set("logger", log.WithField("request_id", id))
+		c.Set("logger", log.WithField("subsystem", "http").WithField("request_id", id))
 		c.Header("X-Request-Id", id)
 		c.Next()
 	}
 }
 
+// AccessLog writes an entry for every request to the access log once it has been
+// handled, including the client IP, route, status and latency so that abusive
+// clients can be identified and blocked by external tooling.
+func AccessLog(l log.Interface) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+		l.WithFields(log.Fields{
+			"client_ip":  c.ClientIP(),
+			"method":     c.Request.Method,
+			"path":       c.Request.URL.Path,
+			"route":      c.FullPath(),
+			"status":     c.Writer.Status(),
+			"size":       c.Writer.Size(),
+			"latency_ms": float64(latency) / float64(time.Millisecond),
+			"request_id": c.GetString("request_id"),
+			"user_agent": c.Request.UserAgent(),
+		}).Info(c.Request.Method + " " + c.Request.URL.Path)
+	}
+}
+
 // AttachServerManager attaches the server manager to the request context which
 // allows routes to access the underlying server collection.
 func AttachServerManager(m *server.Manager) gin.HandlerFunc {
@@ -163,12 +186,24 @@ func ServerExists() gin.HandlerFunc {
 // permission string, ensuring that if it is a server permission, the token has
 // control over that server. If it is a global token, this will ensure that the
 // request is using a properly signed global token.
+//
+// Requests may also be made using one of the API keys defined in the configuration,
+// in which case the key must have been granted the scope required by the route and
+// be allowed to access the server in the request. Every request made using an API
+// key is logged along with the name of the key.
 func RequireAuthorization() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Routes registered on a group inherit this middleware from the router as well,
+		// so don't authorize the same request twice.
+		if c.GetBool("authorized") {
+			c.Next()
+			return
+		}
+
 		// We don't put this value outside this function since the node's authentication
 		// token can be changed on the fly and the config.Get() call returns a copy, so
 		// if it is rotated this value will never properly get updated.
-		token := config.Get().AuthenticationToken
+		cfg := config.Get()
 		auth := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
 		if len(auth) != 2 || auth[0] != "Bearer" {
 			c.Header("WWW-Authenticate", "Bearer")
@@ -179,10 +214,49 @@ func RequireAuthorization() gin.HandlerFunc {
 		// All requests to Wings must be authorized with the authentication token present in
 		// the Wings configuration file. Remeber, all requests to Wings come from the Panel
 		// backend, or using a signed JWT for temporary authentication.
-		if subtle.ConstantTimeCompare([]byte(auth[1]), []byte(token)) != 1 {
+		if subtle.ConstantTimeCompare([]byte(auth[1]), []byte(cfg.AuthenticationToken)) == 1 {
+			c.Set("authorized", true)
+			c.Next()
+			return
+		}
+
+		key := findApiKey(cfg.ApiKeys, auth[1])
+		if key == nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You are not authorized to access this endpoint."})
+			return
+		}
+
+		logger := ExtractLogger(c).WithFields(log.Fields{
+			"api_key":   key.Name,
+			"method":    c.Request.Method,
+			"path":      c.Request.URL.Path,
+			"client_ip": c.ClientIP(),
+		})
+		if reason := authorizeApiKey(c, key); reason != "" {
+			logger.WithField("reason", reason).Warn("denied request made using api key")
 			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "You are not authorized to access this endpoint."})
 			return
 		}
+
+		c.Set("authorized", true)
+		c.Set("api_key", key)
+		c.Set("logger", ExtractLogger(c).WithField("api_key", key.Name))
+		c.Next()
+
+		logger.WithField("status", c.Writer.Status()).Info("request made using api key")
+	}
+}
+
+// RequireClientCertificate ensures that the request was made using a client
+// certificate signed by one of the configured client certificate authorities when
+// mutual TLS is enabled. The certificate itself is verified by the webserver when
+// the connection is established.
+func RequireClientCertificate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if config.Get().Api.Mtls.Enabled && (c.Request.TLS == nil || len(c.Request.TLS.VerifiedChains) == 0) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "A valid client certificate is required to access this endpoint."})
+			return
+		}
 		c.Next()
 	}
 }
@@ -229,6 +303,15 @@ func ExtractApiClient(c *gin.Context) remote.Client {
 	panic("middleware/middlware: cannot extract api clinet: not present in context")
 }
 
+// ExtractApiKey returns the API key used to authorize the request, or nil if the
+// request was authorized using the node's authentication token.
+func ExtractApiKey(c *gin.Context) *config.ApiKey {
+	if v, ok := c.Get("api_key"); ok {
+		return v.(*config.ApiKey)
+	}
+	return nil
+}
+
 // ExtractManager returns the server manager instance set on the request context.
 func ExtractManager(c *gin.Context) *server.Manager {
 	if v, ok := c.Get("manager"); ok {
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/franela/goblin"
+	"github.com/gin-gonic/gin"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestRequireClientCertificate(t *testing.T) {
+	g := Goblin(t)
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(RequireClientCertificate())
+	router.GET("/", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	request := func(state *tls.ConnectionState) int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.TLS = state
+		router.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	g.Describe("RequireClientCertificate", func() {
+		g.It("allows requests when mutual TLS is disabled", func() {
+			config.Set(&config.Configuration{AuthenticationToken: "abc"})
+			g.Assert(request(nil)).Equal(http.StatusNoContent)
+		})
+
+		g.It("requires a verified client certificate when mutual TLS is enabled", func() {
+			cfg := &config.Configuration{AuthenticationToken: "abc"}
+			cfg.Api.Mtls.Enabled = true
+			config.Set(cfg)
+
+			g.Assert(request(nil)).Equal(http.StatusUnauthorized)
+			g.Assert(request(&tls.ConnectionState{})).Equal(http.StatusUnauthorized)
+			g.Assert(request(&tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}})).Equal(http.StatusNoContent)
+		})
+	})
+}
@@ -11,8 +11,9 @@ import (
 	wserver "github.com/pterodactyl/wings/server"
 )
 
-// Configure configures the routing infrastructure for this daemon instance.
-func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
+// Configure configures the routing infrastructure for this daemon instance. If an
+// access logger is provided every request is written to it once completed.
+func Configure(m *wserver.Manager, client remote.Client, access log.Interface) *gin.Engine {
 	gin.SetMode("release")
 
 	router := gin.New()
@@ -23,13 +24,15 @@ func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
 	}
 	router.Use(middleware.AttachRequestID(), middleware.CaptureErrors(), middleware.SetAccessControlHeaders())
 	router.Use(middleware.AttachServerManager(m), middleware.AttachApiClient(client))
-	// @todo log this into a different file so you can setup IP blocking for abusive requests and such.
-	// This should still dump requests in debug mode since it does help with understanding the request
-	// lifecycle and quickly seeing what was called leading to the logs. However, it isn't feasible to mix
-	// this output in production and still get meaningful logs from it since they'll likely just be a huge
-	// spamfest.
+	if access != nil {
+		router.Use(middleware.AccessLog(access))
+	}
+	// Requests are also dumped into the Wings log at the debug level since it helps
+	// with understanding the request lifecycle and what was called leading to other
+	// log entries.
 	router.Use(gin.LoggerWithFormatter(func(params gin.LogFormatterParams) string {
 		log.WithFields(log.Fields{
+			"subsystem":  "http",
 			"client_ip":  params.ClientIP,
 			"status":     params.StatusCode,
 			"latency":    params.Latency,
@@ -49,34 +52,58 @@ func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
 	// accessible.
 	router.GET("/api/servers/:server/ws", middleware.ServerExists(), getServerWebsocket)
 
+	// The node level websocket uses a JWT listing multiple servers to authorize
+	// access and multiplexes their status and stats events over one connection.
+	router.GET("/api/ws", getNodeWebsocket)
+
 	// This request is called by another daemon when a server is going to be transferred out.
 	// This request does not need the AuthorizationMiddleware as the panel should never call it
-	// and requests are authenticated through a JWT the panel issues to the other daemon.
-	router.POST("/api/transfers", postTransfers)
+	// and requests are authenticated through a JWT the panel issues to the other daemon. When
+	// mutual TLS is enabled the other daemon must also present a client certificate.
+	transfers := router.Group("/api/transfers", middleware.RequireClientCertificate())
+	transfers.POST("", postTransfers)
+
+	// Incremental transfers send each file in checksummed chunks which can be resumed
+	// from the last received offset if a request fails. These are authenticated in
+	// the same way as the transfer endpoint above.
+	transfers.POST("/incremental", postIncrementalTransfer)
+	transfers.HEAD("/incremental/files", headIncrementalTransferFile)
+	transfers.PUT("/incremental/files", putIncrementalTransferFile)
+	transfers.PUT("/incremental/backups", putIncrementalTransferBackup)
+	transfers.POST("/incremental/complete", postIncrementalTransferComplete)
 
 	// All the routes beyond this mount will use an authorization middleware
 	// and will not be accessible without the correct Authorization header provided.
-	protected := router.Use(middleware.RequireAuthorization())
+	protected := router.Use(middleware.RequireClientCertificate(), middleware.RequireAuthorization())
 	protected.POST("/api/update", postUpdateConfiguration)
 	protected.GET("/api/system", getSystemInformation)
+	protected.POST("/api/system/reload", postReloadConfiguration)
 	protected.GET("/api/servers", getAllServers)
 	protected.POST("/api/servers", postCreateServer)
 	protected.DELETE("/api/transfers/:server", deleteTransfer)
+	protected.GET("/api/images", getImages)
+	protected.GET("/api/images/pull", getImagePulls)
+	protected.POST("/api/images/pull", postImagePulls)
+	protected.POST("/api/images/prune", postImagesPrune)
 
 	// These are server specific routes, and require that the request be authorized, and
 	// that the server exist on the Daemon.
 	server := router.Group("/api/servers/:server")
-	server.Use(middleware.RequireAuthorization(), middleware.ServerExists())
+	server.Use(middleware.RequireClientCertificate(), middleware.RequireAuthorization(), middleware.ServerExists())
 	{
 		server.GET("", getServer)
 		server.DELETE("", deleteServer)
 
 		server.GET("/logs", getServerLogs)
 		server.POST("/power", postServerPower)
+		server.GET("/commands", getServerCommands)
 		server.POST("/commands", postServerCommands)
 		server.POST("/install", postServerInstall)
 		server.POST("/reinstall", postServerReinstall)
+		server.GET("/install/history", getServerInstallHistory)
+		server.GET("/install/history/:run", getServerInstallRun)
 		server.POST("/sync", postServerSync)
+		server.POST("/configuration/preview", postServerConfigurationPreview)
 		server.POST("/ws/deny", postServerDenyWSTokens)
 
 		// This archive request causes the archive to start being created
@@ -88,6 +115,7 @@ func Configure(m *wserver.Manager, client remote.Client) *gin.Engine {
 		{
 			files.GET("/contents", getServerFileContents)
 			files.GET("/list-directory", getServerListDirectory)
+			files.GET("/usage", getServerDirectoryUsage)
 			files.PUT("/rename", putServerRenameFiles)
 			files.POST("/copy", postServerCopyFile)
 			files.POST("/write", postServerWriteFile)
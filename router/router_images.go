@@ -0,0 +1,55 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/pterodactyl/wings/internal/images"
+	"github.com/pterodactyl/wings/router/middleware"
+)
+
+// getImages returns the disk used by every docker image on the node, along with
+// the servers that use each one.
+func getImages(c *gin.Context) {
+	report, err := images.Get(middleware.ExtractManager(c)).Report(c.Request.Context())
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"images": report})
+}
+
+// getImagePulls returns the status of images being pulled through the API.
+func getImagePulls(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"pulls": images.Get(middleware.ExtractManager(c)).Pulls()})
+}
+
+// postImagePulls starts pulling the given images in the background so that they
+// are already present when a server using them is first started.
+func postImagePulls(c *gin.Context) {
+	var data struct {
+		Images []string `binding:"required,min=1" json:"images"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	pulls := images.Get(middleware.ExtractManager(c)).Pull(data.Images)
+
+	c.JSON(http.StatusAccepted, gin.H{"pulls": pulls})
+}
+
+// postImagesPrune removes docker images that are no longer used by any server on
+// the node. If "dry_run" is set the images that would be removed are returned
+// without removing them.
+func postImagesPrune(c *gin.Context) {
+	removed, err := images.Get(middleware.ExtractManager(c)).Prune(c.Request.Context(), c.Query("dry_run") == "true")
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"images": removed})
+}
@@ -10,6 +10,8 @@ import (
 	"github.com/apex/log"
 	"github.com/gin-gonic/gin"
 
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/parser"
 	"github.com/pterodactyl/wings/router/downloader"
 	"github.com/pterodactyl/wings/router/middleware"
 	"github.com/pterodactyl/wings/router/tokens"
@@ -19,7 +21,17 @@ import (
 
 // Returns a single server from the collection of servers.
 func getServer(c *gin.Context) {
-	c.JSON(http.StatusOK, ExtractServer(c).ToAPIResponse())
+	c.JSON(http.StatusOK, serverAPIResponse(c, ExtractServer(c)))
+}
+
+// serverAPIResponse returns the API response for the server, redacting the
+// configuration of the server if the request was made using an API key that has
+// not been granted access to it.
+func serverAPIResponse(c *gin.Context, s *server.Server) server.APIResponse {
+	if key := middleware.ExtractApiKey(c); key != nil && !key.HasScope(config.ScopeServersConfig) {
+		return s.ToRedactedAPIResponse()
+	}
+	return s.ToAPIResponse()
 }
 
 // Returns the logs for a given server instance.
@@ -127,14 +139,46 @@ func postServerCommands(c *gin.Context) {
 	}
 
 	for _, command := range data.Commands {
-		if err := s.Environment.SendCommand(command); err != nil {
+		if !s.IsCommandAllowed(command) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "The command \"" + command + "\" is not allowed to be sent to this server.",
+			})
+			return
+		}
+	}
+
+	ra := s.NewRequestActivity("", c.ClientIP())
+	for _, command := range data.Commands {
+		if err := s.SendCommand(command); err != nil {
 			s.Log().WithFields(log.Fields{"command": command, "error": err}).Warn("failed to send command to server instance")
+			continue
 		}
+		s.SaveCommand(ra, command)
 	}
 
 	c.Status(http.StatusNoContent)
 }
 
+// Returns the most recent console commands sent to the server. The results can
+// be limited to the commands sent by a single user by passing their UUID in the
+// "user" query parameter.
+func getServerCommands(c *gin.Context) {
+	s := ExtractServer(c)
+
+	l, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if l <= 0 || l > 100 {
+		l = 100
+	}
+
+	history, err := s.CommandHistory(c.Request.Context(), c.Query("user"), l)
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": history})
+}
+
 // postServerSync will accept a POST request and trigger a re-sync of the given
 // server against the Panel. This can be manually triggered when needed by an
 // external system, or triggered by the Panel itself when modifications are made
@@ -149,6 +193,26 @@ func postServerSync(c *gin.Context) {
 	}
 }
 
+// Runs the configuration file replacements for the server against the current
+// files without writing any changes and returns a unified diff for each file,
+// along with any replacements that did not match. An alternate set of
+// configuration files can be provided in the request body to test changes to
+// an egg before saving them.
+func postServerConfigurationPreview(c *gin.Context) {
+	s := ExtractServer(c)
+
+	var data struct {
+		Configs []parser.ConfigurationFile `json:"configs"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&data); err != nil {
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": s.PreviewConfigurationFiles(data.Configs)})
+}
+
 // Performs a server installation in a background thread.
 func postServerInstall(c *gin.Context) {
 	s := ExtractServer(c)
@@ -188,6 +252,41 @@ func postServerReinstall(c *gin.Context) {
 	c.Status(http.StatusAccepted)
 }
 
+// Returns the most recent installation runs for the server, without their output.
+func getServerInstallHistory(c *gin.Context) {
+	s := ExtractServer(c)
+
+	history, err := s.InstallHistory(c.Request.Context())
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": history})
+}
+
+// Returns a single installation run for the server, including its output.
+func getServerInstallRun(c *gin.Context) {
+	s := ExtractServer(c)
+
+	id, err := strconv.Atoi(c.Param("run"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "The run identifier must be a number."})
+		return
+	}
+	run, err := s.InstallRun(c.Request.Context(), id)
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	if run == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "The requested installation run could not be found."})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
 // Deletes a server from the wings daemon and dissociate its objects.
 func deleteServer(c *gin.Context) {
 	s := middleware.ExtractServer(c)
@@ -228,9 +327,19 @@ func deleteServer(c *gin.Context) {
 	// In addition, servers with large amounts of files can take some time to finish deleting,
 	// so we don't want to block the HTTP call while waiting on this.
 	go func(s *server.Server) {
+		if err := s.PurgeCommandHistory(context.Background()); err != nil {
+			s.Log().WithField("error", err).Warn("failed to remove command history during deletion process")
+		}
+		if err := s.PurgeInstallHistory(context.Background()); err != nil {
+			s.Log().WithField("error", err).Warn("failed to remove install history during deletion process")
+		}
+
 		fs := s.Filesystem()
 		p := fs.Path()
 		_ = fs.UnixFS().Close()
+		if err := fs.RemoveQuota(); err != nil {
+			log.WithFields(log.Fields{"path": p, "error": err}).Warn("failed to remove disk quota during deletion process")
+		}
 		if err := os.RemoveAll(p); err != nil {
 			log.WithFields(log.Fields{"path": p, "error": err}).Warn("failed to remove server files during deletion process")
 		}
@@ -85,6 +85,18 @@ func getServerListDirectory(c *gin.Context) {
 	}
 }
 
+// Returns the disk space used by each of the immediate children of a directory
+// for a server, along with the largest files within it.
+func getServerDirectoryUsage(c *gin.Context) {
+	s := ExtractServer(c)
+	usage, err := s.Filesystem().DirectoryUsage(c.Query("directory"))
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
+
 type renameFile struct {
 	To   string `json:"to"`
 	From string `json:"from"`
@@ -21,6 +21,12 @@ type serverTransferRequest struct {
 	URL    string                  `binding:"required" json:"url"`
 	Token  string                  `binding:"required" json:"token"`
 	Server installer.ServerDetails `json:"server"`
+	// Incremental copies the server's files to the target while the server is still
+	// running, and only stops the server to send the files that changed since.
+	Incremental bool `json:"incremental"`
+	// Backups is the UUIDs of the server's local backups that should be sent to
+	// the target node along with the server's files.
+	Backups []string `json:"backups"`
 }
 
 // postServerTransfer handles the start of a transfer for a server.
@@ -61,26 +67,50 @@ func postServerTransfer(c *gin.Context) {
 
 	// Ensure the server is offline. Sometimes a "No such container" error gets through
 	// which means the server is already stopped. We can ignore that.
-	if s.Environment.State() != environment.ProcessOfflineState {
-		if err := s.Environment.WaitForStop(
-			s.Context(),
-			time.Second*15,
-			false,
-		); err != nil && !strings.Contains(strings.ToLower(err.Error()), "no such container") {
+	stop := func() error {
+		if s.Environment.State() != environment.ProcessOfflineState {
+			if err := s.Environment.WaitForStop(
+				s.Context(),
+				time.Second*15,
+				false,
+			); err != nil && !strings.Contains(strings.ToLower(err.Error()), "no such container") {
+				return errors.Wrap(err, "failed to stop server for transfer")
+			}
+		}
+		return nil
+	}
+
+	// Incremental transfers only stop the server once the bulk of the files have
+	// been copied to the target.
+	if !data.Incremental {
+		if err := stop(); err != nil {
 			s.SetTransferring(false)
-			middleware.CaptureAndAbort(c, errors.Wrap(err, "failed to stop server for transfer"))
+			middleware.CaptureAndAbort(c, err)
 			return
 		}
 	}
 
 	// Create a new transfer instance for this server.
 	trnsfr := transfer.New(context.Background(), s)
+	trnsfr.Backups = data.Backups
 	transfer.Outgoing().Add(trnsfr)
 
 	go func() {
 		defer transfer.Outgoing().Remove(trnsfr)
 
-		if _, err := trnsfr.PushArchiveToTarget(data.URL, data.Token); err != nil {
+		var err error
+		if data.Incremental {
+			err = trnsfr.PushIncrementalToTarget(data.URL, data.Token, stop)
+			if errors.Is(err, transfer.ErrIncrementalUnsupported) {
+				trnsfr.Log().Warn("target does not support incremental transfers, falling back to an archive")
+				if err = stop(); err == nil {
+					_, err = trnsfr.PushArchiveToTarget(data.URL, data.Token)
+				}
+			}
+		} else {
+			_, err = trnsfr.PushArchiveToTarget(data.URL, data.Token)
+		}
+		if err != nil {
 			notifyPanelOfFailure()
 
 			if err == context.Canceled {
@@ -89,7 +119,7 @@ func postServerTransfer(c *gin.Context) {
 				return
 			}
 
-			trnsfr.Log().WithError(err).Error("failed to push archive to target")
+			trnsfr.Log().WithError(err).Error("failed to push server files to target")
 			return
 		}
 
@@ -2,8 +2,11 @@ package router
 
 import (
 	"context"
+	"net/http"
+	"sync"
 	"time"
 
+	"emperror.dev/errors"
 	"github.com/gin-gonic/gin"
 	"github.com/goccy/go-json"
 	ws "github.com/gorilla/websocket"
@@ -20,6 +23,34 @@ var expectedCloseCodes = []int{
 	ws.CloseServiceRestart,
 }
 
+// Tracks the open websocket connections so that they can be closed before Wings
+// exits during a graceful restart. Hijacked connections are not closed by the HTTP
+// server when shutting down.
+var (
+	websockets        sync.WaitGroup
+	closeWebsockets   = make(chan struct{})
+	closeWebsocketsMu sync.Once
+)
+
+// CloseWebsockets sends a close frame to every open websocket, telling clients that
+// Wings is restarting so that they reconnect, and waits for the connections to close.
+func CloseWebsockets(ctx context.Context) error {
+	closeWebsocketsMu.Do(func() {
+		close(closeWebsockets)
+	})
+	done := make(chan struct{})
+	go func() {
+		websockets.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // Upgrades a connection to a websocket and passes events along between.
 func getServerWebsocket(c *gin.Context) {
 	manager := middleware.ExtractManager(c)
@@ -34,10 +65,18 @@ func getServerWebsocket(c *gin.Context) {
 
 	handler, err := websocket.GetHandler(s, c.Writer, c.Request, c)
 	if err != nil {
+		if errors.Is(err, websocket.ErrUnsupportedProtocol) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "The requested websocket protocol version is not supported.",
+			})
+			return
+		}
 		middleware.CaptureAndAbort(c, err)
 		return
 	}
 	defer handler.Connection.Close()
+	websockets.Add(1)
+	defer websockets.Done()
 
 	// Track this open connection on the server so that we can close them all programmatically
 	// if the server is deleted.
@@ -60,6 +99,15 @@ func getServerWebsocket(c *gin.Context) {
 		case <-s.Context().Done():
 			_ = handler.Connection.WriteControl(ws.CloseMessage, ws.FormatCloseMessage(ws.CloseGoingAway, "server deleted"), time.Now().Add(time.Second*5))
 			break
+		case <-closeWebsockets:
+			_ = handler.Connection.WriteControl(ws.CloseMessage, ws.FormatCloseMessage(ws.CloseServiceRestart, "daemon restarting"), time.Now().Add(time.Second*5))
+			// Clients respond to the close frame, but close the connection anyway
+			// after a short time in case they don't.
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second * 5):
+				_ = handler.Connection.Close()
+			}
 		}
 	}()
 
@@ -84,7 +132,9 @@ func getServerWebsocket(c *gin.Context) {
 		go func(msg websocket.Message) {
 			if err := handler.HandleInbound(ctx, msg); err != nil {
 				_ = handler.SendErrorJson(msg, err)
+				return
 			}
+			_ = handler.Acknowledge(msg)
 		}(j)
 	}
 }
@@ -24,8 +24,17 @@ func getSystemInformation(c *gin.Context) {
 		return
 	}
 
+	// Degraded is true while the servers are running from the locally cached
+	// configurations because the Panel could not be reached when Wings booted.
+	degraded := middleware.ExtractManager(c).Degraded()
 	if c.Query("v") == "2" {
-		c.JSON(http.StatusOK, i)
+		c.JSON(http.StatusOK, struct {
+			*system.Information
+			Degraded bool `json:"degraded"`
+		}{
+			Information: i,
+			Degraded:    degraded,
+		})
 		return
 	}
 
@@ -35,12 +44,14 @@ func getSystemInformation(c *gin.Context) {
 		KernelVersion string `json:"kernel_version"`
 		OS            string `json:"os"`
 		Version       string `json:"version"`
+		Degraded      bool   `json:"degraded"`
 	}{
 		Architecture:  i.System.Architecture,
 		CPUCount:      i.System.CPUThreads,
 		KernelVersion: i.System.KernelVersion,
 		OS:            i.System.OSType,
 		Version:       i.Version,
+		Degraded:      degraded,
 	})
 }
 
@@ -48,9 +59,14 @@ func getSystemInformation(c *gin.Context) {
 // this wings instance.
 func getAllServers(c *gin.Context) {
 	servers := middleware.ExtractManager(c).All()
-	out := make([]server.APIResponse, len(servers), len(servers))
-	for i, v := range servers {
-		out[i] = v.ToAPIResponse()
+	out := make([]server.APIResponse, 0, len(servers))
+	key := middleware.ExtractApiKey(c)
+	for _, v := range servers {
+		// API keys limited to specific servers only see those servers.
+		if key != nil && !key.CanAccessServer(v.ID()) {
+			continue
+		}
+		out = append(out, serverAPIResponse(c, v))
 	}
 	c.JSON(http.StatusOK, out)
 }
@@ -115,20 +131,30 @@ func postCreateServer(c *gin.Context) {
 
 type postUpdateConfigurationResponse struct {
 	Applied bool `json:"applied"`
+	// The settings that were changed, and whether they are in use or require
+	// Wings to be restarted first.
+	*config.ReloadResult
 }
 
-// Updates the running configuration for this Wings instance.
+// Updates the running configuration for this Wings instance. The update is applied
+// on top of the configuration file rather than the running configuration, so that
+// settings the Panel does not send and changes to the file that are waiting for a
+// restart are kept.
 func postUpdateConfiguration(c *gin.Context) {
-	cfg := config.Get()
-
-	if cfg.IgnorePanelConfigUpdates {
+	if config.Get().IgnorePanelConfigUpdates {
 		c.JSON(http.StatusOK, postUpdateConfigurationResponse{
 			Applied: false,
 		})
 		return
 	}
 
-	if err := c.BindJSON(&cfg); err != nil {
+	cfg, err := config.FromDisk()
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	ssl := cfg.Api.Ssl
+	if err := c.BindJSON(cfg); err != nil {
 		return
 	}
 
@@ -138,8 +164,8 @@ func postUpdateConfiguration(c *gin.Context) {
 	//
 	// If you pass through manual locations in the API call this logic will be skipped.
 	if strings.HasPrefix(cfg.Api.Ssl.KeyFile, "/etc/letsencrypt/live/") {
-		cfg.Api.Ssl.KeyFile = config.Get().Api.Ssl.KeyFile
-		cfg.Api.Ssl.CertificateFile = config.Get().Api.Ssl.CertificateFile
+		cfg.Api.Ssl.KeyFile = ssl.KeyFile
+		cfg.Api.Ssl.CertificateFile = ssl.CertificateFile
 	}
 
 	// Try to write this new configuration to the disk before updating our global
@@ -149,9 +175,21 @@ func postUpdateConfiguration(c *gin.Context) {
 		return
 	}
 	// Since we wrote it to the disk successfully now update the global configuration
-	// state to use this new configuration struct.
-	config.Set(cfg)
+	// state to use this new configuration struct, and reconfigure any subsystems using
+	// the changed settings.
 	c.JSON(http.StatusOK, postUpdateConfigurationResponse{
-		Applied: true,
+		Applied:      true,
+		ReloadResult: config.Reload(cfg),
 	})
 }
+
+// Reloads the configuration from the configuration file on the disk, returning
+// the settings that were changed.
+func postReloadConfiguration(c *gin.Context) {
+	res, err := config.ReloadFromDisk()
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
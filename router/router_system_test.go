@@ -0,0 +1,85 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/franela/goblin"
+	"github.com/gin-gonic/gin"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestPostUpdateConfiguration(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("postUpdateConfiguration", func() {
+		var p string
+
+		g.BeforeEach(func() {
+			p = filepath.Join(t.TempDir(), "config.yml")
+
+			// The file has settings the Panel never sends, and a changed port that is
+			// waiting for a restart.
+			disk, err := config.NewAtPath(p)
+			g.Assert(err).IsNil()
+			disk.AuthenticationToken = "abc"
+			disk.Api.Port = 9090
+			disk.Api.Mtls.Enabled = true
+			disk.Api.Mtls.ClientCAFile = "/etc/pterodactyl/ca.pem"
+			disk.System.Logging.AccessLog = false
+			g.Assert(config.WriteToDisk(disk)).IsNil()
+
+			running, err := config.NewAtPath(p)
+			g.Assert(err).IsNil()
+			running.AuthenticationToken = "abc"
+			config.Set(running)
+		})
+
+		update := func(body string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/update", strings.NewReader(body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			postUpdateConfiguration(c)
+			return w
+		}
+
+		g.It("applies the update on top of the configuration file", func() {
+			w := update(`{"token":"def","throttles":{"lines":100}}`)
+			g.Assert(w.Code).Equal(http.StatusOK)
+
+			disk, err := config.Load(p)
+			g.Assert(err).IsNil()
+			g.Assert(disk.AuthenticationToken).Equal("def")
+			g.Assert(disk.Throttles.Lines).Equal(uint64(100))
+			g.Assert(disk.Api.Port).Equal(9090)
+			g.Assert(disk.Api.Mtls.Enabled).IsTrue()
+			g.Assert(disk.Api.Mtls.ClientCAFile).Equal("/etc/pterodactyl/ca.pem")
+			g.Assert(disk.System.Logging.AccessLog).IsFalse()
+
+			running := config.Get()
+			g.Assert(running.AuthenticationToken).Equal("def")
+			g.Assert(running.Throttles.Lines).Equal(uint64(100))
+			g.Assert(running.Api.Port).Equal(8080)
+			g.Assert(running.Api.Mtls.Enabled).IsFalse()
+		})
+
+		g.It("does not change anything when Panel updates are ignored", func() {
+			c := config.Get()
+			c.IgnorePanelConfigUpdates = true
+			config.Set(c)
+
+			w := update(`{"token":"def"}`)
+			g.Assert(w.Code).Equal(http.StatusOK)
+			g.Assert(config.Get().AuthenticationToken).Equal("abc")
+
+			disk, err := config.Load(p)
+			g.Assert(err).IsNil()
+			g.Assert(disk.AuthenticationToken).Equal("abc")
+		})
+	})
+}
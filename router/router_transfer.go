@@ -27,99 +27,39 @@ import (
 
 // postTransfers .
 func postTransfers(c *gin.Context) {
-	auth := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
-	if len(auth) != 2 || auth[0] != "Bearer" {
-		c.Header("WWW-Authenticate", "Bearer")
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-			"error": "The required authorization heads were not present in the request.",
-		})
-		return
-	}
-
-	token := tokens.TransferPayload{}
-	if err := tokens.ParseToken([]byte(auth[1]), &token); err != nil {
-		middleware.CaptureAndAbort(c, err)
+	u, ok := parseTransferToken(c)
+	if !ok {
 		return
 	}
-
 	manager := middleware.ExtractManager(c)
-	u, err := uuid.Parse(token.Subject)
-	if err != nil {
-		middleware.CaptureAndAbort(c, err)
-		return
-	}
 
 	// Get or create a new transfer instance for this server.
 	var (
 		ctx    context.Context
 		cancel context.CancelFunc
 	)
-	trnsfr := transfer.Incoming().Get(u.String())
-	if trnsfr == nil {
+	trnsfr, _, err := transfer.Incoming().GetOrCreate(u.String(), func() (*transfer.Transfer, error) {
 		// TODO: should this use the request context?
-		trnsfr = transfer.New(c, nil)
-
-		ctx, cancel = context.WithCancel(trnsfr.Context())
-		defer cancel()
-
-		i, err := installer.New(ctx, manager, installer.ServerDetails{
-			UUID:              u.String(),
-			StartOnCompletion: false,
-		})
-		if err != nil {
-			if err := manager.Client().SetTransferStatus(context.Background(), trnsfr.Server.ID(), false); err != nil {
-				trnsfr.Log().WithField("status", false).WithError(err).Error("failed to set transfer status")
-			}
-			middleware.CaptureAndAbort(c, err)
-			return
+		t := transfer.New(c, nil)
+		if err := addIncomingTransfer(t.Context(), manager, t, u); err != nil {
+			return nil, err
 		}
-
-		i.Server().SetTransferring(true)
-		manager.Add(i.Server())
-
-		// We add the transfer to the list of transfers once we have a server instance to use.
-		trnsfr.Server = i.Server()
-		transfer.Incoming().Add(trnsfr)
-	} else {
-		ctx, cancel = context.WithCancel(trnsfr.Context())
-		defer cancel()
+		return t, nil
+	})
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
 	}
+	ctx, cancel = context.WithCancel(trnsfr.Context())
+	defer cancel()
 
 	// Any errors past this point (until the transfer is complete) will abort
 	// the transfer.
 
 	successful := false
-	defer func(ctx context.Context, trnsfr *transfer.Transfer) {
-		// Remove the transfer from the list of incoming transfers.
-		transfer.Incoming().Remove(trnsfr)
-
-		if !successful {
-			trnsfr.Server.Events().Publish(server.TransferStatusEvent, "failure")
-			manager.Remove(func(match *server.Server) bool {
-				return match.ID() == trnsfr.Server.ID()
-			})
-		}
-
-		if err := manager.Client().SetTransferStatus(context.Background(), trnsfr.Server.ID(), successful); err != nil {
-			// Only delete the files if the transfer actually failed, otherwise we could have
-			// unrecoverable data-loss.
-			if !successful && err != nil {
-				// Delete all extracted files.
-				go func(trnsfr *transfer.Transfer) {
-					_ = trnsfr.Server.Filesystem().UnixFS().Close()
-					if err := os.RemoveAll(trnsfr.Server.Filesystem().Path()); err != nil && !os.IsNotExist(err) {
-						trnsfr.Log().WithError(err).Warn("failed to delete local server files")
-					}
-				}(trnsfr)
-			}
-
-			trnsfr.Log().WithField("status", successful).WithError(err).Error("failed to set transfer status on panel")
-			return
-		}
-
-		trnsfr.Server.SetTransferring(false)
-		trnsfr.Server.Events().Publish(server.TransferStatusEvent, "success")
-	}(ctx, trnsfr)
+	defer func() {
+		_ = completeIncomingTransfer(manager, trnsfr, successful)
+	}()
 
 	mediaType, params, err := mime.ParseMediaType(c.GetHeader("Content-Type"))
 	if err != nil {
@@ -146,6 +86,13 @@ func postTransfers(c *gin.Context) {
 		hasChecksum      bool
 		checksumVerified bool
 	)
+	// Backups that have been received but not yet verified against their checksum.
+	backups := make(map[string]*transfer.IncomingBackup)
+	defer func() {
+		for _, ib := range backups {
+			ib.Discard()
+		}
+	}()
 out:
 	for {
 		select {
@@ -163,6 +110,17 @@ out:
 
 			name := p.FormName()
 			switch name {
+			case transfer.BackupsPart:
+				v, err := io.ReadAll(p)
+				if err != nil {
+					middleware.CaptureAndAbort(c, err)
+					return
+				}
+				var ids []string
+				if len(v) > 0 {
+					ids = strings.Split(string(v), ",")
+				}
+				trnsfr.DeclareBackups(ids)
 			case "archive":
 				trnsfr.Log().Debug("received archive")
 
@@ -171,7 +129,7 @@ out:
 					return
 				}
 
-				tee := io.TeeReader(p, h)
+				tee := io.TeeReader(trnsfr.LimitDownload(p), h)
 				if err := trnsfr.Server.Filesystem().ExtractStreamUnsafe(ctx, "/", tee); err != nil {
 					middleware.CaptureAndAbort(c, err)
 					return
@@ -215,6 +173,39 @@ out:
 				trnsfr.Log().Debug("checksums match")
 				checksumVerified = true
 			default:
+				if id, ok := strings.CutPrefix(name, transfer.BackupPartPrefix); ok {
+					trnsfr.Log().WithField("backup", id).Debug("received backup")
+
+					ib, err := trnsfr.ReceiveBackup(id, p)
+					if err != nil {
+						middleware.CaptureAndAbort(c, err)
+						return
+					}
+					backups[id] = ib
+					continue
+				}
+
+				if id, ok := strings.CutPrefix(name, transfer.BackupChecksumPartPrefix); ok {
+					ib, ok := backups[id]
+					if !ok {
+						middleware.CaptureAndAbort(c, errors.New("backup must be sent before its checksum"))
+						return
+					}
+					delete(backups, id)
+
+					v, err := io.ReadAll(p)
+					if err != nil {
+						ib.Discard()
+						middleware.CaptureAndAbort(c, err)
+						return
+					}
+					if err := ib.Verify(string(v)); err != nil {
+						middleware.CaptureAndAbort(c, fmt.Errorf("failed to verify backup %s: %w", id, err))
+						return
+					}
+					trnsfr.AddReceivedBackup(ib)
+					continue
+				}
 				continue
 			}
 		}
@@ -244,9 +235,111 @@ out:
 	// rather than failing the transfer like we do by default.
 	successful = true
 
-	// The rest of the logic for ensuring the server is unlocked and everything
-	// is handled in the deferred function above.
+	// The Panel must be notified before responding, since the source node deletes
+	// the backups listed in the response once it receives it.
+	if err := completeIncomingTransfer(manager, trnsfr, true); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
 	trnsfr.Log().Debug("done!")
+
+	c.JSON(http.StatusOK, gin.H{"backups": trnsfr.ReceivedBackups()})
+}
+
+// parseTransferToken parses the bearer token sent by the source node for a
+// transfer and returns the UUID of the server being transferred. If the token
+// is missing or invalid the request is aborted and false is returned.
+func parseTransferToken(c *gin.Context) (uuid.UUID, bool) {
+	auth := strings.SplitN(c.GetHeader("Authorization"), " ", 2)
+	if len(auth) != 2 || auth[0] != "Bearer" {
+		c.Header("WWW-Authenticate", "Bearer")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "The required authorization heads were not present in the request.",
+		})
+		return uuid.UUID{}, false
+	}
+
+	token := tokens.TransferPayload{}
+	if err := tokens.ParseToken([]byte(auth[1]), &token); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return uuid.UUID{}, false
+	}
+
+	u, err := uuid.Parse(token.Subject)
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return uuid.UUID{}, false
+	}
+	return u, true
+}
+
+// addIncomingTransfer creates the server instance for an incoming transfer and
+// adds the transfer to the list of incoming transfers.
+func addIncomingTransfer(ctx context.Context, manager *server.Manager, trnsfr *transfer.Transfer, u uuid.UUID) error {
+	i, err := installer.New(ctx, manager, installer.ServerDetails{
+		UUID:              u.String(),
+		StartOnCompletion: false,
+	})
+	if err != nil {
+		if err := manager.Client().SetTransferStatus(context.Background(), u.String(), false); err != nil {
+			trnsfr.Log().WithField("status", false).WithError(err).Error("failed to set transfer status")
+		}
+		return err
+	}
+
+	i.Server().SetTransferring(true)
+	manager.Add(i.Server())
+
+	// We add the transfer to the list of transfers once we have a server instance to use.
+	trnsfr.Server = i.Server()
+	transfer.Incoming().Add(trnsfr)
+	return nil
+}
+
+// completeIncomingTransfer removes an incoming transfer and notifies the Panel
+// of the outcome. If the transfer was not successful the server is removed from
+// this node, and its files are deleted. An error is returned if the Panel could
+// not be notified, or if the transfer was already completed by another request.
+func completeIncomingTransfer(manager *server.Manager, trnsfr *transfer.Transfer, successful bool) error {
+	// Remove the transfer from the list of incoming transfers. If it has already
+	// been removed then another request has already completed it.
+	if !transfer.Incoming().Take(trnsfr) {
+		return errors.New("transfer has already been completed")
+	}
+
+	if !successful {
+		trnsfr.DiscardReceivedBackups()
+		trnsfr.Server.Events().Publish(server.TransferStatusEvent, "failure")
+		manager.Remove(func(match *server.Server) bool {
+			return match.ID() == trnsfr.Server.ID()
+		})
+	}
+
+	if err := manager.Client().SetTransferStatus(context.Background(), trnsfr.Server.ID(), successful); err != nil {
+		// Only delete the files if the transfer actually failed, otherwise we could have
+		// unrecoverable data-loss.
+		if !successful && err != nil {
+			// Delete all extracted files.
+			go func(trnsfr *transfer.Transfer) {
+				fs := trnsfr.Server.Filesystem()
+				_ = fs.UnixFS().Close()
+				if err := fs.RemoveQuota(); err != nil {
+					trnsfr.Log().WithError(err).Warn("failed to remove disk quota for local server files")
+				}
+				if err := os.RemoveAll(fs.Path()); err != nil && !os.IsNotExist(err) {
+					trnsfr.Log().WithError(err).Warn("failed to delete local server files")
+				}
+			}(trnsfr)
+		}
+
+		trnsfr.Log().WithField("status", successful).WithError(err).Error("failed to set transfer status on panel")
+		return err
+	}
+
+	trnsfr.Server.SetTransferring(false)
+	trnsfr.Server.Events().Publish(server.TransferStatusEvent, "success")
+	return nil
 }
 
 // deleteTransfer cancels an incoming transfer for a server.
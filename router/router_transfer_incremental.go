@@ -0,0 +1,249 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/gin-gonic/gin"
+
+	"github.com/pterodactyl/wings/router/middleware"
+	"github.com/pterodactyl/wings/server"
+	"github.com/pterodactyl/wings/server/transfer"
+)
+
+// The amount of time an incremental transfer may go without receiving any data
+// from the source node before it is considered to have failed.
+const incrementalTransferIdleTimeout = time.Minute * 30
+
+// getIncomingTransfer returns the incoming transfer for the server in the token
+// sent by the source node, aborting the request if there is no such transfer.
+func getIncomingTransfer(c *gin.Context) *transfer.Transfer {
+	u, ok := parseTransferToken(c)
+	if !ok {
+		return nil
+	}
+	trnsfr := transfer.Incoming().Get(u.String())
+	if trnsfr == nil {
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{
+			"error": "There is no incremental transfer in progress for this server.",
+		})
+		return nil
+	}
+	trnsfr.Touch()
+	return trnsfr
+}
+
+// postIncrementalTransfer starts an incremental transfer of a server to this node,
+// or resumes one that is already in progress. The manifest of the files that have
+// already been received is returned so the source node only sends what is missing.
+func postIncrementalTransfer(c *gin.Context) {
+	u, ok := parseTransferToken(c)
+	if !ok {
+		return
+	}
+	manager := middleware.ExtractManager(c)
+
+	// The source node declares the backups it will send for the server, any other
+	// backups it sends are rejected.
+	var data struct {
+		Backups []string `json:"backups"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&data); err != nil {
+			return
+		}
+	}
+
+	trnsfr, created, err := transfer.Incoming().GetOrCreate(u.String(), func() (*transfer.Transfer, error) {
+		t := transfer.New(context.Background(), nil)
+		if err := addIncomingTransfer(t.Context(), manager, t, u); err != nil {
+			return nil, err
+		}
+		if err := t.Server.EnsureDataDirectoryExists(); err != nil {
+			_ = completeIncomingTransfer(manager, t, false)
+			return nil, err
+		}
+		return t, nil
+	})
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	if created {
+		trnsfr.SetStatus(transfer.StatusProcessing)
+		go watchIncrementalTransfer(manager, trnsfr)
+	}
+	trnsfr.DeclareBackups(data.Backups)
+	trnsfr.Touch()
+
+	m, err := transfer.BuildManifest(trnsfr.Server.Filesystem())
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"manifest": m})
+}
+
+// headIncrementalTransferFile returns the number of bytes that have been received
+// for a file in the X-Transfer-Offset header.
+func headIncrementalTransferFile(c *gin.Context) {
+	trnsfr := getIncomingTransfer(c)
+	if trnsfr == nil {
+		return
+	}
+
+	offset, err := trnsfr.PartialOffset(c.Query("path"))
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.Header("X-Transfer-Offset", strconv.FormatInt(offset, 10))
+	c.Status(http.StatusOK)
+}
+
+// putIncrementalTransferFile receives a single chunk of an entry from the source
+// node. If the offset of the chunk does not match the data that has already been
+// received a 409 is returned along with the offset to resume from.
+func putIncrementalTransferFile(c *gin.Context) {
+	trnsfr := getIncomingTransfer(c)
+	if trnsfr == nil {
+		return
+	}
+
+	var chunk transfer.Chunk
+	var err error
+	chunk.Path = c.Query("path")
+	chunk.Type = c.Query("type")
+	chunk.Target = c.Query("target")
+	chunk.Checksum = c.Query("checksum")
+	chunk.FileChecksum = c.Query("file_checksum")
+	for k, v := range map[string]*int64{"size": &chunk.Size, "mtime": &chunk.ModTime, "offset": &chunk.Offset} {
+		if *v, err = strconv.ParseInt(c.DefaultQuery(k, "0"), 10, 64); err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid value provided for \"" + k + "\"."})
+			return
+		}
+	}
+	mode, err := strconv.ParseUint(c.DefaultQuery("mode", "0"), 10, 32)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid value provided for \"mode\"."})
+		return
+	}
+	chunk.Mode = uint32(mode)
+
+	if err := trnsfr.WriteChunk(chunk, c.Request.Body); err != nil {
+		switch {
+		case errors.Is(err, transfer.ErrOffsetMismatch):
+			offset, _ := trnsfr.PartialOffset(chunk.Path)
+			c.Header("X-Transfer-Offset", strconv.FormatInt(offset, 10))
+			c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, transfer.ErrChecksumMismatch), errors.Is(err, transfer.ErrInvalidPath):
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			middleware.CaptureAndAbort(c, err)
+		}
+		return
+	}
+	trnsfr.Touch()
+
+	c.Status(http.StatusNoContent)
+}
+
+// putIncrementalTransferBackup receives a local backup for the server from the
+// source node and places it in this node's backup directory once its checksum
+// has been verified.
+func putIncrementalTransferBackup(c *gin.Context) {
+	trnsfr := getIncomingTransfer(c)
+	if trnsfr == nil {
+		return
+	}
+
+	ib, err := trnsfr.ReceiveBackup(c.Query("backup"), c.Request.Body)
+	if err != nil {
+		if errors.Is(err, transfer.ErrInvalidBackup) || errors.Is(err, transfer.ErrBackupExists) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	if err := ib.Verify(c.Query("checksum")); err != nil {
+		if errors.Is(err, transfer.ErrChecksumMismatch) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	trnsfr.AddReceivedBackup(ib)
+	trnsfr.Touch()
+
+	c.Status(http.StatusNoContent)
+}
+
+// postIncrementalTransferComplete is called by the source node once every file has
+// been sent. Any files that are not in the final manifest from the source node are
+// removed, and the Panel is notified that the transfer has completed.
+func postIncrementalTransferComplete(c *gin.Context) {
+	trnsfr := getIncomingTransfer(c)
+	if trnsfr == nil {
+		return
+	}
+	manager := middleware.ExtractManager(c)
+
+	var data struct {
+		Manifest transfer.Manifest `json:"manifest"`
+	}
+	if err := c.BindJSON(&data); err != nil {
+		return
+	}
+
+	if err := trnsfr.Finalize(data.Manifest); err != nil {
+		_ = completeIncomingTransfer(manager, trnsfr, false)
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	// Ensure the server environment gets configured.
+	if err := trnsfr.Server.CreateEnvironment(); err != nil {
+		_ = completeIncomingTransfer(manager, trnsfr, false)
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	// The Panel must be notified before responding, since the source node deletes
+	// the backups listed in the response once it receives it.
+	if err := completeIncomingTransfer(manager, trnsfr, true); err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"backups": trnsfr.ReceivedBackups()})
+}
+
+// watchIncrementalTransfer fails an incremental transfer if it is canceled, or if
+// the source node stops sending data for too long.
+func watchIncrementalTransfer(manager *server.Manager, trnsfr *transfer.Transfer) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-trnsfr.Context().Done():
+			_ = completeIncomingTransfer(manager, trnsfr, false)
+			return
+		case <-ticker.C:
+			if transfer.Incoming().Get(trnsfr.Server.ID()) != trnsfr {
+				return
+			}
+			if trnsfr.IdleFor() > incrementalTransferIdleTimeout {
+				trnsfr.Log().Warn("incremental transfer timed out waiting for data from source node")
+				trnsfr.Cancel()
+			}
+		}
+	}
+}
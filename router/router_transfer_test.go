@@ -0,0 +1,91 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	. "github.com/franela/goblin"
+	"github.com/goccy/go-json"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/remote"
+	"github.com/pterodactyl/wings/server"
+	"github.com/pterodactyl/wings/server/transfer"
+)
+
+// newTestIncomingTransfer returns an incoming transfer for a server on a manager
+// using a Panel that responds to every request with the given status.
+func newTestIncomingTransfer(status int) (*server.Manager, *transfer.Transfer, func()) {
+	panel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(`{"errors":[]}`))
+	}))
+
+	dir, err := os.MkdirTemp(os.TempDir(), "pterodactyl")
+	if err != nil {
+		panic(err)
+	}
+	config.Set(&config.Configuration{
+		AuthenticationToken: "abc",
+		System: config.SystemConfiguration{
+			RootDirectory: "/server",
+			Data:          dir,
+		},
+	})
+
+	m := server.NewEmptyManager(remote.New(panel.URL))
+	s, err := m.InitServer(remote.ServerConfigurationResponse{
+		Settings: json.RawMessage(`{"uuid":"00000000-0000-0000-0000-000000000000"}`),
+	})
+	if err != nil {
+		panic(err)
+	}
+	s.SetTransferring(true)
+	m.Add(s)
+
+	trnsfr := transfer.New(context.Background(), s)
+	transfer.Incoming().Add(trnsfr)
+	return m, trnsfr, func() {
+		transfer.Incoming().Remove(trnsfr)
+		panel.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestCompleteIncomingTransfer(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("completeIncomingTransfer", func() {
+		g.It("completes the transfer once the Panel accepts it", func() {
+			m, trnsfr, done := newTestIncomingTransfer(http.StatusNoContent)
+			defer done()
+
+			g.Assert(completeIncomingTransfer(m, trnsfr, true)).IsNil()
+			g.Assert(trnsfr.Server.IsTransferring()).IsFalse()
+			g.Assert(transfer.Incoming().Get(trnsfr.Server.ID()) == nil).IsTrue()
+			_, ok := m.Get(trnsfr.Server.ID())
+			g.Assert(ok).IsTrue()
+		})
+
+		g.It("returns an error if the Panel does not accept the transfer", func() {
+			m, trnsfr, done := newTestIncomingTransfer(http.StatusUnprocessableEntity)
+			defer done()
+
+			g.Assert(completeIncomingTransfer(m, trnsfr, true) == nil).IsFalse()
+			g.Assert(trnsfr.Server.IsTransferring()).IsTrue()
+		})
+
+		g.It("returns an error if the transfer was already completed", func() {
+			m, trnsfr, done := newTestIncomingTransfer(http.StatusNoContent)
+			defer done()
+
+			g.Assert(completeIncomingTransfer(m, trnsfr, false)).IsNil()
+			g.Assert(completeIncomingTransfer(m, trnsfr, true) == nil).IsFalse()
+			_, ok := m.Get(trnsfr.Server.ID())
+			g.Assert(ok).IsFalse()
+		})
+	})
+}
@@ -0,0 +1,53 @@
+package router
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/goccy/go-json"
+	ws "github.com/gorilla/websocket"
+
+	"github.com/pterodactyl/wings/router/middleware"
+	"github.com/pterodactyl/wings/router/websocket"
+)
+
+// Upgrades a connection to a node level websocket which sends the status and
+// stats events for every server listed in the connection's JWT.
+func getNodeWebsocket(c *gin.Context) {
+	manager := middleware.ExtractManager(c)
+
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+
+	handler, err := websocket.GetMultiHandler(manager, c.Writer, c.Request)
+	if err != nil {
+		middleware.CaptureAndAbort(c, err)
+		return
+	}
+	defer handler.Connection.Close()
+
+	handler.Logger().Debug("opening connection to node websocket")
+	defer handler.Logger().Debug("closing connection to node websocket")
+
+	for {
+		j := websocket.Message{}
+
+		_, p, err := handler.Connection.ReadMessage()
+		if err != nil {
+			if ws.IsUnexpectedCloseError(err, expectedCloseCodes...) {
+				handler.Logger().WithField("error", err).Warn("error handling node websocket message")
+			}
+			break
+		}
+
+		if err := json.Unmarshal(p, &j); err != nil {
+			continue
+		}
+
+		go func(msg websocket.Message) {
+			if err := handler.HandleInbound(ctx, msg); err != nil {
+				_ = handler.SendErrorJson(msg, err)
+			}
+		}(j)
+	}
+}
@@ -65,6 +65,78 @@ func (p *WebsocketPayload) GetServerUuid() string {
 // before Wings was booted, or because we have denied all tokens with the same JTI
 // occurring before a set time.
 func (p *WebsocketPayload) Denylisted() bool {
+	return isDenylisted(&p.Payload)
+}
+
+// Checks if the given token payload has a permission string.
+func (p *WebsocketPayload) HasPermission(permission string) bool {
+	p.RLock()
+	defer p.RUnlock()
+
+	for _, k := range p.Permissions {
+		if k == permission || (!strings.HasPrefix(permission, "admin") && k == "*") {
+			return !p.Denylisted()
+		}
+	}
+
+	return false
+}
+
+// MultiServerWebsocketPayload defines the JWT payload for a node level websocket
+// connection that receives events for multiple servers at once. Each server the
+// connection has access to is listed along with the permissions the user has for
+// that specific server.
+type MultiServerWebsocketPayload struct {
+	jwt.Payload
+	sync.RWMutex
+
+	UserUUID string              `json:"user_uuid"`
+	Servers  map[string][]string `json:"servers"`
+}
+
+// Returns the JWT payload.
+func (p *MultiServerWebsocketPayload) GetPayload() *jwt.Payload {
+	p.RLock()
+	defer p.RUnlock()
+
+	return &p.Payload
+}
+
+// Denylisted checks if the JWT has been marked as denied by the instance.
+func (p *MultiServerWebsocketPayload) Denylisted() bool {
+	return isDenylisted(&p.Payload)
+}
+
+// GetServerUuids returns the UUIDs of all servers listed in the token.
+func (p *MultiServerWebsocketPayload) GetServerUuids() []string {
+	p.RLock()
+	defer p.RUnlock()
+
+	out := make([]string, 0, len(p.Servers))
+	for k := range p.Servers {
+		out = append(out, k)
+	}
+	return out
+}
+
+// HasPermission checks if the token payload has a permission string for the
+// given server.
+func (p *MultiServerWebsocketPayload) HasPermission(server string, permission string) bool {
+	p.RLock()
+	defer p.RUnlock()
+
+	for _, k := range p.Servers[server] {
+		if k == permission || (!strings.HasPrefix(permission, "admin") && k == "*") {
+			return !p.Denylisted()
+		}
+	}
+
+	return false
+}
+
+// isDenylisted checks if a JWT payload was issued before Wings was booted, or if
+// we have denied all tokens with the same JTI occurring before a set time.
+func isDenylisted(p *jwt.Payload) bool {
 	// If there is no IssuedAt present for the token, we cannot validate the token so
 	// just immediately mark it as not valid.
 	if p.IssuedAt == nil {
@@ -87,17 +159,3 @@ func (p *WebsocketPayload) Denylisted() bool {
 
 	return false
 }
-
-// Checks if the given token payload has a permission string.
-func (p *WebsocketPayload) HasPermission(permission string) bool {
-	p.RLock()
-	defer p.RUnlock()
-
-	for _, k := range p.Permissions {
-		if k == permission || (!strings.HasPrefix(permission, "admin") && k == "*") {
-			return !p.Denylisted()
-		}
-	}
-
-	return false
-}
@@ -0,0 +1,68 @@
+package tokens
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+	"github.com/gbrlsnchs/jwt/v3"
+)
+
+func TestMultiServerWebsocketPayload_HasPermission(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("MultiServerWebsocketPayload#HasPermission", func() {
+		newPayload := func(issued time.Time) *MultiServerWebsocketPayload {
+			return &MultiServerWebsocketPayload{
+				Payload: jwt.Payload{IssuedAt: jwt.NumericDate(issued)},
+				Servers: map[string][]string{
+					"server-a": {"websocket.connect", "control.console"},
+					"server-b": {"control.start"},
+					"server-c": {"*"},
+				},
+			}
+		}
+
+		g.It("only grants the permissions listed for the server", func() {
+			p := newPayload(time.Now().Add(time.Second))
+			g.Assert(p.HasPermission("server-a", "websocket.connect")).IsTrue()
+			g.Assert(p.HasPermission("server-a", "control.start")).IsFalse()
+			g.Assert(p.HasPermission("server-b", "control.start")).IsTrue()
+			g.Assert(p.HasPermission("server-b", "websocket.connect")).IsFalse()
+		})
+
+		g.It("does not grant permissions for servers missing from the token", func() {
+			p := newPayload(time.Now().Add(time.Second))
+			g.Assert(p.HasPermission("server-d", "websocket.connect")).IsFalse()
+			g.Assert(p.HasPermission("", "websocket.connect")).IsFalse()
+		})
+
+		g.It("grants every non-admin permission for a wildcard", func() {
+			p := newPayload(time.Now().Add(time.Second))
+			g.Assert(p.HasPermission("server-c", "websocket.connect")).IsTrue()
+			g.Assert(p.HasPermission("server-c", "admin.websocket.errors")).IsFalse()
+		})
+
+		g.It("does not grant permissions for tokens issued before wings was booted", func() {
+			p := newPayload(wingsBootTime.Add(-time.Minute))
+			g.Assert(p.HasPermission("server-a", "websocket.connect")).IsFalse()
+		})
+
+		g.It("does not grant permissions for denied tokens", func() {
+			denylist.Store("multi-denied", time.Now().Add(time.Minute))
+
+			p := newPayload(time.Now().Add(time.Second))
+			p.JWTID = "multi-denied"
+			g.Assert(p.HasPermission("server-a", "websocket.connect")).IsFalse()
+
+			p = newPayload(time.Now().Add(time.Minute * 2))
+			p.JWTID = "multi-denied"
+			g.Assert(p.HasPermission("server-a", "websocket.connect")).IsTrue()
+		})
+
+		g.It("lists every server in the token", func() {
+			p := newPayload(time.Now().Add(time.Second))
+			g.Assert(len(p.GetServerUuids())).Equal(3)
+		})
+	})
+}
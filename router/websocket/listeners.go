@@ -1,6 +1,7 @@
 package websocket
 
 import (
+	"bytes"
 	"context"
 	"sync"
 	"time"
@@ -78,8 +79,17 @@ var e = []string{
 	server.BackupRestoreCompletedEvent,
 	server.TransferLogsEvent,
 	server.TransferStatusEvent,
+	server.TransferProgressEvent,
 }
 
+// The maximum amount of time console output is held before being flushed to a
+// client receiving binary console frames, and the maximum size of a single frame
+// before it is flushed regardless of the time.
+const (
+	binaryFlushInterval = time.Millisecond * 50
+	binaryFlushSize     = 32 * 1024
+)
+
 // ListenForServerEvents will listen for different events happening on a server
 // and send them along to the connected websocket client. This function will
 // block until the context provided to it is canceled.
@@ -98,6 +108,18 @@ func (h *Handler) listenForServerEvents(ctx context.Context) error {
 	h.server.Sink(system.LogSink).On(logOutput)
 	h.server.Sink(system.InstallSink).On(installOutput)
 
+	// Console output for clients that have requested binary frames is batched
+	// together and flushed on this ticker, or once the buffer gets large enough.
+	// Binary frames are only available to version 2 clients, so there is nothing
+	// to flush for version 1 clients.
+	var pending bytes.Buffer
+	var flush <-chan time.Time
+	if h.version >= ProtocolVersion2 {
+		t := time.NewTicker(binaryFlushInterval)
+		defer t.Stop()
+		flush = t.C
+	}
+
 	onError := func(evt string, err2 error) {
 		h.Logger().WithField("event", evt).WithField("error", err2).Error("failed to send event over server websocket")
 		// Avoid race conditions by only setting the error once and then canceling
@@ -109,17 +131,51 @@ func (h *Handler) listenForServerEvents(ctx context.Context) error {
 		cancel()
 	}
 
+	sendPending := func() error {
+		if pending.Len() == 0 {
+			return nil
+		}
+		defer pending.Reset()
+		return h.SendBinary(FrameConsoleOutput, pending.Bytes())
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
 			break
+		case <-flush:
+			if sendErr := sendPending(); sendErr != nil {
+				onError(server.ConsoleOutputEvent, sendErr)
+				break
+			}
+			continue
 		case b := <-logOutput:
+			if !h.subs.Allow(ChannelConsole) {
+				continue
+			}
+			if h.subs.Binary(ChannelConsole) {
+				if pending.Len() > 0 {
+					pending.WriteByte('\n')
+				}
+				pending.Write(b)
+				if pending.Len() < binaryFlushSize {
+					continue
+				}
+				if sendErr := sendPending(); sendErr != nil {
+					onError(server.ConsoleOutputEvent, sendErr)
+					break
+				}
+				continue
+			}
 			sendErr := h.SendJson(Message{Event: server.ConsoleOutputEvent, Args: []string{string(b)}})
 			if sendErr == nil {
 				continue
 			}
 			onError(server.ConsoleOutputEvent, sendErr)
 		case b := <-installOutput:
+			if !h.subs.Allow(ChannelInstall) {
+				continue
+			}
 			sendErr := h.SendJson(Message{Event: server.InstallOutputEvent, Args: []string{string(b)}})
 			if sendErr == nil {
 				continue
@@ -130,6 +186,9 @@ func (h *Handler) listenForServerEvents(ctx context.Context) error {
 			if err := events.DecodeTo(b, &e); err != nil {
 				continue
 			}
+			if !h.subs.Allow(ChannelForEvent(e.Topic)) {
+				continue
+			}
 			var sendErr error
 			message := Message{Event: e.Topic}
 			if str, ok := e.Data.(string); ok {
@@ -9,8 +9,33 @@ const (
 	SendServerLogsEvent        = "send logs"
 	SendCommandEvent           = "send command"
 	SendStatsEvent             = "send stats"
+	SendCommandHistoryEvent    = "send command history"
+	CommandHistoryEvent        = "command history"
 	ErrorEvent                 = "daemon error"
 	JwtErrorEvent              = "jwt error"
+
+	// Events only available to clients using version 2 of the protocol.
+	SubscribeEvent     = "subscribe"
+	UnsubscribeEvent   = "unsubscribe"
+	SubscriptionsEvent = "subscriptions"
+	AckEvent           = "ack"
+)
+
+// The versions of the websocket protocol supported by Wings. Clients select the
+// protocol version using the "protocol" query parameter when connecting, and will
+// default to version 1 if it is not provided.
+const (
+	ProtocolVersion1 = 1
+	ProtocolVersion2 = 2
+)
+
+// The frame types used as the first byte of a binary websocket message sent to
+// clients using version 2 of the protocol. The remainder of the frame is the
+// raw DEFLATE compressed payload.
+const (
+	// FrameConsoleOutput contains one or more lines of console output separated
+	// by a newline character.
+	FrameConsoleOutput byte = 0x01
 )
 
 type Message struct {
@@ -20,4 +45,13 @@ type Message struct {
 	// The data to pass along, only used by power/command currently. Other requests
 	// should either omit the field or pass an empty value as it is ignored.
 	Args []string `json:"args,omitempty"`
+
+	// An optional identifier for the message. When using version 2 of the protocol
+	// a message sent with an ID will be acknowledged with an "ack" event once it has
+	// been processed, and any error caused by it will include the same ID.
+	ID string `json:"id,omitempty"`
+
+	// The UUID of the server this message relates to. This is only used by the node
+	// level websocket which sends events for multiple servers over one connection.
+	Server string `json:"server,omitempty"`
 }
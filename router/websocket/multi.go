@@ -0,0 +1,326 @@
+package websocket
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/goccy/go-json"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+
+	"github.com/pterodactyl/wings/events"
+	"github.com/pterodactyl/wings/router/tokens"
+	"github.com/pterodactyl/wings/server"
+)
+
+// MultiHandler is a node level websocket connection which multiplexes the status
+// and stats events for multiple servers over a single connection. This is used by
+// dashboards that need to display the state of many servers at once without
+// opening a dedicated socket to each one.
+type MultiHandler struct {
+	sync.RWMutex `json:"-"`
+	Connection   *websocket.Conn `json:"-"`
+	jwt          *tokens.MultiServerWebsocketPayload
+	manager      *server.Manager
+	uuid         uuid.UUID
+
+	wmu       sync.Mutex
+	lmu       sync.Mutex
+	listeners map[string]context.CancelFunc
+}
+
+// NewMultiTokenPayload parses a JWT into a node level websocket token payload.
+func NewMultiTokenPayload(token []byte) (*tokens.MultiServerWebsocketPayload, error) {
+	var payload tokens.MultiServerWebsocketPayload
+	if err := tokens.ParseToken(token, &payload); err != nil {
+		return nil, err
+	}
+
+	if payload.Denylisted() {
+		return nil, ErrJwtOnDenylist
+	}
+
+	return &payload, nil
+}
+
+// GetMultiHandler returns a new node level websocket handler for the request.
+func GetMultiHandler(m *server.Manager, w http.ResponseWriter, r *http.Request) (*MultiHandler, error) {
+	upgrader := newUpgrader()
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := uuid.NewRandom()
+	if err != nil {
+		return nil, err
+	}
+
+	return &MultiHandler{
+		Connection: conn,
+		manager:    m,
+		uuid:       u,
+		listeners:  make(map[string]context.CancelFunc),
+	}, nil
+}
+
+func (h *MultiHandler) Uuid() uuid.UUID {
+	return h.uuid
+}
+
+func (h *MultiHandler) Logger() *log.Entry {
+	return log.WithField("subsystem", "websocket").
+		WithField("connection", h.Uuid().String())
+}
+
+// GetJwt returns the JWT for the websocket in a race-safe manner.
+func (h *MultiHandler) GetJwt() *tokens.MultiServerWebsocketPayload {
+	h.RLock()
+	defer h.RUnlock()
+
+	return h.jwt
+}
+
+// TokenValid checks if the JWT is still valid.
+func (h *MultiHandler) TokenValid() error {
+	j := h.GetJwt()
+	if j == nil {
+		return ErrJwtNotPresent
+	}
+
+	if err := jwt.ExpirationTimeValidator(time.Now())(&j.Payload); err != nil {
+		return err
+	}
+
+	if j.Denylisted() {
+		return ErrJwtOnDenylist
+	}
+
+	return nil
+}
+
+// SendJson sends a message to the client. Messages for a specific server are only
+// sent if the token still grants the connect permission for that server.
+func (h *MultiHandler) SendJson(v Message) error {
+	if err := h.TokenValid(); err != nil {
+		_ = h.unsafeSendJson(Message{
+			Event: JwtErrorEvent,
+			Args:  []string{err.Error()},
+		})
+		return nil
+	}
+
+	if v.Server != "" && !h.GetJwt().HasPermission(v.Server, PermissionConnect) {
+		return nil
+	}
+
+	if err := h.unsafeSendJson(v); err != nil {
+		if errors.Is(err, websocket.ErrCloseSent) {
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Sends JSON over the websocket connection, ignoring the authentication state
+// of the socket user.
+func (h *MultiHandler) unsafeSendJson(v interface{}) error {
+	h.wmu.Lock()
+	defer h.wmu.Unlock()
+
+	return h.Connection.WriteJSON(v)
+}
+
+// SendErrorJson sends an error back to the connected client. JWT errors are sent
+// back as-is, all other errors are masked behind a generic error message.
+func (h *MultiHandler) SendErrorJson(msg Message, err error) error {
+	wsm := Message{Event: ErrorEvent, ID: msg.ID}
+	if IsJwtError(err) {
+		wsm.Event = JwtErrorEvent
+		wsm.Args = []string{err.Error()}
+	} else {
+		h.Logger().WithFields(log.Fields{"event": msg.Event, "error": err}).
+			Errorf("error processing websocket event \"%s\"", msg.Event)
+		wsm.Args = []string{"an unexpected error was encountered while handling this request"}
+	}
+
+	return h.unsafeSendJson(wsm)
+}
+
+// HandleInbound handles an inbound socket request and route it to the proper action.
+func (h *MultiHandler) HandleInbound(ctx context.Context, m Message) error {
+	if m.Event != AuthenticationEvent {
+		if err := h.TokenValid(); err != nil {
+			_ = h.unsafeSendJson(Message{
+				Event: JwtErrorEvent,
+				Args:  []string{err.Error()},
+			})
+			return nil
+		}
+	}
+
+	switch m.Event {
+	case AuthenticationEvent:
+		{
+			token, err := NewMultiTokenPayload([]byte(strings.Join(m.Args, "")))
+			if err != nil {
+				return err
+			}
+
+			newConnection := h.GetJwt() == nil
+
+			h.Lock()
+			h.jwt = token
+			h.Unlock()
+
+			_ = h.unsafeSendJson(Message{Event: AuthenticationSuccessEvent})
+
+			if newConnection {
+				go h.listenForExpiration(ctx)
+			}
+
+			// Start listening to any servers that were added to the token, and stop
+			// listening to any that are no longer present in it.
+			h.syncListeners(ctx)
+
+			return nil
+		}
+	case SendStatsEvent:
+		{
+			for _, id := range h.GetJwt().GetServerUuids() {
+				s, ok := h.manager.Get(id)
+				if !ok {
+					continue
+				}
+				_ = h.SendJson(Message{Event: server.StatusEvent, Server: id, Args: []string{s.Environment.State()}})
+				b, _ := json.Marshal(s.Proc())
+				_ = h.SendJson(Message{Event: server.StatsEvent, Server: id, Args: []string{string(b)}})
+			}
+
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// syncListeners ensures that there is exactly one event listener running for every
+// server in the JWT that exists on this node and that the token has the connect
+// permission for.
+func (h *MultiHandler) syncListeners(ctx context.Context) {
+	j := h.GetJwt()
+	allowed := make(map[string]*server.Server)
+	for _, id := range j.GetServerUuids() {
+		if !j.HasPermission(id, PermissionConnect) {
+			continue
+		}
+		if s, ok := h.manager.Get(id); ok {
+			allowed[id] = s
+		}
+	}
+
+	h.lmu.Lock()
+	defer h.lmu.Unlock()
+	for id, cancel := range h.listeners {
+		if _, ok := allowed[id]; !ok {
+			cancel()
+			delete(h.listeners, id)
+		}
+	}
+	for id, s := range allowed {
+		if _, ok := h.listeners[id]; ok {
+			continue
+		}
+		lctx, cancel := context.WithCancel(ctx)
+		h.listeners[id] = cancel
+		go h.listenToServer(lctx, s)
+
+		// Send the current state of the server so the client does not need to
+		// wait for the next change to know what it is.
+		_ = h.SendJson(Message{Event: server.StatusEvent, Server: id, Args: []string{s.Environment.State()}})
+	}
+}
+
+// removeListener stops listening to events for the given server.
+func (h *MultiHandler) removeListener(id string) {
+	h.lmu.Lock()
+	defer h.lmu.Unlock()
+	if cancel, ok := h.listeners[id]; ok {
+		cancel()
+		delete(h.listeners, id)
+	}
+}
+
+// listenToServer forwards the status and stats events for a single server to the
+// client until the context is canceled or the server is deleted.
+func (h *MultiHandler) listenToServer(ctx context.Context, s *server.Server) {
+	eventChan := make(chan []byte, 8)
+	s.Events().On(eventChan)
+	defer s.Events().Off(eventChan)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.Context().Done():
+			_ = h.SendJson(Message{Event: server.DeletedEvent, Server: s.ID()})
+			h.removeListener(s.ID())
+			return
+		case b := <-eventChan:
+			var e events.Event
+			if err := events.DecodeTo(b, &e); err != nil {
+				continue
+			}
+			if e.Topic != server.StatusEvent && e.Topic != server.StatsEvent {
+				continue
+			}
+			message := Message{Event: e.Topic, Server: s.ID()}
+			if str, ok := e.Data.(string); ok {
+				message.Args = []string{str}
+			} else {
+				b, err := json.Marshal(e.Data)
+				if err != nil {
+					continue
+				}
+				message.Args = []string{string(b)}
+			}
+			if err := h.SendJson(message); err != nil {
+				h.Logger().WithField("server", s.ID()).WithField("error", err).Warn("failed to send event over node websocket")
+				_ = h.Connection.Close()
+				return
+			}
+		}
+	}
+}
+
+// listenForExpiration checks the time to expiration on the JWT every 30 seconds
+// and notifies the client when it is about to expire, or has expired.
+func (h *MultiHandler) listenForExpiration(ctx context.Context) {
+	ticker := time.NewTicker(time.Second * 30)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			jwt := h.GetJwt()
+			if jwt != nil {
+				if jwt.ExpirationTime.Unix()-time.Now().Unix() <= 0 {
+					_ = h.SendJson(Message{Event: TokenExpiredEvent})
+				} else if jwt.ExpirationTime.Unix()-time.Now().Unix() <= 60 {
+					_ = h.SendJson(Message{Event: TokenExpiringEvent})
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package websocket
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/gorilla/websocket"
+
+	"github.com/pterodactyl/wings/environment"
+	"github.com/pterodactyl/wings/router/tokens"
+	"github.com/pterodactyl/wings/server"
+)
+
+// testEnvironment is a process environment that always reports the server as
+// running.
+type testEnvironment struct {
+	environment.ProcessEnvironment
+}
+
+func (testEnvironment) State() string {
+	return environment.ProcessRunningState
+}
+
+// newTestMultiHandler returns a node level websocket handler for a manager
+// containing a server for each of the given UUIDs, along with the client side of
+// the connection.
+func newTestMultiHandler(ids ...string) (*MultiHandler, *websocket.Conn, func()) {
+	conns := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			panic(err)
+		}
+		conns <- c
+	}))
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		panic(err)
+	}
+	m := server.NewEmptyManager(nil)
+	for _, id := range ids {
+		s, err := server.New(nil)
+		if err != nil {
+			panic(err)
+		}
+		s.Config().Uuid = id
+		s.Environment = testEnvironment{}
+		m.Add(s)
+	}
+	h := &MultiHandler{
+		Connection: <-conns,
+		manager:    m,
+		listeners:  make(map[string]context.CancelFunc),
+	}
+	return h, client, func() {
+		_ = client.Close()
+		_ = h.Connection.Close()
+		srv.Close()
+	}
+}
+
+// newTestMultiToken returns a node level token granting the given permissions
+// for each server.
+func newTestMultiToken(servers map[string][]string) *tokens.MultiServerWebsocketPayload {
+	return &tokens.MultiServerWebsocketPayload{
+		Payload: jwt.Payload{
+			IssuedAt:       jwt.NumericDate(time.Now().Add(time.Second)),
+			ExpirationTime: jwt.NumericDate(time.Now().Add(time.Hour)),
+		},
+		Servers: servers,
+	}
+}
+
+// readAll reads every message sent to the client until nothing is received for a
+// short time. The connection cannot be read from again afterwards.
+func readAll(c *websocket.Conn) []string {
+	var out []string
+	for {
+		_, b, err := readMessage(c)
+		if err != nil {
+			return out
+		}
+		out = append(out, strings.TrimSpace(string(b)))
+	}
+}
+
+func TestMultiHandler(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("MultiHandler#SendJson", func() {
+		g.It("only sends events for servers the token can connect to", func() {
+			h, client, done := newTestMultiHandler()
+			defer done()
+			h.jwt = newTestMultiToken(map[string][]string{
+				"server-a": {PermissionConnect},
+				"server-b": {"control.console"},
+			})
+
+			for _, id := range []string{"server-b", "server-c", "server-a"} {
+				g.Assert(h.SendJson(Message{Event: server.StatusEvent, Server: id, Args: []string{"running"}})).IsNil()
+			}
+
+			g.Assert(readAll(client)).Equal([]string{`{"event":"status","args":["running"],"server":"server-a"}`})
+		})
+
+		g.It("sends a token error instead of events once the token has expired", func() {
+			h, client, done := newTestMultiHandler()
+			defer done()
+			h.jwt = newTestMultiToken(map[string][]string{"server-a": {PermissionConnect}})
+			h.jwt.ExpirationTime = jwt.NumericDate(time.Now().Add(-time.Minute))
+
+			g.Assert(h.SendJson(Message{Event: server.StatusEvent, Server: "server-a", Args: []string{"running"}})).IsNil()
+
+			out := readAll(client)
+			g.Assert(len(out)).Equal(1)
+			g.Assert(strings.HasPrefix(out[0], `{"event":"jwt error"`)).IsTrue()
+		})
+	})
+
+	g.Describe("MultiHandler#syncListeners", func() {
+		g.It("only listens to servers on the node the token can connect to", func() {
+			h, client, done := newTestMultiHandler("server-a", "server-b", "server-c")
+			defer done()
+			h.jwt = newTestMultiToken(map[string][]string{
+				"server-a": {PermissionConnect},
+				"server-b": {"control.console"},
+				"server-d": {PermissionConnect},
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			h.syncListeners(ctx)
+
+			var ids []string
+			for id := range h.listeners {
+				ids = append(ids, id)
+			}
+			g.Assert(ids).Equal([]string{"server-a"})
+			g.Assert(readAll(client)).Equal([]string{`{"event":"status","args":["running"],"server":"server-a"}`})
+		})
+
+		g.It("does not forward events from servers the token does not grant", func() {
+			h, client, done := newTestMultiHandler("server-a", "server-b", "server-c")
+			defer done()
+			h.jwt = newTestMultiToken(map[string][]string{
+				"server-a": {PermissionConnect},
+				"server-b": {"control.console"},
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			h.syncListeners(ctx)
+			time.Sleep(time.Millisecond * 50)
+
+			for _, id := range []string{"server-a", "server-b", "server-c"} {
+				s, _ := h.manager.Get(id)
+				s.Events().Publish(server.StatusEvent, "stopping")
+				s.Events().Publish(server.ConsoleOutputEvent, "console line")
+			}
+
+			g.Assert(readAll(client)).Equal([]string{
+				`{"event":"status","args":["running"],"server":"server-a"}`,
+				`{"event":"status","args":["stopping"],"server":"server-a"}`,
+			})
+		})
+
+		g.It("stops listening to servers removed from the token", func() {
+			h, _, done := newTestMultiHandler("server-a", "server-b")
+			defer done()
+			h.jwt = newTestMultiToken(map[string][]string{
+				"server-a": {PermissionConnect},
+				"server-b": {PermissionConnect},
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			h.syncListeners(ctx)
+
+			h.jwt = newTestMultiToken(map[string][]string{"server-b": {PermissionConnect}})
+			h.syncListeners(ctx)
+
+			var ids []string
+			for id := range h.listeners {
+				ids = append(ids, id)
+			}
+			g.Assert(ids).Equal([]string{"server-b"})
+		})
+	})
+}
@@ -0,0 +1,197 @@
+package websocket
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+
+	"github.com/pterodactyl/wings/server"
+)
+
+// The channels that a client using version 2 of the websocket protocol is able
+// to subscribe to. Clients using version 1 of the protocol are implicitly
+// subscribed to every channel.
+const (
+	ChannelConsole  = "console"
+	ChannelStats    = "stats"
+	ChannelStatus   = "status"
+	ChannelInstall  = "install"
+	ChannelBackup   = "backup"
+	ChannelTransfer = "transfer"
+)
+
+// The options that can be passed along with a channel when subscribing to it. These
+// are appended to the channel name using a colon, for example "console:binary"
+// or "stats:5s".
+const (
+	// OptionBinary causes console output to be sent to the client as batched and
+	// compressed binary frames rather than one JSON message per line.
+	OptionBinary = "binary"
+)
+
+// The smallest interval a client is allowed to request for stats events, anything
+// smaller than this is just the same as receiving every event.
+const minimumStatsInterval = time.Second
+
+var ErrUnknownChannel = errors.Sentinel("websocket: unknown subscription channel")
+
+// Permissions that must be present on the JWT for a client to subscribe to a
+// given channel. Channels not listed here only require the connect permission.
+var channelPermissions = map[string]string{
+	ChannelInstall:  PermissionReceiveInstall,
+	ChannelBackup:   PermissionReceiveBackups,
+	ChannelTransfer: PermissionReceiveTransfer,
+}
+
+// subscription is a single channel a websocket client has subscribed to.
+type subscription struct {
+	binary   bool
+	interval time.Duration
+	lastSent time.Time
+}
+
+// Subscriptions tracks the channels that a websocket connection has subscribed
+// to and handles throttling of events on channels with a custom interval.
+type Subscriptions struct {
+	mu       sync.Mutex
+	all      bool
+	channels map[string]*subscription
+}
+
+// NewSubscriptions returns a new subscription set. If "all" is true every channel
+// is considered subscribed to, which is the behavior for version 1 clients.
+func NewSubscriptions(all bool) *Subscriptions {
+	return &Subscriptions{all: all, channels: make(map[string]*subscription)}
+}
+
+// ChannelForEvent returns the subscription channel that a given server event is
+// sent over. An empty string is returned for events that are always sent to the
+// client regardless of their subscriptions.
+func ChannelForEvent(event string) string {
+	switch {
+	case event == server.ConsoleOutputEvent || event == server.DaemonMessageEvent:
+		return ChannelConsole
+	case event == server.StatsEvent:
+		return ChannelStats
+	case event == server.StatusEvent:
+		return ChannelStatus
+	case event == server.InstallOutputEvent || event == server.InstallStartedEvent || event == server.InstallCompletedEvent:
+		return ChannelInstall
+	case strings.HasPrefix(event, server.BackupCompletedEvent) || strings.HasPrefix(event, server.BackupRestoreCompletedEvent):
+		return ChannelBackup
+	case event == server.TransferLogsEvent || event == server.TransferStatusEvent || event == server.TransferProgressEvent:
+		return ChannelTransfer
+	}
+	return ""
+}
+
+// parseChannel parses a channel definition in the format of "name[:option]"
+// and returns the channel name and the subscription for it.
+func parseChannel(v string) (string, *subscription, error) {
+	name, opt, _ := strings.Cut(strings.TrimSpace(v), ":")
+	sub := &subscription{}
+	switch name {
+	case ChannelConsole:
+		if opt != "" && opt != OptionBinary {
+			return "", nil, errors.Errorf("websocket: invalid option \"%s\" for console channel", opt)
+		}
+		sub.binary = opt == OptionBinary
+	case ChannelStats:
+		if opt != "" {
+			d, err := time.ParseDuration(opt)
+			if err != nil {
+				return "", nil, errors.Wrap(err, "websocket: invalid stats interval")
+			}
+			if d < minimumStatsInterval {
+				d = minimumStatsInterval
+			}
+			sub.interval = d
+		}
+	case ChannelStatus, ChannelInstall, ChannelBackup, ChannelTransfer:
+		if opt != "" {
+			return "", nil, errors.Errorf("websocket: channel \"%s\" does not accept options", name)
+		}
+	default:
+		return "", nil, errors.WithStack(ErrUnknownChannel)
+	}
+	return name, sub, nil
+}
+
+// Subscribe adds the given channel to the subscription set, replacing any
+// existing subscription for the same channel.
+func (s *Subscriptions) Subscribe(v string) (string, error) {
+	name, sub, err := parseChannel(v)
+	if err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.channels[name] = sub
+	s.mu.Unlock()
+	return name, nil
+}
+
+// Unsubscribe removes a channel from the subscription set.
+func (s *Subscriptions) Unsubscribe(v string) {
+	name, _, _ := strings.Cut(strings.TrimSpace(v), ":")
+	s.mu.Lock()
+	delete(s.channels, name)
+	s.mu.Unlock()
+}
+
+// Allow returns true if an event for the given channel should be sent to the
+// client at this point in time. For channels with an interval this will also
+// mark the event as sent, so calling this function is not free of side effects.
+func (s *Subscriptions) Allow(channel string) bool {
+	if channel == "" {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.all {
+		return true
+	}
+	sub, ok := s.channels[channel]
+	if !ok {
+		return false
+	}
+	if sub.interval > 0 {
+		if time.Since(sub.lastSent) < sub.interval {
+			return false
+		}
+		sub.lastSent = time.Now()
+	}
+	return true
+}
+
+// Binary returns true if the given channel should be sent as binary frames.
+func (s *Subscriptions) Binary(channel string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sub, ok := s.channels[channel]; ok {
+		return sub.binary
+	}
+	return false
+}
+
+// List returns the channel definitions currently subscribed to, in the same
+// format that a client would use to subscribe to them.
+func (s *Subscriptions) List() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]string, 0, len(s.channels))
+	for name, sub := range s.channels {
+		switch {
+		case sub.binary:
+			out = append(out, name+":"+OptionBinary)
+		case sub.interval > 0:
+			out = append(out, name+":"+sub.interval.String())
+		default:
+			out = append(out, name)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
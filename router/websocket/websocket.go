@@ -1,9 +1,12 @@
 package websocket
 
 import (
+	"bytes"
+	"compress/flate"
 	"context"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -46,6 +49,8 @@ type Handler struct {
 	server       *server.Server
 	ra           server.RequestActivity
 	uuid         uuid.UUID
+	version      int
+	subs         *Subscriptions
 }
 
 var (
@@ -53,6 +58,8 @@ var (
 	ErrJwtNoConnectPerm = errors.New("jwt: missing connect permission")
 	ErrJwtUuidMismatch  = errors.New("jwt: server uuid mismatch")
 	ErrJwtOnDenylist    = errors.New("jwt: created too far in past (denylist)")
+
+	ErrUnsupportedProtocol = errors.New("websocket: unsupported protocol version")
 )
 
 func IsJwtError(err error) bool {
@@ -81,11 +88,10 @@ func NewTokenPayload(token []byte) (*tokens.WebsocketPayload, error) {
 	return &payload, nil
 }
 
-// GetHandler returns a new websocket handler using the context provided.
-func GetHandler(s *server.Server, w http.ResponseWriter, r *http.Request, c *gin.Context) (*Handler, error) {
-	upgrader := websocket.Upgrader{
-		// Ensure that the websocket request is originating from the Panel itself,
-		// and not some other location.
+// newUpgrader returns a websocket upgrader that ensures that the websocket request
+// is originating from the Panel itself, or one of the configured allowed origins.
+func newUpgrader() websocket.Upgrader {
+	return websocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool {
 			o := r.Header.Get("Origin")
 			if o == config.Get().PanelLocation {
@@ -99,6 +105,20 @@ func GetHandler(s *server.Server, w http.ResponseWriter, r *http.Request, c *gin
 			return false
 		},
 	}
+}
+
+// GetHandler returns a new websocket handler using the context provided.
+func GetHandler(s *server.Server, w http.ResponseWriter, r *http.Request, c *gin.Context) (*Handler, error) {
+	version := ProtocolVersion1
+	if v := r.URL.Query().Get("protocol"); v != "" {
+		i, err := strconv.Atoi(v)
+		if err != nil || (i != ProtocolVersion1 && i != ProtocolVersion2) {
+			return nil, errors.WithStack(ErrUnsupportedProtocol)
+		}
+		version = i
+	}
+
+	upgrader := newUpgrader()
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -116,6 +136,8 @@ func GetHandler(s *server.Server, w http.ResponseWriter, r *http.Request, c *gin
 		server:     s,
 		ra:         s.NewRequestActivity("", c.ClientIP()),
 		uuid:       u,
+		version:    version,
+		subs:       NewSubscriptions(version == ProtocolVersion1),
 	}, nil
 }
 
@@ -123,6 +145,16 @@ func (h *Handler) Uuid() uuid.UUID {
 	return h.uuid
 }
 
+// Version returns the version of the websocket protocol in use by the client.
+func (h *Handler) Version() int {
+	return h.version
+}
+
+// Subscriptions returns the channels the client is subscribed to.
+func (h *Handler) Subscriptions() *Subscriptions {
+	return h.subs
+}
+
 func (h *Handler) Logger() *log.Entry {
 	return log.WithField("subsystem", "websocket").
 		WithField("connection", h.Uuid().String()).
@@ -156,8 +188,9 @@ func (h *Handler) SendJson(v Message) error {
 			}
 		}
 
-		// If we are sending transfer output, only send it to the user if they have the required permissions.
-		if v.Event == server.TransferLogsEvent {
+		// If we are sending transfer output, progress or status, only send it to the
+		// user if they have the required permissions.
+		if strings.HasPrefix(v.Event, "transfer ") {
 			if !j.HasPermission(PermissionReceiveTransfer) {
 				return nil
 			}
@@ -183,6 +216,56 @@ func (h *Handler) SendJson(v Message) error {
 	return nil
 }
 
+// SendBinary compresses the payload and sends it as a binary frame of the given
+// type to the client. This is only used for clients on version 2 of the protocol
+// that have requested binary output for a channel.
+func (h *Handler) SendBinary(frame byte, payload []byte) error {
+	if err := h.TokenValid(); err != nil {
+		_ = h.unsafeSendJson(Message{
+			Event: JwtErrorEvent,
+			Args:  []string{err.Error()},
+		})
+		return nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(frame)
+	fw, err := flate.NewWriter(&buf, flate.BestSpeed)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := fw.Write(payload); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := fw.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	h.Lock()
+	defer h.Unlock()
+	if err := h.Connection.WriteMessage(websocket.BinaryMessage, buf.Bytes()); err != nil {
+		if errors.Is(err, websocket.ErrCloseSent) {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+// Acknowledge sends an acknowledgement for the given message back to the client
+// if the client is using version 2 of the protocol and the message had an ID.
+// Messages are never acknowledged once the JWT on the connection is no longer
+// valid, since they will not have been processed.
+func (h *Handler) Acknowledge(msg Message) error {
+	if h.version < ProtocolVersion2 || msg.ID == "" {
+		return nil
+	}
+	if err := h.TokenValid(); err != nil {
+		return nil
+	}
+	return h.unsafeSendJson(Message{Event: AckEvent, Args: []string{msg.Event}, ID: msg.ID})
+}
+
 // Sends JSON over the websocket connection, ignoring the authentication state of the
 // socket user. Do not call this directly unless you are positive a response should be
 // sent back to the client!
@@ -240,6 +323,9 @@ func (h *Handler) SendErrorJson(msg Message, err error, shouldLog ...bool) error
 
 	m, u := h.GetErrorMessage(wsm.Args[0])
 	wsm.Args = []string{m}
+	if h.version >= ProtocolVersion2 {
+		wsm.ID = msg.ID
+	}
 
 	if !isJWTError && (len(shouldLog) == 0 || (len(shouldLog) == 1 && shouldLog[0] == true)) {
 		h.server.Log().WithFields(log.Fields{"event": msg.Event, "error_identifier": u.String(), "error": err}).
@@ -432,14 +518,76 @@ func (h *Handler) HandleInbound(ctx context.Context, m Message) error {
 				}
 			}
 
-			if err := h.server.Environment.SendCommand(strings.Join(m.Args, "")); err != nil {
-				return err
+			command := strings.Join(m.Args, "")
+			if err := h.server.SendCommand(command); err != nil {
+				if !errors.Is(err, server.ErrCommandNotAllowed) {
+					return err
+				}
+				m, _ := h.GetErrorMessage("that command is not allowed to be sent to this server")
+
+				_ = h.SendJson(Message{
+					Event: ErrorEvent,
+					Args:  []string{m},
+				})
+
+				return nil
 			}
 			h.server.SaveActivity(h.ra, server.ActivityConsoleCommand, models.ActivityMeta{
-				"command": strings.Join(m.Args, ""),
+				"command": command,
 			})
+			h.server.SaveCommand(h.ra, command)
 			return nil
 		}
+	case SendCommandHistoryEvent:
+		{
+			if !h.GetJwt().HasPermission(PermissionSendCommand) {
+				return nil
+			}
+
+			history, err := h.server.CommandHistory(ctx, h.GetJwt().UserUUID, config.Get().System.CommandHistorySize)
+			if err != nil {
+				return err
+			}
+
+			args := make([]string, len(history))
+			for i, c := range history {
+				args[i] = c.Command
+			}
+
+			return h.SendJson(Message{Event: CommandHistoryEvent, Args: args})
+		}
+	case SubscribeEvent:
+		{
+			if h.version < ProtocolVersion2 {
+				return nil
+			}
+
+			for _, v := range m.Args {
+				name, _, _ := strings.Cut(v, ":")
+				// Silently skip over any channels the user does not have permission to
+				// receive, the subscriptions event sent back will not include them.
+				if p, ok := channelPermissions[name]; ok && !h.GetJwt().HasPermission(p) {
+					continue
+				}
+				if _, err := h.subs.Subscribe(v); err != nil {
+					return err
+				}
+			}
+
+			return h.SendJson(Message{Event: SubscriptionsEvent, Args: h.subs.List()})
+		}
+	case UnsubscribeEvent:
+		{
+			if h.version < ProtocolVersion2 {
+				return nil
+			}
+
+			for _, v := range m.Args {
+				h.subs.Unsubscribe(v)
+			}
+
+			return h.SendJson(Message{Event: SubscriptionsEvent, Args: h.subs.List()})
+		}
 	}
 
 	return nil
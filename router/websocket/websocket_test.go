@@ -0,0 +1,283 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/franela/goblin"
+	"github.com/gbrlsnchs/jwt/v3"
+	"github.com/gorilla/websocket"
+
+	"github.com/pterodactyl/wings/router/tokens"
+	"github.com/pterodactyl/wings/server"
+	"github.com/pterodactyl/wings/system"
+)
+
+// newTestHandler returns a handler for a websocket connection using the given
+// version of the protocol, along with the client side of the connection.
+func newTestHandler(version int) (*Handler, *websocket.Conn, func()) {
+	conns := make(chan *websocket.Conn, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{}
+		c, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			panic(err)
+		}
+		conns <- c
+	}))
+
+	client, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		panic(err)
+	}
+	s, err := server.New(nil)
+	if err != nil {
+		panic(err)
+	}
+	h := &Handler{
+		Connection: <-conns,
+		server:     s,
+		version:    version,
+		subs:       NewSubscriptions(version == ProtocolVersion1),
+	}
+	return h, client, func() {
+		_ = client.Close()
+		_ = h.Connection.Close()
+		srv.Close()
+	}
+}
+
+// newTestToken returns a token for the server that expires after the given
+// duration.
+func newTestToken(s *server.Server, expires time.Duration) *tokens.WebsocketPayload {
+	return &tokens.WebsocketPayload{
+		Payload: jwt.Payload{
+			IssuedAt:       jwt.NumericDate(time.Now().Add(time.Second)),
+			ExpirationTime: jwt.NumericDate(time.Now().Add(expires)),
+		},
+		ServerUUID:  s.ID(),
+		Permissions: []string{PermissionConnect},
+	}
+}
+
+// readMessage reads the next message sent to the client, returning an error if
+// nothing is received within a short time.
+func readMessage(c *websocket.Conn) (int, []byte, error) {
+	_ = c.SetReadDeadline(time.Now().Add(time.Millisecond * 250))
+	return c.ReadMessage()
+}
+
+func TestHandler_Acknowledge(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Handler#Acknowledge", func() {
+		g.It("acknowledges messages with an ID from version 2 clients", func() {
+			h, client, done := newTestHandler(ProtocolVersion2)
+			defer done()
+			h.setJwt(newTestToken(h.server, time.Hour))
+
+			g.Assert(h.Acknowledge(Message{Event: SendStatsEvent, ID: "abc"})).IsNil()
+			_, b, err := readMessage(client)
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal(`{"event":"ack","args":["send stats"],"id":"abc"}` + "\n")
+		})
+
+		g.It("does not acknowledge messages without an ID", func() {
+			h, client, done := newTestHandler(ProtocolVersion2)
+			defer done()
+			h.setJwt(newTestToken(h.server, time.Hour))
+
+			g.Assert(h.Acknowledge(Message{Event: SendStatsEvent})).IsNil()
+			_, _, err := readMessage(client)
+			g.Assert(err == nil).IsFalse()
+		})
+
+		g.It("does not acknowledge messages from version 1 clients", func() {
+			h, client, done := newTestHandler(ProtocolVersion1)
+			defer done()
+			h.setJwt(newTestToken(h.server, time.Hour))
+
+			g.Assert(h.Acknowledge(Message{Event: SendStatsEvent, ID: "abc"})).IsNil()
+			_, _, err := readMessage(client)
+			g.Assert(err == nil).IsFalse()
+		})
+
+		g.It("does not acknowledge messages once the token has expired", func() {
+			h, client, done := newTestHandler(ProtocolVersion2)
+			defer done()
+			h.setJwt(newTestToken(h.server, -time.Minute))
+
+			g.Assert(h.Acknowledge(Message{Event: SendStatsEvent, ID: "abc"})).IsNil()
+			_, _, err := readMessage(client)
+			g.Assert(err == nil).IsFalse()
+		})
+	})
+}
+
+func TestHandler_SendJson(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Handler#SendJson", func() {
+		g.It("only sends transfer events to tokens with the transfer permission", func() {
+			events := []Message{
+				{Event: server.TransferLogsEvent, Args: []string{"line"}},
+				{Event: server.TransferProgressEvent, Args: []string{"{}"}},
+				{Event: server.TransferStatusEvent, Args: []string{"processing"}},
+			}
+
+			h, client, done := newTestHandler(ProtocolVersion1)
+			defer done()
+			h.setJwt(newTestToken(h.server, time.Hour))
+
+			for _, e := range events {
+				g.Assert(h.SendJson(e)).IsNil()
+			}
+			g.Assert(h.SendJson(Message{Event: server.StatusEvent, Args: []string{"running"}})).IsNil()
+
+			_, b, err := readMessage(client)
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal(`{"event":"status","args":["running"]}` + "\n")
+
+			h2, client2, done2 := newTestHandler(ProtocolVersion1)
+			defer done2()
+			token := newTestToken(h2.server, time.Hour)
+			token.Permissions = append(token.Permissions, PermissionReceiveTransfer)
+			h2.setJwt(token)
+
+			for _, e := range events {
+				g.Assert(h2.SendJson(e)).IsNil()
+				_, b, err := readMessage(client2)
+				g.Assert(err).IsNil()
+				g.Assert(strings.HasPrefix(string(b), `{"event":"`+e.Event+`"`)).IsTrue(e.Event)
+			}
+		})
+	})
+}
+
+func TestHandler_listenForServerEvents(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Handler#listenForServerEvents", func() {
+		g.It("sends batched console output as a binary frame to version 2 clients", func() {
+			h, client, done := newTestHandler(ProtocolVersion2)
+			defer done()
+			h.setJwt(newTestToken(h.server, time.Hour))
+			_, err := h.subs.Subscribe(ChannelConsole + ":" + OptionBinary)
+			g.Assert(err).IsNil()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go h.listenForServerEvents(ctx)
+			time.Sleep(time.Millisecond * 50)
+
+			h.server.Sink(system.LogSink).Push([]byte("first line"))
+			h.server.Sink(system.LogSink).Push([]byte("second line"))
+
+			mt, b, err := readMessage(client)
+			g.Assert(err).IsNil()
+			g.Assert(mt).Equal(websocket.BinaryMessage)
+			g.Assert(b[0]).Equal(FrameConsoleOutput)
+			out, err := io.ReadAll(flate.NewReader(bytes.NewReader(b[1:])))
+			g.Assert(err).IsNil()
+			g.Assert(string(out)).Equal("first line\nsecond line")
+		})
+
+		g.It("only sends events for subscribed channels to version 2 clients", func() {
+			h, client, done := newTestHandler(ProtocolVersion2)
+			defer done()
+			h.setJwt(newTestToken(h.server, time.Hour))
+			_, err := h.subs.Subscribe(ChannelStatus)
+			g.Assert(err).IsNil()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go h.listenForServerEvents(ctx)
+			time.Sleep(time.Millisecond * 50)
+
+			h.server.Sink(system.LogSink).Push([]byte("console line"))
+			h.server.Events().Publish(server.StatusEvent, "running")
+
+			mt, b, err := readMessage(client)
+			g.Assert(err).IsNil()
+			g.Assert(mt).Equal(websocket.TextMessage)
+			g.Assert(string(b)).Equal(`{"event":"status","args":["running"]}` + "\n")
+		})
+
+		g.It("sends console output as JSON to version 1 clients", func() {
+			h, client, done := newTestHandler(ProtocolVersion1)
+			defer done()
+			h.setJwt(newTestToken(h.server, time.Hour))
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go h.listenForServerEvents(ctx)
+			time.Sleep(time.Millisecond * 50)
+
+			h.server.Sink(system.LogSink).Push([]byte("console line"))
+
+			mt, b, err := readMessage(client)
+			g.Assert(err).IsNil()
+			g.Assert(mt).Equal(websocket.TextMessage)
+			g.Assert(string(b)).Equal(`{"event":"console output","args":["console line"]}` + "\n")
+		})
+	})
+}
+
+func TestSubscriptions(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Subscriptions", func() {
+		g.It("allows every channel for version 1 clients", func() {
+			s := NewSubscriptions(true)
+			g.Assert(s.Allow(ChannelConsole)).IsTrue()
+			g.Assert(s.Allow(ChannelTransfer)).IsTrue()
+			g.Assert(s.Binary(ChannelConsole)).IsFalse()
+		})
+
+		g.It("only allows subscribed channels for version 2 clients", func() {
+			s := NewSubscriptions(false)
+			g.Assert(s.Allow(ChannelConsole)).IsFalse()
+
+			name, err := s.Subscribe(ChannelConsole + ":" + OptionBinary)
+			g.Assert(err).IsNil()
+			g.Assert(name).Equal(ChannelConsole)
+			g.Assert(s.Allow(ChannelConsole)).IsTrue()
+			g.Assert(s.Binary(ChannelConsole)).IsTrue()
+
+			s.Unsubscribe(ChannelConsole)
+			g.Assert(s.Allow(ChannelConsole)).IsFalse()
+		})
+
+		g.It("only filters events that are sent over a channel", func() {
+			s := NewSubscriptions(false)
+			g.Assert(s.Allow(ChannelForEvent(server.TransferProgressEvent))).IsFalse()
+			g.Assert(s.Allow(ChannelForEvent("unknown event"))).IsTrue()
+		})
+
+		g.It("throttles stats to the requested interval", func() {
+			s := NewSubscriptions(false)
+			_, err := s.Subscribe(ChannelStats + ":10ms")
+			g.Assert(err).IsNil()
+			g.Assert(s.List()).Equal([]string{ChannelStats + ":1s"})
+			g.Assert(s.Allow(ChannelStats)).IsTrue()
+			g.Assert(s.Allow(ChannelStats)).IsFalse()
+		})
+
+		g.It("rejects unknown channels and options", func() {
+			s := NewSubscriptions(false)
+			_, err := s.Subscribe("unknown")
+			g.Assert(err == nil).IsFalse()
+			_, err = s.Subscribe(ChannelConsole + ":gzip")
+			g.Assert(err == nil).IsFalse()
+			_, err = s.Subscribe(ChannelStatus + ":1s")
+			g.Assert(err == nil).IsFalse()
+		})
+	})
+}
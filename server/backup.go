@@ -54,10 +54,18 @@ func (s *Server) getServerwideIgnoredFiles() (string, error) {
 	return string(b), nil
 }
 
+// IsBackingUp returns true if a backup of the server is currently being generated.
+func (s *Server) IsBackingUp() bool {
+	return s.backups.Load() > 0
+}
+
 // Backup performs a server backup and then emits the event over the server
 // websocket. We let the actual backup system handle notifying the panel of the
 // status, but that won't emit a websocket event.
 func (s *Server) Backup(b backup.BackupInterface) error {
+	s.backups.Add(1)
+	defer s.backups.Add(-1)
+
 	ignored := b.Ignored()
 	if b.Ignored() == "" {
 		if i, err := s.getServerwideIgnoredFiles(); err != nil {
@@ -160,7 +160,7 @@ func (b *Backup) Ignored() string {
 // Returns a logger instance for this backup with the additional context fields
 // assigned to the output.
 func (b *Backup) log() *log.Entry {
-	l := log.WithField("backup", b.Identifier()).WithField("adapter", b.adapter)
+	l := log.WithField("subsystem", "backup").WithField("backup", b.Identifier()).WithField("adapter", b.adapter)
 	for k, v := range b.logContext {
 		l = l.WithField(k, v)
 	}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"context"
+	"path"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/internal/database"
+	"github.com/pterodactyl/wings/internal/models"
+)
+
+// IsCommandAllowed checks the given console command against the command allowlist
+// and denylist defined for the server's egg. Only the name of the command (the first
+// word, with any leading slash removed) is compared, and entries in either list may
+// use shell style wildcards such as "gamerule*". Namespaced commands such as
+// "minecraft:op" are compared both with and without their namespace, so they cannot
+// be used to get around the denylist. If a command matches the denylist it is never
+// allowed, otherwise if an allowlist is defined the command must match one of its
+// entries. Commands spanning multiple lines are sent to the server as separate
+// commands, so every line must be allowed.
+func (s *Server) IsCommandAllowed(command string) bool {
+	egg := s.Config().Egg
+	lines := strings.FieldsFunc(command, func(r rune) bool {
+		return r == '\n' || r == '\r'
+	})
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		names := commandNames(fields[0])
+		if matchesCommand(names, egg.CommandDenylist) {
+			return false
+		}
+		if len(egg.CommandAllowlist) > 0 && !matchesCommand(names, egg.CommandAllowlist) {
+			return false
+		}
+	}
+	return true
+}
+
+// SendCommand sends a console command to the server, returning ErrCommandNotAllowed
+// if the command is not allowed by the egg.
+func (s *Server) SendCommand(command string) error {
+	if !s.IsCommandAllowed(command) {
+		return errors.WithStack(ErrCommandNotAllowed)
+	}
+	return s.Environment.SendCommand(command)
+}
+
+// commandNames returns the names a command is matched against, which is the
+// command without a leading slash and, if the command is namespaced, the command
+// without the namespace.
+func commandNames(command string) []string {
+	name := strings.ToLower(strings.TrimPrefix(command, "/"))
+	if ns, cmd, ok := strings.Cut(name, ":"); ok && ns != "" && cmd != "" {
+		return []string{name, strings.TrimPrefix(cmd, "/")}
+	}
+	return []string{name}
+}
+
+func matchesCommand(names []string, patterns []string) bool {
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(p), "/"))
+		for _, name := range names {
+			if ok, _ := path.Match(p, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SaveCommand stores a command sent to the server in the local command history. Only
+// the most recent commands for each server are retained, as defined by the
+// command_history_size configuration value. If an error is encountered it is logged
+// but not returned to the caller.
+func (s *Server) SaveCommand(a RequestActivity, command string) {
+	size := config.Get().System.CommandHistorySize
+	if size <= 0 {
+		return
+	}
+
+	var user models.JsonNullString
+	if a.user != "" {
+		user.String = a.user
+		user.Valid = true
+	}
+
+	ctx, cancel := context.WithTimeout(s.Context(), time.Second*3)
+	go func() {
+		defer cancel()
+		db := database.Instance().WithContext(ctx)
+		if tx := db.Create(&models.Command{Server: s.ID(), User: user, Command: command}); tx.Error != nil {
+			s.Log().WithField("error", errors.WithStack(tx.Error)).Error("commands: failed to save command history")
+			return
+		}
+		// Trim the history back down to the configured size by removing everything
+		// older than the most recent entries for this server.
+		keep := db.Model(&models.Command{}).Select("id").Where("server = ?", s.ID()).Order("id DESC").Limit(size)
+		if tx := db.Where("server = ? AND id NOT IN (?)", s.ID(), keep).Delete(&models.Command{}); tx.Error != nil {
+			s.Log().WithField("error", errors.WithStack(tx.Error)).Error("commands: failed to trim command history")
+		}
+	}()
+}
+
+// CommandHistory returns the most recent commands sent to the server, ordered from
+// oldest to newest. If a user UUID is provided only commands sent by that user are
+// returned.
+func (s *Server) CommandHistory(ctx context.Context, user string, limit int) ([]models.Command, error) {
+	tx := database.Instance().WithContext(ctx).Where("server = ?", s.ID())
+	if user != "" {
+		tx = tx.Where("user = ?", user)
+	}
+	var out []models.Command
+	if err := tx.Order("id DESC").Limit(limit).Find(&out).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out, nil
+}
+
+// PurgeCommandHistory removes all the stored command history for the server.
+func (s *Server) PurgeCommandHistory(ctx context.Context) error {
+	if tx := database.Instance().WithContext(ctx).Where("server = ?", s.ID()).Delete(&models.Command{}); tx.Error != nil {
+		return errors.WithStack(tx.Error)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+)
+
+func TestCommands(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Server#IsCommandAllowed", func() {
+		g.It("allows everything when no lists are defined", func() {
+			s := &Server{}
+
+			g.Assert(s.IsCommandAllowed("op player")).IsTrue()
+			g.Assert(s.IsCommandAllowed("")).IsTrue()
+		})
+
+		g.It("blocks commands on the denylist", func() {
+			s := &Server{}
+			s.cfg.Egg.CommandDenylist = []string{"op", "ban*"}
+
+			g.Assert(s.IsCommandAllowed("say hello")).IsTrue()
+			g.Assert(s.IsCommandAllowed("op player")).IsFalse()
+			g.Assert(s.IsCommandAllowed("/OP player")).IsFalse()
+			g.Assert(s.IsCommandAllowed("ban-ip 127.0.0.1")).IsFalse()
+			g.Assert(s.IsCommandAllowed("opt")).IsTrue()
+		})
+
+		g.It("only allows commands on the allowlist when defined", func() {
+			s := &Server{}
+			s.cfg.Egg.CommandAllowlist = []string{"say", "list"}
+
+			g.Assert(s.IsCommandAllowed("say hello")).IsTrue()
+			g.Assert(s.IsCommandAllowed("list")).IsTrue()
+			g.Assert(s.IsCommandAllowed("op player")).IsFalse()
+		})
+
+		g.It("checks every line of commands spanning multiple lines", func() {
+			s := &Server{}
+			s.cfg.Egg.CommandDenylist = []string{"op"}
+
+			g.Assert(s.IsCommandAllowed("say hi\nop attacker")).IsFalse()
+			g.Assert(s.IsCommandAllowed("say hi\r/op attacker")).IsFalse()
+			g.Assert(s.IsCommandAllowed("say hi\n\nlist")).IsTrue()
+
+			s.cfg.Egg.CommandDenylist = nil
+			s.cfg.Egg.CommandAllowlist = []string{"say"}
+			g.Assert(s.IsCommandAllowed("say hi\r\nstop")).IsFalse()
+		})
+
+		g.It("blocks namespaced commands on the denylist", func() {
+			s := &Server{}
+			s.cfg.Egg.CommandDenylist = []string{"op", "bukkit:*"}
+
+			g.Assert(s.IsCommandAllowed("minecraft:op player")).IsFalse()
+			g.Assert(s.IsCommandAllowed("/minecraft:op player")).IsFalse()
+			g.Assert(s.IsCommandAllowed("MINECRAFT:OP player")).IsFalse()
+			g.Assert(s.IsCommandAllowed("say hi\nminecraft:op player")).IsFalse()
+			g.Assert(s.IsCommandAllowed("bukkit:reload")).IsFalse()
+			g.Assert(s.IsCommandAllowed("minecraft:say hello")).IsTrue()
+			g.Assert(s.IsCommandAllowed("minecraft:")).IsTrue()
+		})
+
+		g.It("allows namespaced commands on the allowlist", func() {
+			s := &Server{}
+			s.cfg.Egg.CommandAllowlist = []string{"say", "essentials:msg"}
+
+			g.Assert(s.IsCommandAllowed("minecraft:say hello")).IsTrue()
+			g.Assert(s.IsCommandAllowed("essentials:msg player hi")).IsTrue()
+			g.Assert(s.IsCommandAllowed("msg player hi")).IsFalse()
+			g.Assert(s.IsCommandAllowed("minecraft:op player")).IsFalse()
+		})
+
+		g.It("prefers the denylist over the allowlist", func() {
+			s := &Server{}
+			s.cfg.Egg.CommandAllowlist = []string{"*"}
+			s.cfg.Egg.CommandDenylist = []string{"stop"}
+
+			g.Assert(s.IsCommandAllowed("say hello")).IsTrue()
+			g.Assert(s.IsCommandAllowed("stop")).IsFalse()
+		})
+	})
+}
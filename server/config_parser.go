@@ -1,13 +1,19 @@
 package server
 
 import (
+	"io"
 	"runtime"
 
+	"emperror.dev/errors"
 	"github.com/gammazero/workerpool"
 
 	"github.com/pterodactyl/wings/internal/ufs"
+	"github.com/pterodactyl/wings/parser"
 )
 
+// The maximum size of a configuration file that can be previewed.
+const maxPreviewFileSize = 4 * 1024 * 1024
+
 // UpdateConfigurationFiles updates all the defined configuration files for
 // a server automatically to ensure that they always use the specified values.
 func (s *Server) UpdateConfigurationFiles() {
@@ -37,3 +43,45 @@ func (s *Server) UpdateConfigurationFiles() {
 
 	pool.StopWait()
 }
+
+// PreviewConfigurationFiles runs the replacements for the given configuration
+// files against the current contents of the files on the disk without writing
+// any changes, and returns a diff of the changes that would be made to each file
+// along with any replacements that did not match. If no files are provided the
+// configuration files defined for the server's egg are used.
+func (s *Server) PreviewConfigurationFiles(files []parser.ConfigurationFile) []*parser.Preview {
+	if files == nil {
+		files = s.ProcessConfiguration().ConfigurationFiles
+	}
+
+	out := make([]*parser.Preview, len(files))
+	for i, f := range files {
+		p, err := s.previewConfigurationFile(f)
+		if err != nil {
+			p = &parser.Preview{File: f.FileName, Parser: f.Parser.String(), Unmatched: []string{}, Error: err.Error()}
+		}
+		out[i] = p
+	}
+	return out
+}
+
+func (s *Server) previewConfigurationFile(f parser.ConfigurationFile) (*parser.Preview, error) {
+	var contents []byte
+	file, err := s.Filesystem().UnixFS().Open(f.FileName)
+	if err != nil && !errors.Is(err, ufs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		defer file.Close()
+		// Configuration files are not expected to be large, refuse to load anything
+		// that is unreasonably big into memory.
+		contents, err = io.ReadAll(io.LimitReader(file, maxPreviewFileSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if len(contents) > maxPreviewFileSize {
+			return nil, errors.New("file is too large to preview")
+		}
+	}
+	return f.Preview(contents)
+}
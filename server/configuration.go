@@ -3,7 +3,9 @@ package server
 import (
 	"sync"
 
+	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/environment"
+	"github.com/pterodactyl/wings/remote"
 )
 
 type EggConfiguration struct {
@@ -14,6 +16,30 @@ type EggConfiguration struct {
 	// or basically any type of access on the server by any user. This is NOT the same
 	// as a per-user denylist, this is defined at the Egg level.
 	FileDenylist []string `json:"file_denylist"`
+
+	// Console commands that are never allowed to be sent to the server, and if not
+	// empty, the only console commands that are allowed to be sent to the server.
+	// Entries are matched against the first word of the command and may contain
+	// wildcards, e.g. "ban*".
+	CommandDenylist  []string `json:"command_denylist"`
+	CommandAllowlist []string `json:"command_allowlist"`
+
+	// Credentials used to pull the images for every server using this Egg, unless
+	// the server defines its own.
+	RegistryAuth *remote.RegistryCredentials `json:"registry_auth,omitempty"`
+
+	// The name of the security profile defined on the node to use for every server
+	// using this Egg, unless the server selects its own.
+	SecurityProfile string `json:"security_profile"`
+
+	// The maximum number of seconds the installation script may run for, overriding
+	// the timeout configured on the node when greater than 0.
+	InstallTimeout int64 `json:"install_timeout"`
+
+	// The number of times the installation process is retried when it fails due
+	// to a network error, such as being unable to pull the installer image or the
+	// script exiting with a network related exit code.
+	InstallRetries int `json:"install_retries"`
 }
 
 type ConfigurationMeta struct {
@@ -55,12 +81,41 @@ type Configuration struct {
 	Mounts                []Mount                 `json:"mounts"`
 	Egg                   EggConfiguration        `json:"egg,omitempty"`
 
+	// Ulimits and Devices requested by the Panel for the server container. Only
+	// those allowed by the node configuration are applied.
+	Ulimits []config.Ulimit        `json:"ulimits"`
+	Devices []config.DeviceMapping `json:"devices"`
+
+	// The UUID of the user that owns the server, used to share a network between
+	// servers belonging to the same user when the node isolates networks by owner.
+	OwnerUuid string `json:"owner_uuid"`
+
+	// Networks are the identifiers of the private networks the Panel has linked the
+	// server into. Every server in the same network is able to reach the others.
+	Networks []string `json:"networks"`
+
 	Container struct {
 		// Defines the Docker image that will be used for this server
 		Image string `json:"image,omitempty"`
+		// Credentials used to pull the image if it is hosted in a private registry.
+		RegistryAuth *remote.RegistryCredentials `json:"registry_auth,omitempty"`
+		// The name of the security profile defined on the node to use for the container.
+		SecurityProfile string `json:"security_profile"`
 	} `json:"container,omitempty"`
 }
 
+// RegistryCredentials returns the credentials to use when pulling the image for
+// the server, preferring those defined for the server over those for the Egg.
+func (c *Configuration) RegistryCredentials() *remote.RegistryCredentials {
+	if !c.Container.RegistryAuth.IsEmpty() {
+		return c.Container.RegistryAuth
+	}
+	if !c.Egg.RegistryAuth.IsEmpty() {
+		return c.Egg.RegistryAuth
+	}
+	return nil
+}
+
 func (s *Server) Config() *Configuration {
 	s.cfg.mu.RLock()
 	defer s.cfg.mu.RUnlock()
@@ -91,3 +146,13 @@ func (c *Configuration) SetSuspended(s bool) {
 	defer c.mu.Unlock()
 	c.Suspended = s
 }
+
+// SecurityProfile returns the name of the security profile to use for the
+// server container, preferring the profile selected for the server over the one
+// selected for the Egg.
+func (c *Configuration) SecurityProfile() string {
+	if c.Container.SecurityProfile != "" {
+		return c.Container.SecurityProfile
+	}
+	return c.Egg.SecurityProfile
+}
@@ -14,21 +14,28 @@ import (
 // appName is a local cache variable to avoid having to make expensive copies of
 // the configuration every time we need to send output along to the websocket for
 // a server.
-var appName string
+var appName = system.NewAtomicString("")
 var appNameSync sync.Once
 
 // PublishConsoleOutputFromDaemon sends output to the server console formatted
 // to appear correctly as being sent from Wings.
 func (s *Server) PublishConsoleOutputFromDaemon(data string) {
 	appNameSync.Do(func() {
-		appName = config.Get().AppName
+		appName.Store(config.Get().AppName)
 	})
 	s.Events().Publish(
 		ConsoleOutputEvent,
-		colorstring.Color(fmt.Sprintf("[yellow][bold][%s Daemon]:[default] %s", appName, data)),
+		colorstring.Color(fmt.Sprintf("[yellow][bold][%s Daemon]:[default] %s", appName.Load(), data)),
 	)
 }
 
+// SetAppName updates the application name used when sending output to a server
+// console, for use after the configuration has been reloaded.
+func SetAppName(name string) {
+	appNameSync.Do(func() {})
+	appName.Store(name)
+}
+
 // Throttler returns the throttler instance for the server or creates a new one.
 func (s *Server) Throttler() *ConsoleThrottle {
 	s.throttleOnce.Do(func() {
@@ -43,6 +50,11 @@ func (s *Server) Throttler() *ConsoleThrottle {
 	return s.throttler
 }
 
+// configure updates the limits of the throttler using the configuration.
+func (ct *ConsoleThrottle) configure(throttles config.ConsoleThrottles) {
+	ct.limit.SetLimit(throttles.Lines, time.Duration(throttles.Period)*time.Millisecond)
+}
+
 type ConsoleThrottle struct {
 	limit  *system.Rate
 	lock   *system.Locker
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// Returns the host devices to pass through to the server container after verifying that
+// they are within the list of allowed devices for the node.
+func (s *Server) Devices() []config.DeviceMapping {
+	var devices []config.DeviceMapping
+
+	for _, d := range s.Config().Devices {
+		source := filepath.Clean(d.Source)
+		target := source
+		if d.Target != "" {
+			target = filepath.Clean(d.Target)
+		}
+		perms := d.Permissions
+		if perms == "" {
+			perms = "rwm"
+		}
+
+		logger := s.Log().WithFields(log.Fields{
+			"source_path": source,
+			"target_path": target,
+			"permissions": perms,
+		})
+
+		if strings.Trim(perms, "rwm") != "" {
+			logger.Warn("skipping server device, invalid cgroup permissions")
+			continue
+		}
+
+		allowed := false
+		for _, a := range config.Get().AllowedDevices {
+			if source == filepath.Clean(a) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			logger.Warn("skipping server device, not in list of allowed devices")
+			continue
+		}
+
+		devices = append(devices, config.DeviceMapping{
+			Source:      source,
+			Target:      target,
+			Permissions: perms,
+		})
+	}
+
+	return devices
+}
+
+// Returns the ulimits to apply to the server container after verifying that they are
+// allowed for the node and do not exceed the maximum allowed value.
+func (s *Server) Ulimits() []config.Ulimit {
+	var ulimits []config.Ulimit
+
+	for _, u := range s.Config().Ulimits {
+		logger := s.Log().WithFields(log.Fields{
+			"ulimit": u.Name,
+			"soft":   u.Soft,
+			"hard":   u.Hard,
+		})
+
+		max, ok := config.Get().AllowedUlimits[u.Name]
+		if !ok {
+			logger.Warn("skipping server ulimit, not in list of allowed ulimits")
+			continue
+		}
+		if max != -1 && (u.Hard < 0 || u.Hard > max || u.Soft < 0 || u.Soft > max) {
+			logger.WithField("max", max).Warn("skipping server ulimit, exceeds maximum allowed value")
+			continue
+		}
+		if u.Hard != -1 && (u.Soft == -1 || u.Soft > u.Hard) {
+			logger.Warn("skipping server ulimit, soft limit is greater than the hard limit")
+			continue
+		}
+
+		ulimits = append(ulimits, u)
+	}
+
+	return ulimits
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"testing"
+
+	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestDevices(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Server#Devices", func() {
+		g.BeforeEach(func() {
+			config.Set(&config.Configuration{AuthenticationToken: "abc", AllowedDevices: []string{"/dev/fuse", "/dev/net/tun"}})
+		})
+
+		g.It("only returns allowed devices", func() {
+			s := &Server{}
+			s.cfg.Devices = []config.DeviceMapping{
+				{Source: "/dev/fuse"},
+				{Source: "/dev/sda"},
+				{Source: "/dev/net/../net/tun", Target: "/dev/tun", Permissions: "rw"},
+			}
+
+			g.Assert(s.Devices()).Equal([]config.DeviceMapping{
+				{Source: "/dev/fuse", Target: "/dev/fuse", Permissions: "rwm"},
+				{Source: "/dev/net/tun", Target: "/dev/tun", Permissions: "rw"},
+			})
+		})
+
+		g.It("skips devices with invalid permissions", func() {
+			s := &Server{}
+			s.cfg.Devices = []config.DeviceMapping{{Source: "/dev/fuse", Permissions: "rwx"}}
+
+			g.Assert(len(s.Devices())).Equal(0)
+		})
+	})
+
+	g.Describe("Server#Ulimits", func() {
+		g.BeforeEach(func() {
+			config.Set(&config.Configuration{AuthenticationToken: "abc", AllowedUlimits: map[string]int64{"nofile": 65536, "memlock": -1}})
+		})
+
+		g.It("only returns allowed ulimits within the maximum", func() {
+			s := &Server{}
+			s.cfg.Ulimits = []config.Ulimit{
+				{Name: "nofile", Soft: 32768, Hard: 65536},
+				{Name: "nproc", Soft: 100, Hard: 100},
+				{Name: "memlock", Soft: -1, Hard: -1},
+			}
+
+			g.Assert(s.Ulimits()).Equal([]config.Ulimit{
+				{Name: "nofile", Soft: 32768, Hard: 65536},
+				{Name: "memlock", Soft: -1, Hard: -1},
+			})
+		})
+
+		g.It("skips ulimits above the maximum or with a soft limit above the hard limit", func() {
+			s := &Server{}
+			s.cfg.Ulimits = []config.Ulimit{
+				{Name: "nofile", Soft: 1024, Hard: 1048576},
+				{Name: "nofile", Soft: -1, Hard: -1},
+				{Name: "memlock", Soft: 200, Hard: 100},
+			}
+
+			g.Assert(len(s.Ulimits())).Equal(0)
+		})
+	})
+}
@@ -10,6 +10,8 @@ var (
 	ErrServerIsInstalling   = errors.New("server is currently installing")
 	ErrServerIsTransferring = errors.New("server is currently being transferred")
 	ErrServerIsRestoring    = errors.New("server is currently being restored")
+	ErrCommandNotAllowed    = errors.New("command is not allowed for this server")
+	ErrInstallTimeout       = errors.New("installation process exceeded the maximum run time")
 )
 
 type crashTooFrequent struct{}
@@ -178,6 +178,24 @@ func (fs *Filesystem) ExtractStreamUnsafe(ctx context.Context, dir string, r io.
 	})
 }
 
+// ExtractArchive extracts an archive stored outside of the server's data directory
+// into the given directory. The name of the archive is used to identify its format.
+func (fs *Filesystem) ExtractArchive(ctx context.Context, dir string, name string, r io.Reader) error {
+	format, input, err := archiver.Identify(name, r)
+	if err != nil {
+		if errors.Is(err, archiver.ErrNoMatch) {
+			return newFilesystemError(ErrCodeUnknownArchive, err)
+		}
+		return err
+	}
+	return fs.extractStream(ctx, extractStreamOptions{
+		Directory: dir,
+		FileName:  name,
+		Format:    format,
+		Reader:    input,
+	})
+}
+
 type extractStreamOptions struct {
 	// The directory to extract the archive to.
 	Directory string
@@ -52,3 +52,29 @@ func TestFilesystem_DecompressFile(t *testing.T) {
 		})
 	})
 }
+
+func TestFilesystem_ExtractArchive(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("ExtractArchive", func() {
+		for _, ext := range []string{"zip", "tar.gz"} {
+			ext := ext
+			g.It("can extract a "+ext+" from outside the server directory", func() {
+				f, err := os.Open("./testdata/test." + ext)
+				g.Assert(err).IsNil()
+				defer f.Close()
+
+				err = fs.ExtractArchive(context.Background(), "/", "template."+ext, f)
+				g.Assert(err).IsNil()
+
+				_, err = rfs.StatServerFile("test/inside/finside.txt")
+				g.Assert(err).IsNil()
+			})
+		}
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+	})
+}
@@ -2,12 +2,13 @@ package filesystem
 
 import (
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
+	"golang.org/x/sys/unix"
 
+	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/internal/ufs"
 )
 
@@ -45,6 +46,11 @@ func (fs *Filesystem) MaxDisk() int64 {
 // SetDiskLimit sets the disk space limit for this Filesystem instance.
 func (fs *Filesystem) SetDiskLimit(i int64) {
 	fs.unixFS.SetLimit(i)
+	if fs.quota != nil {
+		if err := fs.quota.SetLimit(i); err != nil {
+			log.WithField("root", fs.Path()).WithField("error", err).Warn("failed to update disk quota limit")
+		}
+	}
 }
 
 // The same concept as HasSpaceAvailable however this will return an error if there is
@@ -101,6 +107,17 @@ func (fs *Filesystem) CachedUsage() int64 {
 // This is primarily to avoid a bunch of I/O operations from piling up on the server, especially on servers
 // with a large amount of files.
 func (fs *Filesystem) DiskUsage(allowStaleValue bool) (int64, error) {
+	// Kernel backed quotas track the exact usage, so there is no need to cache the
+	// value or walk the directory.
+	if fs.quota != nil {
+		size, err := fs.quota.Usage()
+		if err == nil {
+			fs.unixFS.SetUsage(size)
+			return size, nil
+		}
+		log.WithField("root", fs.Path()).WithField("error", err).Warn("failed to get disk usage from quota driver")
+	}
+
 	// A disk check interval of 0 means this functionality is completely disabled.
 	if fs.diskCheckInterval == 0 {
 		return 0, nil
@@ -164,7 +181,7 @@ func (fs *Filesystem) DirectorySize(root string) (int64, error) {
 		return 0, err
 	}
 
-	var size atomic.Int64
+	counter := newUsageCounter(config.Get().System.DiskQuota.Accounting == AccountingAllocated)
 	err = fs.unixFS.WalkDirat(dirfd, name, func(dirfd int, name, _ string, d ufs.DirEntry, err error) error {
 		if err != nil {
 			return errors.Wrap(err, "walkdirat err")
@@ -180,13 +197,63 @@ func (fs *Filesystem) DirectorySize(root string) (int64, error) {
 			return errors.Wrap(err, "lstatat err")
 		}
 
-		// TODO: detect if info is a hard-link and de-duplicate it.
-		// ref; https://github.com/pterodactyl/wings/pull/181/files
-
-		size.Add(info.Size())
+		counter.Add(info)
 		return nil
 	})
-	return size.Load(), errors.WrapIf(err, "server/filesystem: directorysize: failed to walk directory")
+	return counter.Size(), errors.WrapIf(err, "server/filesystem: directorysize: failed to walk directory")
+}
+
+// The ways the size of a file can be counted when calculating disk usage.
+const (
+	AccountingApparent  = "apparent"
+	AccountingAllocated = "allocated"
+)
+
+type inode struct {
+	dev uint64
+	ino uint64
+}
+
+// usageCounter sums the size of files, only counting files with multiple hard
+// links the first time they are seen.
+type usageCounter struct {
+	allocated bool
+	seen      map[inode]struct{}
+	size      int64
+}
+
+func newUsageCounter(allocated bool) *usageCounter {
+	return &usageCounter{allocated: allocated, seen: make(map[inode]struct{})}
+}
+
+// Add adds the size of the file to the total and returns the size that was
+// added. If allocated is true, the blocks allocated for the file are counted
+// rather than its size, so that sparse files only count the data actually
+// written to the disk.
+func (c *usageCounter) Add(info ufs.FileInfo) int64 {
+	size := info.Size()
+	if st, ok := info.Sys().(*unix.Stat_t); ok {
+		if st.Nlink > 1 {
+			// Do not remove these "redundant" type-casts, they are required for 32-bit builds to work.
+			id := inode{dev: uint64(st.Dev), ino: uint64(st.Ino)}
+			if _, ok := c.seen[id]; ok {
+				return 0
+			}
+			c.seen[id] = struct{}{}
+		}
+		if c.allocated {
+			// Blocks are always counted in 512-byte units, regardless of the block size
+			// of the filesystem.
+			size = int64(st.Blocks) * 512
+		}
+	}
+	c.size += size
+	return size
+}
+
+// Size returns the total size of the files added to the counter.
+func (c *usageCounter) Size() int64 {
+	return c.size
 }
 
 func (fs *Filesystem) HasSpaceFor(size int64) error {
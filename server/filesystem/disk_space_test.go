@@ -0,0 +1,112 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestFilesystem_DirectorySize(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("DirectorySize", func() {
+		g.BeforeEach(func() {
+			if err := rfs.CreateServerFile("data.bin", make([]byte, 8192)); err != nil {
+				panic(err)
+			}
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+			config.Update(func(c *config.Configuration) {
+				c.System.DiskQuota.Accounting = ""
+			})
+		})
+
+		g.It("counts hard linked files once", func() {
+			err := os.Link(filepath.Join(rfs.root, "server/data.bin"), filepath.Join(rfs.root, "server/link.bin"))
+			g.Assert(err).IsNil()
+
+			size, err := fs.DirectorySize("/")
+			g.Assert(err).IsNil()
+			g.Assert(size).Equal(int64(8192))
+		})
+
+		g.It("counts the apparent size of sparse files by default", func() {
+			err := os.Truncate(filepath.Join(rfs.root, "server/data.bin"), 16*1024*1024)
+			g.Assert(err).IsNil()
+
+			size, err := fs.DirectorySize("/")
+			g.Assert(err).IsNil()
+			g.Assert(size).Equal(int64(16 * 1024 * 1024))
+		})
+
+		g.It("counts the allocated size of sparse files when configured", func() {
+			config.Update(func(c *config.Configuration) {
+				c.System.DiskQuota.Accounting = AccountingAllocated
+			})
+			err := os.Truncate(filepath.Join(rfs.root, "server/data.bin"), 16*1024*1024)
+			g.Assert(err).IsNil()
+
+			size, err := fs.DirectorySize("/")
+			g.Assert(err).IsNil()
+			g.Assert(size < 16*1024*1024).IsTrue()
+		})
+	})
+}
+
+func TestFilesystem_DirectoryUsage(t *testing.T) {
+	g := Goblin(t)
+	fs, rfs := NewFs()
+
+	g.Describe("DirectoryUsage", func() {
+		g.BeforeEach(func() {
+			_ = os.MkdirAll(filepath.Join(rfs.root, "server/world/region"), 0o755)
+			_ = os.MkdirAll(filepath.Join(rfs.root, "server/plugins"), 0o755)
+			_ = rfs.CreateServerFile("small.txt", make([]byte, 10))
+			_ = rfs.CreateServerFile("world/region/r.0.0.mca", make([]byte, 4096))
+			_ = rfs.CreateServerFile("world/level.dat", make([]byte, 1024))
+			_ = rfs.CreateServerFile("plugins/a.jar", make([]byte, 2048))
+		})
+
+		g.AfterEach(func() {
+			_ = fs.TruncateRootDirectory()
+		})
+
+		g.It("returns the size of each child of the root directory", func() {
+			u, err := fs.DirectoryUsage("/")
+			g.Assert(err).IsNil()
+			g.Assert(u.Size).Equal(int64(10 + 4096 + 1024 + 2048))
+			g.Assert(u.Files).Equal(int64(4))
+			g.Assert(u.Directories).Equal(int64(3))
+			g.Assert(u.Children).Equal([]ChildUsage{
+				{Name: "world", Directory: true, Size: 5120, Files: 2, Directories: 1},
+				{Name: "plugins", Directory: true, Size: 2048, Files: 1},
+				{Name: "small.txt", Size: 10, Files: 1},
+			})
+			g.Assert(u.LargestFiles[0]).Equal(FileUsage{Path: "/world/region/r.0.0.mca", Size: 4096})
+			g.Assert(len(u.LargestFiles)).Equal(4)
+		})
+
+		g.It("returns the size of each child of a nested directory", func() {
+			u, err := fs.DirectoryUsage("world")
+			g.Assert(err).IsNil()
+			g.Assert(u.Directory).Equal("/world")
+			g.Assert(u.Children).Equal([]ChildUsage{
+				{Name: "region", Directory: true, Size: 4096, Files: 1},
+				{Name: "level.dat", Size: 1024, Files: 1},
+			})
+			g.Assert(u.LargestFiles[1]).Equal(FileUsage{Path: "/world/level.dat", Size: 1024})
+		})
+
+		g.It("returns an error for a file", func() {
+			_, err := fs.DirectoryUsage("small.txt")
+			g.Assert(err == nil).IsFalse()
+		})
+	})
+}
@@ -23,12 +23,14 @@ import (
 
 type Filesystem struct {
 	unixFS *ufs.Quota
+	quota  quotaDriver
 
 	mu                sync.RWMutex
 	lastLookupTime    *usageLookupTime
 	lookupInProgress  atomic.Bool
 	diskCheckInterval time.Duration
 	denylist          *ignore.GitIgnore
+	usage             usageCache
 
 	isTest bool
 }
@@ -38,6 +40,9 @@ func New(root string, size int64, denylist []string) (*Filesystem, error) {
 	if err := os.MkdirAll(root, 0o755); err != nil {
 		return nil, err
 	}
+	// The quota driver must be configured before the directory is opened since it
+	// may mount a filesystem over the directory.
+	driver := newQuotaDriver(root, size)
 	unixFS, err := ufs.NewUnixFS(root, config.UseOpenat2())
 	if err != nil {
 		return nil, err
@@ -46,6 +51,7 @@ func New(root string, size int64, denylist []string) (*Filesystem, error) {
 
 	return &Filesystem{
 		unixFS: quota,
+		quota:  driver,
 
 		diskCheckInterval: time.Duration(config.Get().System.DiskCheckInterval),
 		lastLookupTime:    &usageLookupTime{},
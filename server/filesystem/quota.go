@@ -0,0 +1,165 @@
+package filesystem
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// The drivers that can be used to track and limit the disk space used by a server.
+const (
+	QuotaDriverWalk     = "walk"
+	QuotaDriverProject  = "project"
+	QuotaDriverLoopback = "loopback"
+)
+
+// ErrQuotaUnsupported is returned when a quota driver cannot be used for the
+// server directory.
+var ErrQuotaUnsupported = errors.Sentinel("filesystem: quota driver is not supported for this directory")
+
+// quotaDriver is a kernel backed quota that enforces the disk space limit for
+// a server directory and reports its exact usage without walking the directory.
+type quotaDriver interface {
+	// Name returns the name of the driver.
+	Name() string
+	// SetLimit sets the maximum amount of disk space in bytes that may be used,
+	// with a value of 0 or less removing the limit.
+	SetLimit(limit int64) error
+	// Usage returns the amount of disk space in bytes currently used.
+	Usage() (int64, error)
+	// Remove removes the quota from the directory, and releases any resources it
+	// used. This is called before the server directory is deleted.
+	Remove() error
+}
+
+// newQuotaDriver returns the quota driver configured for the node for the given
+// directory, or nil if the usage should be calculated by walking the directory.
+// This must be called before the directory is opened, since the loopback driver
+// mounts a filesystem over the directory.
+func newQuotaDriver(root string, limit int64) quotaDriver {
+	var (
+		d   quotaDriver
+		err error
+	)
+	cfg := config.Get().System.DiskQuota
+	switch cfg.Driver {
+	case QuotaDriverProject:
+		d, err = newProjectQuota(root, limit)
+	case QuotaDriverLoopback:
+		d, err = newLoopbackQuota(root, limit, cfg)
+	default:
+		return nil
+	}
+	if err != nil {
+		log.WithFields(log.Fields{"root": root, "driver": cfg.Driver, "error": err}).
+			Warn("failed to configure disk quota driver, falling back to walking the directory")
+		return nil
+	}
+	return d
+}
+
+// QuotaDriver returns the name of the driver used to track and limit the disk
+// space used by the filesystem.
+func (fs *Filesystem) QuotaDriver() string {
+	if fs.quota == nil {
+		return QuotaDriverWalk
+	}
+	return fs.quota.Name()
+}
+
+// RemoveQuota removes the kernel backed quota from the filesystem, if one is in
+// use. This must be called before deleting the server directory.
+func (fs *Filesystem) RemoveQuota() error {
+	if fs.quota == nil {
+		return nil
+	}
+	return fs.quota.Remove()
+}
+
+// mountInfo describes the filesystem mounted at a path.
+type mountInfo struct {
+	Target string
+	FSType string
+	Source string
+}
+
+// findMount returns the mount that contains the given path, using the longest
+// matching mount point from /proc/self/mountinfo.
+func findMount(path string) (mountInfo, error) {
+	path, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return mountInfo{}, errors.WithStack(err)
+	}
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return mountInfo{}, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	var match mountInfo
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// See proc(5) for the format of each line, the fields after the separator
+		// are the filesystem type and mount source.
+		fields := strings.Fields(scanner.Text())
+		sep := -1
+		for i, v := range fields {
+			if v == "-" {
+				sep = i
+				break
+			}
+		}
+		if len(fields) < 5 || sep == -1 || len(fields) < sep+3 {
+			continue
+		}
+		target := unescapeMountPath(fields[4])
+		if target != path && !strings.HasPrefix(path, strings.TrimSuffix(target, "/")+"/") {
+			continue
+		}
+		if len(target) >= len(match.Target) {
+			match = mountInfo{Target: target, FSType: fields[sep+1], Source: unescapeMountPath(fields[sep+2])}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return mountInfo{}, errors.WithStack(err)
+	}
+	if match.Target == "" {
+		return match, errors.Errorf("filesystem: could not find mount for %s", path)
+	}
+	return match, nil
+}
+
+// unescapeMountPath replaces the octal escape sequences used for spaces and other
+// special characters in /proc/self/mountinfo.
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			var c byte
+			valid := true
+			for _, d := range s[i+1 : i+4] {
+				if d < '0' || d > '7' {
+					valid = false
+					break
+				}
+				c = c*8 + byte(d-'0')
+			}
+			if valid {
+				b.WriteByte(c)
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
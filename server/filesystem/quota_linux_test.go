@@ -0,0 +1,82 @@
+package filesystem
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// These tests require a directory on a loop mounted XFS image with project quotas
+// enabled, and must be run as root. For example:
+//
+//	truncate -s 512M /tmp/xfs.img && mkfs.xfs -q /tmp/xfs.img
+//	mkdir -p /mnt/xfs && mount -o loop,prjquota /tmp/xfs.img /mnt/xfs
+//	WINGS_TEST_PRJQUOTA_DIR=/mnt/xfs go test ./server/filesystem -run TestProjectQuota
+func TestProjectQuota(t *testing.T) {
+	dir := os.Getenv("WINGS_TEST_PRJQUOTA_DIR")
+	if dir == "" || os.Geteuid() != 0 {
+		t.Skip("WINGS_TEST_PRJQUOTA_DIR is not set or not running as root")
+	}
+
+	g := Goblin(t)
+
+	g.Describe("Filesystem with project quotas", func() {
+		var fs *Filesystem
+
+		g.BeforeEach(func() {
+			config.Set(&config.Configuration{
+				AuthenticationToken: "abc",
+				System: config.SystemConfiguration{
+					DiskCheckInterval: 150,
+					DiskQuota:         config.DiskQuota{Driver: QuotaDriverProject},
+				},
+			})
+
+			root, err := os.MkdirTemp(dir, "server")
+			if err != nil {
+				panic(err)
+			}
+			if err := os.WriteFile(filepath.Join(root, "existing.txt"), make([]byte, 64*1024), 0o644); err != nil {
+				panic(err)
+			}
+			fs, err = New(root, 1024*1024, []string{})
+			if err != nil {
+				panic(err)
+			}
+		})
+
+		g.AfterEach(func() {
+			_ = fs.UnixFS().Close()
+			_ = fs.RemoveQuota()
+			_ = os.RemoveAll(fs.Path())
+		})
+
+		g.It("uses the project quota driver", func() {
+			g.Assert(fs.QuotaDriver()).Equal(QuotaDriverProject)
+		})
+
+		g.It("includes existing files in the usage", func() {
+			size, err := fs.DiskUsage(false)
+			g.Assert(err).IsNil()
+			g.Assert(size >= 64*1024).IsTrue()
+		})
+
+		g.It("reports writes immediately", func() {
+			before, _ := fs.DiskUsage(false)
+			err := os.WriteFile(filepath.Join(fs.Path(), "new.txt"), make([]byte, 128*1024), 0o644)
+			g.Assert(err).IsNil()
+
+			after, _ := fs.DiskUsage(false)
+			g.Assert(after-before >= 128*1024).IsTrue()
+		})
+
+		g.It("prevents writes beyond the limit", func() {
+			err := os.WriteFile(filepath.Join(fs.Path(), "large.bin"), make([]byte, 2*1024*1024), 0o644)
+			g.Assert(err == nil).IsFalse()
+		})
+	})
+}
@@ -0,0 +1,278 @@
+package filesystem
+
+import (
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"golang.org/x/sys/unix"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+// loopbackQuota limits the disk space used by a server by storing its files on a
+// dedicated filesystem image that is loop mounted over the server directory. The
+// image is sparse, so it only uses the space actually written to it on the host.
+//
+// The filesystem uses part of the image for its own metadata, such as the journal.
+// The space used by an empty filesystem is stored alongside the image as the
+// baseline, which is not counted towards the usage of the server, and the image is
+// sized so that the space left for files matches the limit.
+type loopbackQuota struct {
+	root     string
+	image    string
+	fstype   string
+	baseline int64
+}
+
+func newLoopbackQuota(root string, limit int64, cfg config.DiskQuota) (*loopbackQuota, error) {
+	q := &loopbackQuota{
+		root:   root,
+		image:  filepath.Join(cfg.LoopbackDirectory, filepath.Base(root)+".img"),
+		fstype: cfg.LoopbackFilesystem,
+	}
+	if q.fstype != "xfs" {
+		q.fstype = "ext4"
+	}
+
+	if b, err := os.ReadFile(q.baselinePath()); err == nil {
+		q.baseline, _ = strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+	} else if !os.IsNotExist(err) {
+		return nil, errors.WithStack(err)
+	}
+
+	if m, err := findMount(root); err != nil {
+		return nil, err
+	} else if m.Target == root {
+		// The image is still mounted from a previous run of the process.
+		q.grow(limit)
+		return q, nil
+	}
+
+	created := false
+	if _, err := os.Stat(q.image); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, errors.WithStack(err)
+		}
+		// A size is required to create the image, so servers without a limit need
+		// to use another driver.
+		if limit <= 0 {
+			return nil, errors.WithStack(ErrQuotaUnsupported)
+		}
+		// Existing files would be hidden once the image is mounted over them, so
+		// they must be moved manually before switching drivers.
+		if entries, err := os.ReadDir(root); err != nil {
+			return nil, errors.WithStack(err)
+		} else if len(entries) > 0 {
+			return nil, errors.New("filesystem: server directory must be empty to create a loopback image")
+		}
+		if err := q.create(limit); err != nil {
+			return nil, err
+		}
+		created = true
+	}
+
+	if err := run("mount", "-o", "loop,noatime", q.image, root); err != nil {
+		return nil, errors.Wrap(err, "filesystem: failed to mount loopback image")
+	}
+	if created {
+		// The directory created by mkfs.ext4 would otherwise show up in the server files.
+		_ = os.Remove(filepath.Join(root, "lost+found"))
+		if err := q.writeBaseline(); err != nil {
+			log.WithFields(log.Fields{"root": q.root, "error": err}).
+				Warn("filesystem: failed to store loopback filesystem baseline, metadata will be counted as usage after a restart")
+		}
+	}
+	q.grow(limit)
+	return q, nil
+}
+
+// grow grows the mounted image to the limit. The image remains in use if it cannot
+// be grown, since the files of the server are already stored on it.
+func (q *loopbackQuota) grow(limit int64) {
+	if err := q.SetLimit(limit); err != nil {
+		log.WithFields(log.Fields{"root": q.root, "limit": limit, "error": err}).
+			Warn("filesystem: failed to grow loopback image to the disk limit")
+	}
+}
+
+func (q *loopbackQuota) baselinePath() string {
+	return q.image + ".baseline"
+}
+
+// writeBaseline stores the space used by the newly created filesystem before any
+// files have been written to it.
+func (q *loopbackQuota) writeBaseline() error {
+	st, err := q.statfs()
+	if err != nil {
+		return err
+	}
+	q.baseline = int64(st.Blocks-st.Bfree) * st.Bsize
+	return errors.WithStack(os.WriteFile(q.baselinePath(), []byte(strconv.FormatInt(q.baseline, 10)), 0o600))
+}
+
+func (q *loopbackQuota) statfs() (*unix.Statfs_t, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(q.root, &st); err != nil {
+		return nil, errors.Wrap(err, "filesystem: failed to stat loopback filesystem")
+	}
+	return &st, nil
+}
+
+// create creates and formats a new sparse image with the given amount of space
+// available for files. The image is formatted at the size of the limit first, and
+// formatted again at a larger size if the metadata of the filesystem leaves less
+// space than the limit.
+func (q *loopbackQuota) create(limit int64) error {
+	if err := os.MkdirAll(filepath.Dir(q.image), 0o700); err != nil {
+		return errors.WithStack(err)
+	}
+	f, err := os.OpenFile(q.image, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_ = f.Close()
+
+	size := limit
+	for i := 0; i < 3; i++ {
+		var capacity int64
+		if err = q.format(size); err != nil {
+			break
+		}
+		if capacity, err = q.capacity(); err != nil || capacity >= limit {
+			break
+		}
+		size = imageSize(limit, size, capacity)
+	}
+	if err != nil {
+		_ = os.Remove(q.image)
+		return errors.Wrap(err, "filesystem: failed to create loopback image")
+	}
+	return nil
+}
+
+// format resizes the image and formats it with a new filesystem.
+func (q *loopbackQuota) format(size int64) error {
+	if err := os.Truncate(q.image, size); err != nil {
+		return errors.WithStack(err)
+	}
+	if q.fstype == "xfs" {
+		return run("mkfs.xfs", "-q", "-f", q.image)
+	}
+	// Reserved blocks are only useful for the root user on system disks.
+	return run("mkfs.ext4", "-q", "-F", "-m", "0", q.image)
+}
+
+// capacity temporarily mounts the newly formatted image and returns the space
+// available for files on it.
+func (q *loopbackQuota) capacity() (int64, error) {
+	if err := run("mount", "-o", "loop,noatime", q.image, q.root); err != nil {
+		return 0, err
+	}
+	st, err := q.statfs()
+	if uerr := run("umount", q.root); err == nil {
+		err = uerr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * st.Bsize, nil
+}
+
+func (q *loopbackQuota) Name() string {
+	return QuotaDriverLoopback
+}
+
+// SetLimit grows the image so that the space available for files matches the new
+// limit. Images cannot be shrunk while they are mounted, so lowering the limit is
+// only enforced by Wings itself until the image is recreated.
+func (q *loopbackQuota) SetLimit(limit int64) error {
+	if limit <= 0 {
+		return nil
+	}
+	fi, err := os.Stat(q.image)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	st, err := q.statfs()
+	if err != nil {
+		return err
+	}
+	// The capacity of the image is the space available to files, along with the
+	// space already used by them.
+	capacity := int64(st.Bavail)*st.Bsize + int64(st.Blocks-st.Bfree)*st.Bsize - q.baseline
+	size := imageSize(limit, fi.Size(), capacity)
+	if size <= fi.Size() {
+		if size < fi.Size() {
+			log.WithFields(log.Fields{"root": q.root, "limit": limit, "size": fi.Size()}).
+				Debug("filesystem: loopback image is larger than the disk limit, shrinking is not supported")
+		}
+		return nil
+	}
+
+	m, err := findMount(q.root)
+	if err != nil {
+		return err
+	}
+	if err := os.Truncate(q.image, size); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := run("losetup", "-c", m.Source); err != nil {
+		return errors.Wrap(err, "filesystem: failed to resize loop device")
+	}
+	if q.fstype == "xfs" {
+		err = run("xfs_growfs", q.root)
+	} else {
+		err = run("resize2fs", m.Source)
+	}
+	return errors.Wrap(err, "filesystem: failed to grow loopback filesystem")
+}
+
+// imageSize returns the size the image needs to be for the given amount of space
+// to be available for files, based on the space available in an image of the
+// current size. The metadata of the filesystem grows along with the image, so
+// the image is scaled by the same ratio. The size is rounded up to 1MiB.
+func imageSize(limit int64, size int64, capacity int64) int64 {
+	if capacity <= 0 || capacity >= size {
+		return limit
+	}
+	out := int64(math.Ceil(float64(limit) * float64(size) / float64(capacity)))
+	return (out + 1<<20 - 1) &^ (1<<20 - 1)
+}
+
+// Usage returns the space used by files on the mounted filesystem, excluding the
+// space used by the filesystem itself when it was created.
+func (q *loopbackQuota) Usage() (int64, error) {
+	st, err := q.statfs()
+	if err != nil {
+		return 0, err
+	}
+	if used := int64(st.Blocks-st.Bfree)*st.Bsize - q.baseline; used > 0 {
+		return used, nil
+	}
+	return 0, nil
+}
+
+// Remove unmounts the image and deletes it.
+func (q *loopbackQuota) Remove() error {
+	if err := run("umount", q.root); err != nil {
+		return errors.Wrap(err, "filesystem: failed to unmount loopback image")
+	}
+	if err := os.Remove(q.baselinePath()); err != nil && !os.IsNotExist(err) {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Remove(q.image))
+}
+
+func run(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return errors.Wrapf(err, "%s: %s", name, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package filesystem
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+)
+
+func TestLoopbackQuota_imageSize(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("imageSize", func() {
+		g.It("scales the image by the filesystem overhead", func() {
+			g.Assert(imageSize(90<<20, 100<<20, 90<<20)).Equal(int64(100 << 20))
+			g.Assert(imageSize(180<<20, 100<<20, 90<<20)).Equal(int64(200 << 20))
+		})
+
+		g.It("rounds the size up to 1MiB", func() {
+			g.Assert(imageSize(1<<20+1, 1<<20, 1<<20-1)).Equal(int64(2 << 20))
+		})
+
+		g.It("uses the limit if the capacity is unknown", func() {
+			g.Assert(imageSize(64<<20, 64<<20, 0)).Equal(int64(64 << 20))
+		})
+	})
+}
+
+// These tests create and loop mount ext4 images, so they must be run as root on
+// a system with mkfs.ext4, losetup and resize2fs installed.
+func TestLoopbackQuota(t *testing.T) {
+	for _, bin := range []string{"mkfs.ext4", "losetup", "resize2fs", "mount"} {
+		if _, err := exec.LookPath(bin); err != nil || os.Geteuid() != 0 {
+			t.Skip("not running as root or " + bin + " is not installed")
+		}
+	}
+
+	g := Goblin(t)
+
+	g.Describe("Filesystem with a loopback quota", func() {
+		var fs *Filesystem
+		var dir string
+		const limit = 32 * 1024 * 1024
+
+		g.BeforeEach(func() {
+			var err error
+			dir, err = os.MkdirTemp("", "loopback")
+			if err != nil {
+				panic(err)
+			}
+			config.Set(&config.Configuration{
+				AuthenticationToken: "abc",
+				System: config.SystemConfiguration{
+					DiskCheckInterval: 150,
+					DiskQuota: config.DiskQuota{
+						Driver:             QuotaDriverLoopback,
+						LoopbackDirectory:  filepath.Join(dir, "images"),
+						LoopbackFilesystem: "ext4",
+					},
+				},
+			})
+
+			root := filepath.Join(dir, "server")
+			if err := os.Mkdir(root, 0o700); err != nil {
+				panic(err)
+			}
+			fs, err = New(root, limit, []string{})
+			if err != nil {
+				panic(err)
+			}
+		})
+
+		g.AfterEach(func() {
+			_ = fs.UnixFS().Close()
+			_ = fs.RemoveQuota()
+			_ = os.RemoveAll(dir)
+		})
+
+		g.It("uses the loopback quota driver", func() {
+			g.Assert(fs.QuotaDriver()).Equal(QuotaDriverLoopback)
+		})
+
+		g.It("does not count the filesystem metadata towards the usage", func() {
+			size, err := fs.DiskUsage(false)
+			g.Assert(err).IsNil()
+			g.Assert(size < 64*1024).IsTrue()
+		})
+
+		g.It("reports writes immediately", func() {
+			before, _ := fs.DiskUsage(false)
+			err := os.WriteFile(filepath.Join(fs.Path(), "new.txt"), make([]byte, 128*1024), 0o644)
+			g.Assert(err).IsNil()
+
+			after, _ := fs.DiskUsage(false)
+			g.Assert(after-before >= 128*1024).IsTrue()
+		})
+
+		g.It("allows files up to the limit to be written", func() {
+			err := os.WriteFile(filepath.Join(fs.Path(), "large.bin"), make([]byte, limit-1024*1024), 0o644)
+			g.Assert(err).IsNil()
+		})
+
+		// The filesystem metadata does not scale exactly with the size of the image, so
+		// the image may leave slightly more space than the limit.
+		g.It("prevents writes beyond the limit", func() {
+			err := os.WriteFile(filepath.Join(fs.Path(), "large.bin"), make([]byte, limit+4*1024*1024), 0o644)
+			g.Assert(err == nil).IsFalse()
+		})
+	})
+}
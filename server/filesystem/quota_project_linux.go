@@ -0,0 +1,257 @@
+package filesystem
+
+import (
+	"hash/fnv"
+	"os"
+	"path/filepath"
+	"unsafe"
+
+	"emperror.dev/errors"
+	"golang.org/x/sys/unix"
+)
+
+// Constants from linux/fs.h and linux/quota.h that are not provided by the
+// unix package.
+const (
+	fsIocFsGetXAttr     = 0x801c581f
+	fsIocFsSetXAttr     = 0x401c5820
+	fsXFlagProjInherit  = 0x00000200
+	qGetQuota           = 0x800007
+	qSetQuota           = 0x800008
+	prjQuota            = 2
+	qifBLimits          = 1
+	quotaBlockSize      = 1024
+	minProjectID        = 1 << 20
+	projectIDRange      = 1 << 30
+	projectIDMaxRetries = 1024
+)
+
+// fsXAttr matches struct fsxattr from linux/fs.h.
+type fsXAttr struct {
+	XFlags     uint32
+	ExtSize    uint32
+	NExtents   uint32
+	ProjID     uint32
+	CowExtSize uint32
+	_          [8]byte
+}
+
+// dqBlk matches struct if_dqblk from linux/quota.h.
+type dqBlk struct {
+	BHardLimit uint64
+	BSoftLimit uint64
+	CurSpace   uint64
+	IHardLimit uint64
+	ISoftLimit uint64
+	CurInodes  uint64
+	BTime      uint64
+	ITime      uint64
+	Valid      uint32
+	_          uint32
+}
+
+// projectQuota limits the disk space used by a server directory using XFS or
+// ext4 project quotas. Every file in the directory is assigned a project ID that
+// is unique to the server, and the directory is flagged so that new files inherit
+// the project ID.
+type projectQuota struct {
+	root   string
+	device string
+	id     uint32
+}
+
+func newProjectQuota(root string, limit int64) (*projectQuota, error) {
+	m, err := findMount(root)
+	if err != nil {
+		return nil, err
+	}
+	if m.FSType != "xfs" && m.FSType != "ext4" {
+		return nil, errors.WithStack(ErrQuotaUnsupported)
+	}
+
+	q := &projectQuota{root: root, device: m.Source}
+	attr, err := getFsXAttr(root)
+	if err != nil {
+		return nil, err
+	}
+	if attr.ProjID != 0 && attr.XFlags&fsXFlagProjInherit != 0 {
+		q.id = attr.ProjID
+	} else {
+		if q.id, err = allocateProjectID(root); err != nil {
+			return nil, err
+		}
+		if err := q.assign(); err != nil {
+			return nil, err
+		}
+	}
+	if err := q.SetLimit(limit); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+func (q *projectQuota) Name() string {
+	return QuotaDriverProject
+}
+
+// SetLimit sets the hard limit of the project quota for the directory.
+func (q *projectQuota) SetLimit(limit int64) error {
+	dq := dqBlk{Valid: qifBLimits}
+	if limit > 0 {
+		dq.BHardLimit = uint64((limit + quotaBlockSize - 1) / quotaBlockSize)
+		dq.BSoftLimit = dq.BHardLimit
+	}
+	return errors.Wrap(q.quotactl(qSetQuota, &dq), "filesystem: failed to set project quota")
+}
+
+// Usage returns the space used by the project as tracked by the kernel.
+func (q *projectQuota) Usage() (int64, error) {
+	var dq dqBlk
+	if err := q.quotactl(qGetQuota, &dq); err != nil {
+		return 0, errors.Wrap(err, "filesystem: failed to get project quota")
+	}
+	return int64(dq.CurSpace), nil
+}
+
+// Remove clears the limit for the project.
+func (q *projectQuota) Remove() error {
+	return q.SetLimit(0)
+}
+
+func (q *projectQuota) quotactl(cmd int, dq *dqBlk) error {
+	dev, err := unix.BytePtrFromString(q.device)
+	if err != nil {
+		return err
+	}
+	_, _, errno := unix.Syscall6(unix.SYS_QUOTACTL, uintptr(cmd<<8|prjQuota), uintptr(unsafe.Pointer(dev)), uintptr(q.id), uintptr(unsafe.Pointer(dq)), 0, 0)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// assign sets the project ID on every file and directory in the root, and flags
+// each directory so that files created within it inherit the project ID. Every
+// entry is opened relative to its parent directory without following symlinks,
+// so that entries swapped out while walking cannot redirect the walk outside of
+// the root.
+func (q *projectQuota) assign() error {
+	fd, err := unix.Open(q.root, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_NOFOLLOW|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return errors.Wrap(err, "filesystem: failed to open root directory")
+	}
+	f := os.NewFile(uintptr(fd), q.root)
+	defer f.Close()
+	return q.assignDir(f)
+}
+
+// assignDir sets the project ID on the open directory and everything within it.
+func (q *projectQuota) assignDir(dir *os.File) error {
+	if err := setProjectID(dir, q.id, true); err != nil {
+		return err
+	}
+	names, err := dir.Readdirnames(-1)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, name := range names {
+		if err := q.assignAt(dir, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// assignAt sets the project ID on the entry with the given name in the directory.
+// Project IDs can only be set on regular files and directories, so anything else
+// is skipped, as is anything that no longer exists.
+func (q *projectQuota) assignAt(dir *os.File, name string) error {
+	fd, err := unix.Openat(int(dir.Fd()), name, unix.O_RDONLY|unix.O_NOFOLLOW|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		if errors.Is(err, unix.ENOENT) || errors.Is(err, unix.ELOOP) || errors.Is(err, unix.ENXIO) {
+			return nil
+		}
+		return errors.Wrap(err, "filesystem: failed to open file")
+	}
+	f := os.NewFile(uintptr(fd), filepath.Join(dir.Name(), name))
+	defer f.Close()
+
+	var st unix.Stat_t
+	if err := unix.Fstat(fd, &st); err != nil {
+		return errors.Wrap(err, "filesystem: failed to stat file")
+	}
+	switch st.Mode & unix.S_IFMT {
+	case unix.S_IFDIR:
+		return q.assignDir(f)
+	case unix.S_IFREG:
+		return setProjectID(f, q.id, false)
+	}
+	return nil
+}
+
+// allocateProjectID returns a project ID for the directory that is not used by
+// any of the other directories alongside it. The ID is derived from the name of
+// the directory so that it is stable across restarts.
+func allocateProjectID(root string) (uint32, error) {
+	used := make(map[uint32]bool)
+	entries, err := os.ReadDir(filepath.Dir(root))
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() || e.Name() == filepath.Base(root) {
+			continue
+		}
+		if attr, err := getFsXAttr(filepath.Join(filepath.Dir(root), e.Name())); err == nil && attr.ProjID != 0 {
+			used[attr.ProjID] = true
+		}
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(filepath.Base(root)))
+	id := minProjectID + h.Sum32()%projectIDRange
+	for i := 0; i < projectIDMaxRetries; i++ {
+		if !used[id] {
+			return id, nil
+		}
+		id++
+	}
+	return 0, errors.New("filesystem: could not allocate a project id")
+}
+
+func getFsXAttr(p string) (fsXAttr, error) {
+	var attr fsXAttr
+	f, err := os.Open(p)
+	if err != nil {
+		return attr, errors.WithStack(err)
+	}
+	defer f.Close()
+	if err := ioctl(f.Fd(), fsIocFsGetXAttr, &attr); err != nil {
+		return attr, errors.Wrap(err, "filesystem: failed to get file attributes")
+	}
+	return attr, nil
+}
+
+// setProjectID sets the project ID on the open file, flagging directories so that
+// files created within them inherit the project ID.
+func setProjectID(f *os.File, id uint32, dir bool) error {
+	var attr fsXAttr
+	if err := ioctl(f.Fd(), fsIocFsGetXAttr, &attr); err != nil {
+		return errors.Wrap(err, "filesystem: failed to get file attributes")
+	}
+	attr.ProjID = id
+	if dir {
+		attr.XFlags |= fsXFlagProjInherit
+	}
+	if err := ioctl(f.Fd(), fsIocFsSetXAttr, &attr); err != nil {
+		return errors.Wrap(err, "filesystem: failed to set project id")
+	}
+	return nil
+}
+
+func ioctl(fd uintptr, req uint, attr *fsXAttr) error {
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, fd, uintptr(req), uintptr(unsafe.Pointer(attr))); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package filesystem
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"emperror.dev/errors"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/internal/ufs"
+)
+
+// The number of files returned in the list of the largest files in a directory,
+// and the number of directory breakdowns kept in the cache for each server.
+const (
+	largestFilesCount = 25
+	usageCacheSize    = 32
+)
+
+// DirectoryUsage describes the disk space used by a directory, broken down by
+// each of its immediate children.
+type DirectoryUsage struct {
+	Directory    string       `json:"directory"`
+	Size         int64        `json:"size"`
+	Files        int64        `json:"files"`
+	Directories  int64        `json:"directories"`
+	Children     []ChildUsage `json:"children"`
+	LargestFiles []FileUsage  `json:"largest_files"`
+	CalculatedAt time.Time    `json:"calculated_at"`
+}
+
+// ChildUsage describes the disk space used by a file or directory, including all
+// of its descendants.
+type ChildUsage struct {
+	Name        string `json:"name"`
+	Directory   bool   `json:"directory"`
+	Size        int64  `json:"size"`
+	Files       int64  `json:"files"`
+	Directories int64  `json:"directories"`
+}
+
+// FileUsage describes the disk space used by a single file.
+type FileUsage struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// usageCache stores recently calculated directory breakdowns so that repeated
+// requests do not walk the directory again.
+type usageCache struct {
+	mu      sync.Mutex
+	entries map[string]*DirectoryUsage
+}
+
+// DirectoryUsage returns the disk space used by each of the immediate children
+// of the directory, along with the largest files within it. Results are cached
+// for the same amount of time as the total disk usage of the server.
+func (fs *Filesystem) DirectoryUsage(dir string) (*DirectoryUsage, error) {
+	dir = path.Clean("/" + strings.TrimPrefix(dir, "/"))
+
+	// Only one breakdown is calculated at a time for each server to avoid piling up
+	// expensive walks of the same directories.
+	fs.usage.mu.Lock()
+	defer fs.usage.mu.Unlock()
+
+	ttl := time.Second * fs.diskCheckInterval
+	if u, ok := fs.usage.entries[dir]; ok && time.Since(u.CalculatedAt) < ttl {
+		return u, nil
+	}
+
+	u, err := fs.directoryUsage(dir)
+	if err != nil {
+		return nil, err
+	}
+	if ttl > 0 {
+		if fs.usage.entries == nil {
+			fs.usage.entries = make(map[string]*DirectoryUsage)
+		}
+		for k, v := range fs.usage.entries {
+			if time.Since(v.CalculatedAt) >= ttl || len(fs.usage.entries) >= usageCacheSize {
+				delete(fs.usage.entries, k)
+			}
+		}
+		fs.usage.entries[dir] = u
+	}
+	return u, nil
+}
+
+func (fs *Filesystem) directoryUsage(dir string) (*DirectoryUsage, error) {
+	dirfd, name, closeFd, err := fs.unixFS.SafePath(dir)
+	defer closeFd()
+	if err != nil {
+		return nil, err
+	}
+	if st, err := fs.unixFS.Lstatat(dirfd, name); err != nil {
+		return nil, err
+	} else if !st.IsDir() {
+		return nil, errors.WithStack(ufs.ErrNotDirectory)
+	}
+
+	u := &DirectoryUsage{Directory: dir, Children: []ChildUsage{}, LargestFiles: []FileUsage{}}
+	children := make(map[string]*ChildUsage)
+	counter := newUsageCounter(config.Get().System.DiskQuota.Accounting == AccountingAllocated)
+	err = fs.unixFS.WalkDirat(dirfd, name, func(dirfd int, n, relative string, d ufs.DirEntry, err error) error {
+		if err != nil {
+			return errors.Wrap(err, "walkdirat err")
+		}
+		// The relative path includes the name of the directory the walk started at.
+		if relative == name {
+			return nil
+		}
+		if name != "." {
+			relative = strings.TrimPrefix(relative, name+"/")
+		}
+		top, _, nested := strings.Cut(relative, "/")
+
+		c, ok := children[top]
+		if !ok {
+			c = &ChildUsage{Name: top}
+			children[top] = c
+		}
+		if d.IsDir() {
+			u.Directories++
+			if nested {
+				c.Directories++
+			} else {
+				c.Directory = true
+			}
+			return nil
+		}
+		// Only calculate the size of regular files.
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := fs.unixFS.Lstatat(dirfd, n)
+		if err != nil {
+			return errors.Wrap(err, "lstatat err")
+		}
+		size := counter.Add(info)
+		u.Files++
+		c.Files++
+		c.Size += size
+		u.LargestFiles = addLargestFile(u.LargestFiles, FileUsage{Path: path.Join(dir, relative), Size: size})
+		return nil
+	})
+	if err != nil {
+		return nil, errors.WrapIf(err, "server/filesystem: directoryusage: failed to walk directory")
+	}
+
+	for _, c := range children {
+		u.Children = append(u.Children, *c)
+	}
+	sort.Slice(u.Children, func(i, j int) bool {
+		if u.Children[i].Size == u.Children[j].Size {
+			return u.Children[i].Name < u.Children[j].Name
+		}
+		return u.Children[i].Size > u.Children[j].Size
+	})
+	u.Size = counter.Size()
+	u.CalculatedAt = time.Now()
+	return u, nil
+}
+
+// addLargestFile inserts the file into the list of largest files, which is kept
+// sorted from largest to smallest, if it is large enough to be included.
+func addLargestFile(files []FileUsage, f FileUsage) []FileUsage {
+	if len(files) == largestFilesCount && f.Size <= files[len(files)-1].Size {
+		return files
+	}
+	i := sort.Search(len(files), func(i int) bool {
+		return files[i].Size < f.Size
+	})
+	if len(files) < largestFilesCount {
+		files = append(files, FileUsage{})
+	}
+	copy(files[i+1:], files[i:])
+	files[i] = f
+	return files
+}
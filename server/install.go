@@ -1,12 +1,15 @@
 package server
 
 import (
-	"bufio"
+	"bytes"
 	"context"
+	"fmt"
 	"html/template"
 	"io"
+	"net"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -20,6 +23,9 @@ import (
 
 	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/environment"
+	"github.com/pterodactyl/wings/environment/docker"
+	"github.com/pterodactyl/wings/internal/models"
+	"github.com/pterodactyl/wings/internal/templates"
 	"github.com/pterodactyl/wings/remote"
 	"github.com/pterodactyl/wings/system"
 )
@@ -113,10 +119,18 @@ func (s *Server) internalInstall() error {
 	return nil
 }
 
+// The time to wait before retrying an installation process that failed due to a
+// network error, multiplied by the number of the attempt.
+var installRetryDelay = time.Second * 10
+
 type InstallationProcess struct {
 	Server *Server
 	Script *remote.InstallationScript
 	client *client.Client
+	// The output of the installation script for the current attempt.
+	output bytes.Buffer
+	// Set once the installation template has been unpacked.
+	unpacked bool
 }
 
 // NewInstallationProcess returns a new installation process struct that will be
@@ -175,6 +189,9 @@ func (ip *InstallationProcess) RemoveContainer() error {
 // This will configure the required environment, and then spin up the
 // installation container. Once the container finishes installing the results
 // are stored in an installation log in the server's configuration directory.
+//
+// If the egg allows it, the process is retried when it fails due to a network
+// error. Every attempt is stored in the server's install history.
 func (ip *InstallationProcess) Run() error {
 	ip.Server.Log().Debug("acquiring installation process lock")
 	if !ip.Server.installing.SwapIf(true) {
@@ -189,25 +206,158 @@ func (ip *InstallationProcess) Run() error {
 		ip.Server.installing.Store(false)
 	}()
 
-	if err := ip.BeforeExecute(); err != nil {
-		return err
+	return ip.retry(ip.Server.Config().Egg.InstallRetries, ip.run)
+}
+
+// retry calls run until an attempt succeeds, fails with an error that cannot be
+// retried, or the egg's number of retries has been used up. The delay between
+// attempts increases with each attempt.
+func (ip *InstallationProcess) retry(retries int, run func(attempt int) (bool, error)) error {
+	for attempt := 1; ; attempt++ {
+		retry, err := run(attempt)
+		if !retry || attempt > retries {
+			return err
+		}
+
+		delay := time.Duration(attempt) * installRetryDelay
+		ip.Server.Log().WithFields(log.Fields{"attempt": attempt, "error": err}).Warn("installation process failed due to a network error, retrying")
+		ip.Server.Events().Publish(DaemonMessageEvent, fmt.Sprintf("Installation process failed due to a network error, retrying in %s...", delay))
+		select {
+		case <-ip.Server.Context().Done():
+			return err
+		case <-time.After(delay):
+		}
 	}
+}
+
+// run executes a single attempt of the installation process and stores it in the
+// install history. Returns true if the attempt failed due to a network error and
+// may be retried.
+func (ip *InstallationProcess) run(attempt int) (bool, error) {
+	record := &models.Install{Server: ip.Server.ID(), Attempt: attempt, Image: ip.Script.ContainerImage, ExitCode: -1}
+	started := time.Now()
+	ip.output.Reset()
+
+	retry, err := func() (bool, error) {
+		if err := ip.unpackTemplate(); err != nil {
+			return isNetworkError(err), err
+		}
+		if ip.Script.Template != nil && ip.Script.Template.SkipScript {
+			return false, nil
+		}
+
+		if err := ip.BeforeExecute(); err != nil {
+			return isNetworkError(err), err
+		}
+
+		cID, code, err := ip.Execute()
+		record.ExitCode = code
+		if cID == "" {
+			_ = ip.RemoveContainer()
+			return false, err
+		}
+
+		// If this step fails, log a warning but don't exit out of the process. This is completely
+		// internal to the daemon's functionality, and does not affect the status of the server itself.
+		if err := ip.AfterExecute(cID); err != nil {
+			ip.Server.Log().WithField("error", err).Warn("failed to complete after-execute step of installation process")
+		}
+		if err != nil {
+			return false, err
+		}
+		return exitCodeError(code)
+	}()
 
-	cID, err := ip.Execute()
+	record.Successful = err == nil
+	record.TimedOut = errors.Is(err, ErrInstallTimeout)
 	if err != nil {
-		_ = ip.RemoveContainer()
+		record.Error = err.Error()
+	}
+	record.Log = ip.output.String()
+	record.Duration = time.Since(started).Milliseconds()
+	ip.Server.saveInstallRun(record)
+
+	return retry, err
+}
+
+// exitCodeError returns an error if the installation script exited with a non-zero
+// exit code, and whether the exit code is one of the network error codes that may
+// be retried.
+func exitCodeError(code int64) (bool, error) {
+	if code == 0 {
+		return false, nil
+	}
+	if slices.Contains(config.Get().Docker.InstallerRetryExitCodes, code) {
+		return true, errors.Errorf("install: installation script exited with network error code %d", code)
+	}
+	return false, errors.Errorf("install: installation script exited with code %d", code)
+}
+
+// unpackTemplate unpacks the installation template into the server's data directory
+// if the installation script has one. The template is only unpacked once, even if
+// the installation process is retried.
+func (ip *InstallationProcess) unpackTemplate() error {
+	t := ip.Script.Template
+	if t == nil || ip.unpacked {
+		return nil
+	}
+	if err := ip.Server.EnsureDataDirectoryExists(); err != nil {
 		return err
 	}
 
-	// If this step fails, log a warning but don't exit out of the process. This is completely
-	// internal to the daemon's functionality, and does not affect the status of the server itself.
-	if err := ip.AfterExecute(cID); err != nil {
-		ip.Server.Log().WithField("error", err).Warn("failed to complete after-execute step of installation process")
+	ip.Server.Events().Publish(DaemonMessageEvent, "Unpacking installation template, this could take a few minutes...")
+	f, name, err := templates.Open(ip.Server.Context(), t)
+	if err != nil {
+		return errors.WrapIf(err, "install: failed to open installation template")
 	}
+	defer f.Close()
 
+	ip.Server.Log().WithField("template", name).Info("unpacking installation template for server")
+	if err := ip.Server.Filesystem().ExtractArchive(ip.Server.Context(), "/", name, f); err != nil {
+		return errors.WrapIf(err, "install: failed to unpack installation template")
+	}
+	ip.unpacked = true
+	ip.Server.Events().Publish(DaemonMessageEvent, "Installation template unpacked.")
 	return nil
 }
 
+// timeout returns the maximum amount of time the installation script may run for,
+// or 0 if there is no limit.
+func (ip *InstallationProcess) timeout() time.Duration {
+	t := config.Get().Docker.InstallerTimeout
+	if egg := ip.Server.Config().Egg.InstallTimeout; egg > 0 {
+		t = egg
+	}
+	return time.Duration(t) * time.Second
+}
+
+// isNetworkError returns true if the error was caused by a network failure, such
+// as a registry being unreachable while pulling the installation image. Errors
+// returned by the Docker daemon only contain the message of the underlying error
+// so they are matched by their contents.
+func isNetworkError(err error) bool {
+	var nerr net.Error
+	if errors.As(err, &nerr) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range []string{
+		"i/o timeout",
+		"connection reset",
+		"connection refused",
+		"no such host",
+		"network is unreachable",
+		"temporary failure in name resolution",
+		"tls handshake timeout",
+		"unexpected eof",
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
+
 // Returns the location of the temporary data for the installation process.
 func (ip *InstallationProcess) tempDir() string {
 	return filepath.Join(config.Get().System.TmpDirectory, ip.Server.ID())
@@ -232,75 +382,17 @@ func (ip *InstallationProcess) writeScriptToDisk() error {
 	return nil
 }
 
-// Pulls the docker image to be used for the installation container.
+// Pulls the docker image to be used for the installation container. Credentials
+// provided with the installation script are used if present, otherwise those
+// configured for the server are used.
 func (ip *InstallationProcess) pullInstallationImage() error {
-	// Get a registry auth configuration from the config.
-	var registryAuth *config.RegistryConfiguration
-	for registry, c := range config.Get().Docker.Registries {
-		if !strings.HasPrefix(ip.Script.ContainerImage, registry) {
-			continue
-		}
-
-		log.WithField("registry", registry).Debug("using authentication for registry")
-		registryAuth = &c
-		break
-	}
-
-	// Get the ImagePullOptions.
-	imagePullOptions := types.ImagePullOptions{All: false}
-	if registryAuth != nil {
-		b64, err := registryAuth.Base64()
-		if err != nil {
-			log.WithError(err).Error("failed to get registry auth credentials")
-		}
-
-		// b64 is a string so if there is an error it will just be empty, not nil.
-		imagePullOptions.RegistryAuth = b64
+	creds := ip.Script.RegistryAuth
+	if creds.IsEmpty() {
+		creds = ip.Server.Config().RegistryCredentials()
 	}
-
-	r, err := ip.client.ImagePull(ip.Server.Context(), ip.Script.ContainerImage, imagePullOptions)
-	if err != nil {
-		images, ierr := ip.client.ImageList(ip.Server.Context(), types.ImageListOptions{})
-		if ierr != nil {
-			// Well damn, something has gone really wrong here, just go ahead and abort there
-			// isn't much anything we can do to try and self-recover from this.
-			return ierr
-		}
-
-		for _, img := range images {
-			for _, t := range img.RepoTags {
-				if t != ip.Script.ContainerImage {
-					continue
-				}
-
-				log.WithFields(log.Fields{
-					"image": ip.Script.ContainerImage,
-					"err":   err.Error(),
-				}).Warn("unable to pull requested image from remote source, however the image exists locally")
-
-				// Okay, we found a matching container image, in that case just go ahead and return
-				// from this function, since there is nothing else we need to do here.
-				return nil
-			}
-		}
-
-		return err
-	}
-	defer r.Close()
-
-	log.WithField("image", ip.Script.ContainerImage).Debug("pulling docker image... this could take a bit of time")
-
-	// Block continuation until the image has been pulled successfully.
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		log.Debug(scanner.Text())
-	}
-
-	if err := scanner.Err(); err != nil {
-		return err
-	}
-
-	return nil
+	return docker.PullImage(ip.Server.Context(), ip.client, ip.Script.ContainerImage, creds, func(p docker.PullProgress) {
+		log.Debug(p.Status + " " + p.Progress)
+	})
 }
 
 // BeforeExecute runs before the container is executed. This pulls down the
@@ -380,7 +472,7 @@ func (ip *InstallationProcess) AfterExecute(containerId string) error {
 		return err
 	}
 
-	if _, err := io.Copy(f, reader); err != nil {
+	if _, err := io.Copy(io.MultiWriter(f, &ip.output), reader); err != nil {
 		return err
 	}
 
@@ -388,8 +480,11 @@ func (ip *InstallationProcess) AfterExecute(containerId string) error {
 }
 
 // Execute executes the installation process inside a specially created docker
-// container.
-func (ip *InstallationProcess) Execute() (string, error) {
+// container. Returns the ID of the container and the exit code of the script, or
+// -1 if the script did not finish. The container ID is returned alongside an error
+// if the container was created, for example when the script exceeds the maximum
+// run time and is terminated.
+func (ip *InstallationProcess) Execute() (string, int64, error) {
 	// Create a child context that is canceled once this function is done running. This
 	// will also be canceled if the parent context (from the Server struct) is canceled
 	// which occurs if the server is deleted.
@@ -438,13 +533,15 @@ func (ip *InstallationProcess) Execute() (string, error) {
 		NetworkMode: container.NetworkMode(cfg.Docker.Network.Mode),
 		UsernsMode:  container.UsernsMode(cfg.Docker.UsernsMode),
 	}
+	hostConf.Ulimits = docker.ContainerUlimits(cfg.Docker.Ulimits, ip.Server.Ulimits())
+	hostConf.Devices = docker.ContainerDevices(cfg.Docker.Devices, ip.Server.Devices())
 
 	// Ensure the root directory for the server exists properly before attempting
 	// to trigger the reinstall of the server. It is possible the directory would
 	// not exist when this runs if Wings boots with a missing directory and a user
 	// triggers a reinstall before trying to start the server.
 	if err := ip.Server.EnsureDataDirectoryExists(); err != nil {
-		return "", err
+		return "", -1, err
 	}
 
 	ip.Server.Log().WithField("install_script", ip.tempDir()+"/install.sh").Info("creating install container for server process")
@@ -459,12 +556,12 @@ func (ip *InstallationProcess) Execute() (string, error) {
 
 	r, err := ip.client.ContainerCreate(ctx, conf, hostConf, nil, nil, ip.Server.ID()+"_installer")
 	if err != nil {
-		return "", err
+		return "", -1, err
 	}
 
 	ip.Server.Log().WithField("container_id", r.ID).Info("running installation script for server in container")
 	if err := ip.client.ContainerStart(ctx, r.ID, types.ContainerStartOptions{}); err != nil {
-		return "", err
+		return "", -1, err
 	}
 
 	// Process the install event in the background by listening to the stream output until the
@@ -479,19 +576,35 @@ func (ip *InstallationProcess) Execute() (string, error) {
 		}
 	}(r.ID)
 
-	sChan, eChan := ip.client.ContainerWait(ctx, r.ID, container.WaitConditionNotRunning)
+	// Stop waiting on the container once the maximum run time is exceeded, the container
+	// is then killed when it is removed.
+	wctx := ctx
+	timeout := ip.timeout()
+	if timeout > 0 {
+		var wcancel context.CancelFunc
+		wctx, wcancel = context.WithTimeout(ctx, timeout)
+		defer wcancel()
+	}
+
+	sChan, eChan := ip.client.ContainerWait(wctx, r.ID, container.WaitConditionNotRunning)
 	select {
 	case err := <-eChan:
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			ip.Server.Log().WithField("timeout", timeout).Warn("installation process exceeded the maximum run time, terminating")
+			ip.Server.Events().Publish(DaemonMessageEvent, fmt.Sprintf("Installation process exceeded the maximum run time of %s and was terminated.", timeout))
+			return r.ID, -1, errors.WithStack(ErrInstallTimeout)
+		}
 		// Once the container has stopped running we can mark the install process as being completed.
 		if err == nil {
 			ip.Server.Events().Publish(DaemonMessageEvent, "Installation process completed.")
 		} else {
-			return "", err
+			return r.ID, -1, err
 		}
-	case <-sChan:
+	case res := <-sChan:
+		return r.ID, res.StatusCode, nil
 	}
 
-	return r.ID, nil
+	return r.ID, -1, nil
 }
 
 // StreamOutput streams the output of the installation process to a log file in
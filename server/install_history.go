@@ -0,0 +1,77 @@
+package server
+
+import (
+	"context"
+	"time"
+
+	"emperror.dev/errors"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/internal/database"
+	"github.com/pterodactyl/wings/internal/models"
+)
+
+// The maximum number of bytes of output stored for each installation run. Only the
+// end of the output is kept, since that is where the cause of a failure will be.
+const installLogSize = 512 * 1024
+
+// saveInstallRun stores an installation run in the local install history, and trims
+// the history back down to the install_history_size configuration value. If an error
+// is encountered it is logged but not returned to the caller.
+func (s *Server) saveInstallRun(run *models.Install) {
+	size := config.Get().System.InstallHistorySize
+	if size <= 0 {
+		return
+	}
+	if len(run.Log) > installLogSize {
+		run.Log = run.Log[len(run.Log)-installLogSize:]
+	}
+
+	// The server context may already be canceled if the server was deleted while the
+	// installer was running, in which case the history is purged anyway.
+	ctx, cancel := context.WithTimeout(s.Context(), time.Second*5)
+	defer cancel()
+	db := database.Instance().WithContext(ctx)
+	if tx := db.Create(run); tx.Error != nil {
+		s.Log().WithField("error", errors.WithStack(tx.Error)).Error("install: failed to save install history")
+		return
+	}
+	keep := db.Model(&models.Install{}).Select("id").Where("server = ?", s.ID()).Order("id DESC").Limit(size)
+	if tx := db.Where("server = ? AND id NOT IN (?)", s.ID(), keep).Delete(&models.Install{}); tx.Error != nil {
+		s.Log().WithField("error", errors.WithStack(tx.Error)).Error("install: failed to trim install history")
+	}
+}
+
+// InstallHistory returns the most recent installation runs for the server, ordered
+// from newest to oldest. The output of each run is not included, and must be
+// retrieved individually using InstallRun.
+func (s *Server) InstallHistory(ctx context.Context) ([]models.Install, error) {
+	var out []models.Install
+	tx := database.Instance().WithContext(ctx).Omit("log").Where("server = ?", s.ID()).Order("id DESC")
+	if err := tx.Find(&out).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return out, nil
+}
+
+// InstallRun returns a single installation run for the server, including its
+// output. If the run does not exist a nil value is returned.
+func (s *Server) InstallRun(ctx context.Context, id int) (*models.Install, error) {
+	var out []models.Install
+	tx := database.Instance().WithContext(ctx).Where("server = ? AND id = ?", s.ID(), id).Limit(1)
+	if err := tx.Find(&out).Error; err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return &out[0], nil
+}
+
+// PurgeInstallHistory removes all the stored installation runs for the server.
+func (s *Server) PurgeInstallHistory(ctx context.Context) error {
+	if tx := database.Instance().WithContext(ctx).Where("server = ?", s.ID()).Delete(&models.Install{}); tx.Error != nil {
+		return errors.WithStack(tx.Error)
+	}
+	return nil
+}
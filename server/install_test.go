@@ -0,0 +1,240 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/internal/database"
+	"github.com/pterodactyl/wings/internal/models"
+)
+
+func TestIsNetworkError(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("isNetworkError", func() {
+		g.It("matches wrapped network errors", func() {
+			err := errors.WithMessage(&net.DNSError{Err: "no such host", Name: "ghcr.io"}, "failed to pull image")
+			g.Assert(isNetworkError(err)).IsTrue()
+		})
+
+		g.It("matches network errors returned by the daemon", func() {
+			err := errors.New("Error response from daemon: Get \"https://ghcr.io/v2/\": net/http: TLS handshake timeout")
+			g.Assert(isNetworkError(err)).IsTrue()
+		})
+
+		g.It("does not match other errors", func() {
+			err := errors.New("Error response from daemon: manifest unknown")
+			g.Assert(isNetworkError(err)).IsFalse()
+		})
+	})
+}
+
+func setInstallConfig(dir string, historySize int) {
+	config.Set(&config.Configuration{
+		AuthenticationToken: "abc",
+		System: config.SystemConfiguration{
+			RootDirectory:      dir,
+			InstallHistorySize: historySize,
+		},
+		Docker: config.DockerConfiguration{
+			InstallerRetryExitCodes: []int64{6, 7},
+		},
+	})
+}
+
+func newInstallServer(g *G, id string) *Server {
+	s, err := New(nil)
+	g.Assert(err).IsNil()
+	s.Config().Uuid = id
+	return s
+}
+
+func TestExitCodeError(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("exitCodeError", func() {
+		g.BeforeEach(func() {
+			setInstallConfig(t.TempDir(), 10)
+		})
+
+		g.It("succeeds for an exit code of 0", func() {
+			retry, err := exitCodeError(0)
+			g.Assert(retry).IsFalse()
+			g.Assert(err).IsNil()
+		})
+
+		g.It("fails without retrying for other exit codes", func() {
+			retry, err := exitCodeError(1)
+			g.Assert(retry).IsFalse()
+			g.Assert(err == nil).IsFalse()
+		})
+
+		g.It("fails and retries for network error exit codes", func() {
+			retry, err := exitCodeError(7)
+			g.Assert(retry).IsTrue()
+			g.Assert(err == nil).IsFalse()
+		})
+	})
+}
+
+func TestInstallationProcess_Retry(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("InstallationProcess#retry", func() {
+		var ip *InstallationProcess
+		var attempts []int
+
+		g.BeforeEach(func() {
+			installRetryDelay = time.Millisecond
+			ip = &InstallationProcess{Server: newInstallServer(g, "d8ef0e3c-5b7a-4f0e-9b1c-2d3e4f5a6b7c")}
+			attempts = nil
+		})
+
+		g.AfterEach(func() {
+			installRetryDelay = time.Second * 10
+		})
+
+		run := func(results ...error) func(int) (bool, error) {
+			return func(attempt int) (bool, error) {
+				attempts = append(attempts, attempt)
+				err := results[attempt-1]
+				return err != nil && strings.Contains(err.Error(), "network"), err
+			}
+		}
+
+		g.It("stops after a successful attempt", func() {
+			err := ip.retry(3, run(nil))
+			g.Assert(err).IsNil()
+			g.Assert(attempts).Equal([]int{1})
+		})
+
+		g.It("does not retry errors that cannot be retried", func() {
+			err := ip.retry(3, run(errors.New("exited with code 1")))
+			g.Assert(err.Error()).Equal("exited with code 1")
+			g.Assert(attempts).Equal([]int{1})
+		})
+
+		g.It("does not retry when the egg has no retries", func() {
+			err := ip.retry(0, run(errors.New("network error")))
+			g.Assert(err.Error()).Equal("network error")
+			g.Assert(attempts).Equal([]int{1})
+		})
+
+		g.It("retries network errors until an attempt succeeds", func() {
+			err := ip.retry(3, run(errors.New("network error"), errors.New("network error"), nil))
+			g.Assert(err).IsNil()
+			g.Assert(attempts).Equal([]int{1, 2, 3})
+		})
+
+		g.It("returns the last error once the retries are used up", func() {
+			err := ip.retry(2, run(errors.New("network error 1"), errors.New("network error 2"), errors.New("network error 3")))
+			g.Assert(err.Error()).Equal("network error 3")
+			g.Assert(attempts).Equal([]int{1, 2, 3})
+		})
+
+		g.It("stops retrying once the server context is canceled", func() {
+			installRetryDelay = time.Hour
+			ip.Server.CtxCancel()
+			err := ip.retry(3, run(errors.New("network error"), nil))
+			g.Assert(err.Error()).Equal("network error")
+			g.Assert(attempts).Equal([]int{1})
+		})
+	})
+}
+
+var (
+	initInstallDatabase sync.Once
+	installServers      int
+)
+
+// newHistoryServer returns a server with an identifier that has not been used by
+// any other test, so that it starts with an empty install history.
+func newHistoryServer(g *G) *Server {
+	installServers++
+	return newInstallServer(g, fmt.Sprintf("00000000-0000-0000-0000-%012d", installServers))
+}
+
+func TestServer_SaveInstallRun(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Server#saveInstallRun", func() {
+		var s *Server
+
+		g.BeforeEach(func() {
+			initInstallDatabase.Do(func() {
+				// The database is shared by every test in the package and can only be
+				// initialized once, so it is not stored in a directory removed by a test.
+				dir, err := os.MkdirTemp("", "wings-install")
+				g.Assert(err).IsNil()
+				setInstallConfig(dir, 10)
+				g.Assert(database.Initialize()).IsNil()
+			})
+			s = newHistoryServer(g)
+		})
+
+		history := func() []models.Install {
+			h, err := s.InstallHistory(context.Background())
+			g.Assert(err).IsNil()
+			return h
+		}
+
+		g.It("stores the run", func() {
+			setInstallConfig(t.TempDir(), 10)
+			s.saveInstallRun(&models.Install{Server: s.ID(), Attempt: 1, Image: "alpine", ExitCode: 1, Log: "output"})
+
+			h := history()
+			g.Assert(len(h)).Equal(1)
+			g.Assert(h[0].Attempt).Equal(1)
+			g.Assert(h[0].ExitCode).Equal(int64(1))
+			g.Assert(h[0].Log).Equal("")
+
+			run, err := s.InstallRun(context.Background(), h[0].ID)
+			g.Assert(err).IsNil()
+			g.Assert(run.Log).Equal("output")
+		})
+
+		g.It("keeps only the most recent runs", func() {
+			setInstallConfig(t.TempDir(), 3)
+			other := newHistoryServer(g)
+			other.saveInstallRun(&models.Install{Server: other.ID(), Attempt: 1, Image: "alpine"})
+			for i := 1; i <= 5; i++ {
+				s.saveInstallRun(&models.Install{Server: s.ID(), Attempt: i, Image: "alpine"})
+			}
+
+			h := history()
+			g.Assert(len(h)).Equal(3)
+			g.Assert([]int{h[0].Attempt, h[1].Attempt, h[2].Attempt}).Equal([]int{5, 4, 3})
+
+			// The history of other servers is not trimmed.
+			oh, err := other.InstallHistory(context.Background())
+			g.Assert(err).IsNil()
+			g.Assert(len(oh)).Equal(1)
+		})
+
+		g.It("keeps only the end of large outputs", func() {
+			setInstallConfig(t.TempDir(), 10)
+			s.saveInstallRun(&models.Install{Server: s.ID(), Attempt: 1, Image: "alpine", Log: strings.Repeat("a", installLogSize) + "end"})
+
+			run, err := s.InstallRun(context.Background(), history()[0].ID)
+			g.Assert(err).IsNil()
+			g.Assert(len(run.Log)).Equal(installLogSize)
+			g.Assert(strings.HasSuffix(run.Log, "end")).IsTrue()
+		})
+
+		g.It("does not store runs when the history is disabled", func() {
+			setInstallConfig(t.TempDir(), 0)
+			s.saveInstallRun(&models.Install{Server: s.ID(), Attempt: 1, Image: "alpine"})
+			g.Assert(len(history())).Equal(0)
+		})
+	})
+}
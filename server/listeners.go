@@ -2,6 +2,7 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"regexp"
 	"strconv"
 	"sync"
@@ -79,17 +80,23 @@ func (s *Server) processConsoleOutputEvent(v []byte) {
 }
 
 // StartEventListeners adds all the internal event listeners we want to use for
-// a server. These listeners can only be removed by deleting the server as they
-// should last for the duration of the process' lifetime.
+// a server. These listeners last for the duration of the process' lifetime, and
+// are only removed when the server is deleted or the server is detached.
 func (s *Server) StartEventListeners() {
 	c := make(chan []byte, 8)
 	limit := newDiskLimiter(s)
 
+	ctx, cancel := context.WithCancel(s.Context())
+	s.listenersMu.Lock()
+	s.listenersCancel = cancel
+	s.listenersMu.Unlock()
+
 	s.Log().Debug("registering event listeners: console, state, resources...")
 	s.Environment.Events().On(c)
 	s.Environment.SetLogCallback(s.processConsoleOutputEvent)
 
 	go func() {
+		defer s.Environment.Events().Off(c)
 		for {
 			select {
 			case v := <-c:
@@ -135,13 +142,28 @@ func (s *Server) StartEventListeners() {
 					default:
 					}
 				}(v, limit)
-			case <-s.Context().Done():
+			case <-ctx.Done():
 				return
 			}
 		}
 	}()
 }
 
+// Detach stops the event listeners for the server and detaches from its
+// environment, leaving the server process running. Once detached, crash
+// detection and console output processing no longer take place in this
+// process. This is used when a new Wings process has taken over managing the
+// server after a graceful restart.
+func (s *Server) Detach() {
+	s.listenersMu.Lock()
+	if s.listenersCancel != nil {
+		s.listenersCancel()
+	}
+	s.listenersMu.Unlock()
+	s.Environment.SetLogCallback(func([]byte) {})
+	s.Environment.Detach()
+}
+
 var stripAnsiRegex = regexp.MustCompile("[\u001B\u009B][[\\]()#;?]*(?:(?:(?:[a-zA-Z\\d]*(?:;[a-zA-Z\\d]*)*)?\u0007)|(?:(?:\\d{1,4}(?:;\\d{0,4})*)?[\\dA-PRZcf-ntqry=><~]))")
 
 // Custom listener for console output events that will check if the given line
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"emperror.dev/errors"
@@ -23,9 +24,10 @@ import (
 )
 
 type Manager struct {
-	mu      sync.RWMutex
-	client  remote.Client
-	servers []*Server
+	mu       sync.RWMutex
+	client   remote.Client
+	servers  []*Server
+	degraded atomic.Bool
 }
 
 // NewManager returns a new server manager instance. This will boot up all the
@@ -160,6 +162,95 @@ func (m *Manager) PersistStates() error {
 	return nil
 }
 
+// RuntimeState is the in-memory state of a server that is handed over to the new
+// process when Wings is restarted gracefully, so that crash detection and console
+// throttling continue where they left off.
+type RuntimeState struct {
+	LastCrash     time.Time `json:"last_crash"`
+	ThrottleCount uint64    `json:"throttle_count"`
+	ThrottleStart time.Time `json:"throttle_start"`
+}
+
+// PersistRuntimeState writes the in-memory state of each server to the disk so
+// that it can be restored by the new process during a graceful restart.
+func (m *Manager) PersistRuntimeState() error {
+	states := map[string]RuntimeState{}
+	for _, s := range m.All() {
+		count, start := s.Throttler().limit.State()
+		states[s.ID()] = RuntimeState{
+			LastCrash:     s.crasher.LastCrashTime(),
+			ThrottleCount: count,
+			ThrottleStart: start,
+		}
+	}
+	data, err := json.Marshal(states)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := os.WriteFile(config.Get().System.GetRuntimeStatePath(), data, 0o600); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// RestoreRuntimeState restores the in-memory state of each server written by the
+// previous process during a graceful restart, and then removes the file so that it
+// is not restored again when Wings is next started.
+func (m *Manager) RestoreRuntimeState() error {
+	p := config.Get().System.GetRuntimeStatePath()
+	data, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.WithStack(err)
+	}
+	defer os.Remove(p)
+
+	var states map[string]RuntimeState
+	if err := json.Unmarshal(data, &states); err != nil {
+		return errors.WithStack(err)
+	}
+	for id, st := range states {
+		s, ok := m.Get(id)
+		if !ok {
+			continue
+		}
+		s.crasher.SetLastCrash(st.LastCrash)
+		s.Throttler().limit.Restore(st.ThrottleCount, st.ThrottleStart)
+	}
+	return nil
+}
+
+// Busy returns the IDs of the servers currently running a task that cannot be
+// handed over to another process, such as an installation, backup, restoration or
+// transfer.
+func (m *Manager) Busy() []string {
+	var ids []string
+	for _, s := range m.All() {
+		if s.IsInstalling() || s.IsBackingUp() || s.IsRestoring() || s.IsTransferring() {
+			ids = append(ids, s.ID())
+		}
+	}
+	return ids
+}
+
+// Detach detaches every server from its environment, see Server.Detach.
+func (m *Manager) Detach() {
+	for _, s := range m.All() {
+		s.Detach()
+	}
+}
+
+// ConfigureThrottles applies the console throttle configuration to every server
+// on the node, for use after the configuration has been reloaded.
+func (m *Manager) ConfigureThrottles() {
+	throttles := config.Get().Throttles
+	for _, s := range m.All() {
+		s.Throttler().configure(throttles)
+	}
+}
+
 // ReadStates returns the state of the servers.
 func (m *Manager) ReadStates() (map[string]string, error) {
 	f, err := os.OpenFile(config.Get().System.GetStatesPath(), os.O_RDONLY|os.O_CREATE, 0o644)
@@ -209,11 +300,16 @@ func (m *Manager) InitServer(data remote.ServerConfigurationResponse) (*Server,
 		Allocations: s.cfg.Allocations,
 		Limits:      s.cfg.Build,
 		Labels:      s.cfg.Labels,
+		Ulimits:     s.Ulimits(),
+		Devices:     s.Devices(),
 	}
 
 	envCfg := environment.NewConfiguration(settings, s.GetEnvironmentVariables())
 	meta := docker.Metadata{
-		Image: s.Config().Container.Image,
+		Image:           s.Config().Container.Image,
+		RegistryAuth:    s.Config().RegistryCredentials(),
+		SecurityProfile: s.Config().SecurityProfile(),
+		Network:         docker.NetworkSettings{Owner: s.Config().OwnerUuid, Links: s.Config().Networks},
 	}
 
 	if env, err := docker.New(s.ID(), &meta, envCfg); err != nil {
@@ -237,10 +333,25 @@ func (m *Manager) init(ctx context.Context) error {
 	log.Info("fetching list of servers from API")
 	servers, err := m.client.GetServers(ctx, config.Get().RemoteQuery.BootServersPerPage)
 	if err != nil {
-		if !remote.IsRequestError(err) {
-			return errors.WithStackIf(err)
+		// If the Panel cannot be reached, fall back to the server configurations
+		// from the last successful boot so that running servers are still managed.
+		// The cache is reconciled with the Panel once it is reachable again.
+		if isPanelUnreachable(err) {
+			if cached, cerr := readServersCache(); cerr == nil {
+				log.WithField("error", err).Warn("failed to retrieve server configurations from API, booting from locally cached configurations")
+				servers = cached
+				m.degraded.Store(true)
+				go m.reconcile(ctx)
+			}
+		}
+		if servers == nil {
+			if !remote.IsRequestError(err) {
+				return errors.WithStackIf(err)
+			}
+			return errors.WrapIf(err, "manager: failed to retrieve server configurations")
 		}
-		return errors.WrapIf(err, "manager: failed to retrieve server configurations")
+	} else if err := writeServersCache(servers); err != nil {
+		log.WithField("error", err).Warn("failed to write server configurations cache to disk")
 	}
 
 	start := time.Now()
@@ -254,11 +365,9 @@ func (m *Manager) init(ctx context.Context) error {
 			// Parse the json.RawMessage into an expected struct value. We do this here so that a single broken
 			// server does not cause the entire boot process to hang, and allows us to show more useful error
 			// messaging in the output.
-			d := remote.ServerConfigurationResponse{
-				Settings: data.Settings,
-			}
 			log.WithField("server", data.Uuid).Info("creating new server object from API response")
-			if err := json.Unmarshal(data.ProcessConfiguration, &d.ProcessConfiguration); err != nil {
+			d, err := parseRawServerData(data)
+			if err != nil {
 				log.WithField("server", data.Uuid).WithField("error", err).Error("failed to parse server configuration from API response, skipping...")
 				return
 			}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/apex/log"
+	"github.com/goccy/go-json"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/remote"
+)
+
+// The amount of time between attempts to reach the Panel when the manager was
+// booted from the local server cache.
+var reconcileInterval = time.Second * 30
+
+// Degraded returns true if the manager was booted from the locally cached server
+// configurations because the Panel could not be reached, and has not yet been
+// able to reconcile with the Panel.
+func (m *Manager) Degraded() bool {
+	return m.degraded.Load()
+}
+
+// writeServersCache stores the server configurations returned by the Panel on
+// the disk so that they can be used to boot if the Panel is unreachable. The
+// file contains environment variables for every server, so it is only readable
+// by the owner.
+func writeServersCache(servers []remote.RawServerData) error {
+	b, err := json.Marshal(servers)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	p := config.Get().System.GetServersCachePath()
+	tmp := p + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o600); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp, p))
+}
+
+// readServersCache returns the server configurations stored on the disk the
+// last time they were retrieved from the Panel.
+func readServersCache() ([]remote.RawServerData, error) {
+	b, err := os.ReadFile(config.Get().System.GetServersCachePath())
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var servers []remote.RawServerData
+	if err := json.Unmarshal(b, &servers); err != nil {
+		return nil, errors.Wrap(err, "manager: failed to parse server cache")
+	}
+	return servers, nil
+}
+
+// isPanelUnreachable returns true if the error returned when fetching servers
+// indicates the Panel could not be reached or is failing, rather than the Panel
+// rejecting the request outright.
+func isPanelUnreachable(err error) bool {
+	if rerr := remote.AsRequestError(err); rerr != nil {
+		return rerr.StatusCode() >= 500
+	}
+	return !errors.Is(err, context.Canceled)
+}
+
+// parseRawServerData parses the raw server data returned by the Panel into the
+// configuration used to initialize a server.
+func parseRawServerData(data remote.RawServerData) (remote.ServerConfigurationResponse, error) {
+	d := remote.ServerConfigurationResponse{
+		Settings: data.Settings,
+	}
+	if err := json.Unmarshal(data.ProcessConfiguration, &d.ProcessConfiguration); err != nil {
+		return d, errors.WithStack(err)
+	}
+	return d, nil
+}
+
+// reconcile waits until the Panel is reachable and then brings the servers
+// booted from the local cache in line with the configurations returned by the
+// Panel. Servers that were missing from the cache are booted once added. Servers
+// that the Panel no longer returns are left untouched, other than logging a
+// warning, since removing them would risk destroying data based on a partial
+// response.
+func (m *Manager) reconcile(ctx context.Context) {
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		servers, err := m.client.GetServers(ctx, config.Get().RemoteQuery.BootServersPerPage)
+		if err != nil {
+			log.WithField("error", err).Debug("manager: panel is still unreachable, continuing with cached server configurations")
+			continue
+		}
+		if err := writeServersCache(servers); err != nil {
+			log.WithField("error", err).Warn("manager: failed to write server configurations cache to disk")
+		}
+
+		states, err := m.ReadStates()
+		if err != nil {
+			log.WithField("error", err).Warn("manager: failed to retrieve locally cached server states from disk, assuming new servers are offline")
+		}
+
+		seen := make(map[string]bool, len(servers))
+		for _, data := range servers {
+			seen[data.Uuid] = true
+			d, err := parseRawServerData(data)
+			if err != nil {
+				log.WithField("server", data.Uuid).WithField("error", err).Error("manager: failed to parse server configuration from API response, skipping...")
+				continue
+			}
+			if s, ok := m.Get(data.Uuid); ok {
+				if err := s.SyncWithConfiguration(d); err != nil {
+					s.Log().WithField("error", err).Error("manager: failed to sync server configuration with panel")
+				}
+				continue
+			}
+			s, err := m.InitServer(d)
+			if err != nil {
+				log.WithField("server", data.Uuid).WithField("error", err).Error("manager: failed to load server, skipping...")
+				continue
+			}
+			if err := s.EnsureDataDirectoryExists(); err != nil {
+				s.Log().WithField("error", err).Error("manager: could not create root data directory for server")
+				continue
+			}
+			m.Add(s)
+			// Run the same boot steps as servers that were loaded when Wings booted, so
+			// that a server left running is re-attached to.
+			s.Boot(ctx, states[s.ID()])
+		}
+		for _, s := range m.All() {
+			if !seen[s.ID()] {
+				s.Log().Warn("manager: server was loaded from the local cache but is no longer returned by the panel")
+			}
+		}
+
+		m.degraded.Store(false)
+		log.WithField("total_configs", len(servers)).Info("manager: panel is reachable again, reconciled cached server configurations")
+
+		// This would have failed during boot since the Panel was unreachable.
+		if err := m.client.ResetServersState(ctx); err != nil {
+			log.WithField("error", err).Error("manager: failed to reset server states on Panel: some instances may be stuck in an installing/restoring state unexpectedly")
+		}
+		return
+	}
+}
@@ -0,0 +1,265 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"emperror.dev/errors"
+	. "github.com/franela/goblin"
+	"github.com/goccy/go-json"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/remote"
+)
+
+// testPanel is a Panel client that returns the configured servers, or an error
+// while the Panel is unreachable.
+type testPanel struct {
+	remote.Client
+
+	mu      sync.Mutex
+	servers []remote.RawServerData
+	err     error
+	resets  int
+}
+
+func (p *testPanel) GetServers(_ context.Context, _ int) ([]remote.RawServerData, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return nil, p.err
+	}
+	return p.servers, nil
+}
+
+func (p *testPanel) ResetServersState(_ context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.resets++
+	return nil
+}
+
+func (p *testPanel) set(servers []remote.RawServerData, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.servers = servers
+	p.err = err
+}
+
+func (p *testPanel) resetCount() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.resets
+}
+
+// rawServer returns the raw data for a server as returned by the Panel.
+func rawServer(id string, name string) remote.RawServerData {
+	return remote.RawServerData{
+		Uuid:                 id,
+		Settings:             json.RawMessage(`{"uuid":"` + id + `","meta":{"name":"` + name + `"}}`),
+		ProcessConfiguration: json.RawMessage(`{}`),
+	}
+}
+
+// requestError returns the error returned by the Panel client for a response
+// with the given status code.
+func requestError(status int) error {
+	r := &remote.Response{Response: &http.Response{
+		StatusCode: status,
+		Body:       io.NopCloser(strings.NewReader("")),
+	}}
+	return r.Error()
+}
+
+func TestManagerCache(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Manager cache", func() {
+		var dir string
+		g.BeforeEach(func() {
+			var err error
+			dir, err = os.MkdirTemp(os.TempDir(), "pterodactyl")
+			g.Assert(err).IsNil()
+			config.Set(&config.Configuration{
+				AuthenticationToken: "abc",
+				System: config.SystemConfiguration{
+					RootDirectory: dir,
+					Data:          dir + "/volumes",
+				},
+			})
+		})
+		g.AfterEach(func() {
+			_ = os.RemoveAll(dir)
+		})
+
+		g.Describe("Server configurations cache", func() {
+			g.It("reads the configurations that were written", func() {
+				servers := []remote.RawServerData{rawServer("server-a", "a"), rawServer("server-b", "b")}
+				g.Assert(writeServersCache(servers)).IsNil()
+
+				st, err := os.Stat(config.Get().System.GetServersCachePath())
+				g.Assert(err).IsNil()
+				g.Assert(st.Mode().Perm()).Equal(os.FileMode(0o600))
+
+				out, err := readServersCache()
+				g.Assert(err).IsNil()
+				g.Assert(len(out)).Equal(2)
+				for i, s := range out {
+					g.Assert(s.Uuid).Equal(servers[i].Uuid)
+					g.Assert(string(s.Settings)).Equal(string(servers[i].Settings))
+					g.Assert(string(s.ProcessConfiguration)).Equal(string(servers[i].ProcessConfiguration))
+				}
+			})
+
+			g.It("returns an error if there is no cache", func() {
+				_, err := readServersCache()
+				g.Assert(errors.Is(err, os.ErrNotExist)).IsTrue()
+			})
+
+			g.It("returns an error if the cache is invalid", func() {
+				g.Assert(os.WriteFile(config.Get().System.GetServersCachePath(), []byte("{"), 0o600)).IsNil()
+				_, err := readServersCache()
+				g.Assert(err == nil).IsFalse()
+			})
+		})
+
+		g.Describe("isPanelUnreachable", func() {
+			g.It("returns true if the Panel could not be reached", func() {
+				g.Assert(isPanelUnreachable(errors.New("dial tcp: connection refused"))).IsTrue()
+				g.Assert(isPanelUnreachable(context.DeadlineExceeded)).IsTrue()
+			})
+
+			g.It("returns true if the Panel is failing", func() {
+				g.Assert(isPanelUnreachable(requestError(http.StatusInternalServerError))).IsTrue()
+				g.Assert(isPanelUnreachable(requestError(http.StatusBadGateway))).IsTrue()
+			})
+
+			g.It("returns false if the Panel rejected the request", func() {
+				g.Assert(isPanelUnreachable(requestError(http.StatusForbidden))).IsFalse()
+				g.Assert(isPanelUnreachable(requestError(http.StatusNotFound))).IsFalse()
+			})
+
+			g.It("returns false if the request was canceled", func() {
+				g.Assert(isPanelUnreachable(context.Canceled)).IsFalse()
+				g.Assert(isPanelUnreachable(errors.WithStack(context.Canceled))).IsFalse()
+			})
+		})
+
+		g.Describe("NewManager", func() {
+			g.It("writes the configurations returned by the Panel to the cache", func() {
+				p := &testPanel{servers: []remote.RawServerData{rawServer("server-a", "a")}}
+				m, err := NewManager(context.Background(), p)
+				g.Assert(err).IsNil()
+				g.Assert(m.Len()).Equal(1)
+				g.Assert(m.Degraded()).IsFalse()
+
+				out, err := readServersCache()
+				g.Assert(err).IsNil()
+				g.Assert(len(out)).Equal(1)
+				g.Assert(out[0].Uuid).Equal("server-a")
+			})
+
+			g.It("boots from the cache if the Panel is unreachable", func() {
+				g.Assert(writeServersCache([]remote.RawServerData{rawServer("server-a", "a"), rawServer("server-b", "b")})).IsNil()
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				p := &testPanel{err: errors.New("dial tcp: connection refused")}
+				m, err := NewManager(ctx, p)
+				g.Assert(err).IsNil()
+				g.Assert(m.Len()).Equal(2)
+				g.Assert(m.Degraded()).IsTrue()
+			})
+
+			g.It("does not boot from the cache if the Panel rejects the request", func() {
+				g.Assert(writeServersCache([]remote.RawServerData{rawServer("server-a", "a")})).IsNil()
+
+				p := &testPanel{err: requestError(http.StatusForbidden)}
+				_, err := NewManager(context.Background(), p)
+				g.Assert(err == nil).IsFalse()
+			})
+
+			g.It("returns an error if the Panel is unreachable and there is no cache", func() {
+				p := &testPanel{err: errors.New("dial tcp: connection refused")}
+				_, err := NewManager(context.Background(), p)
+				g.Assert(err == nil).IsFalse()
+			})
+		})
+
+		g.Describe("Manager#reconcile", func() {
+			var interval time.Duration
+			g.BeforeEach(func() {
+				interval = reconcileInterval
+				reconcileInterval = time.Millisecond * 10
+			})
+			g.AfterEach(func() {
+				reconcileInterval = interval
+			})
+
+			g.It("reconciles the cached servers once the Panel is reachable", func() {
+				g.Assert(writeServersCache([]remote.RawServerData{rawServer("server-a", "a"), rawServer("server-b", "b")})).IsNil()
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				p := &testPanel{err: errors.New("dial tcp: connection refused")}
+				m, err := NewManager(ctx, p)
+				g.Assert(err).IsNil()
+				g.Assert(m.Degraded()).IsTrue()
+
+				// The manager keeps using the cache while the Panel is unreachable.
+				time.Sleep(time.Millisecond * 50)
+				g.Assert(m.Degraded()).IsTrue()
+				g.Assert(p.resetCount()).Equal(0)
+
+				// Server B is no longer returned by the Panel, but is left untouched.
+				p.set([]remote.RawServerData{rawServer("server-a", "renamed"), rawServer("server-c", "c")}, nil)
+				for i := 0; i < 100 && m.Degraded(); i++ {
+					time.Sleep(time.Millisecond * 10)
+				}
+				g.Assert(m.Degraded()).IsFalse()
+				g.Assert(p.resetCount()).Equal(1)
+
+				g.Assert(m.Len()).Equal(3)
+				s, ok := m.Get("server-a")
+				g.Assert(ok).IsTrue()
+				g.Assert(s.Config().Meta.Name).Equal("renamed")
+				_, ok = m.Get("server-b")
+				g.Assert(ok).IsTrue()
+				_, ok = m.Get("server-c")
+				g.Assert(ok).IsTrue()
+
+				out, err := readServersCache()
+				g.Assert(err).IsNil()
+				g.Assert(len(out)).Equal(2)
+				g.Assert(out[1].Uuid).Equal("server-c")
+			})
+
+			g.It("stops trying to reach the Panel once the context is canceled", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+				p := &testPanel{err: errors.New("dial tcp: connection refused")}
+				m := NewEmptyManager(p)
+				m.degraded.Store(true)
+
+				done := make(chan struct{})
+				go func() {
+					m.reconcile(ctx)
+					close(done)
+				}()
+				cancel()
+
+				select {
+				case <-done:
+				case <-time.After(time.Second):
+					g.Fail("reconcile did not stop")
+				}
+				g.Assert(m.Degraded()).IsTrue()
+			})
+		})
+	})
+}
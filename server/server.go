@@ -7,10 +7,13 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
 	"github.com/creasty/defaults"
+	"github.com/docker/docker/client"
 	"github.com/goccy/go-json"
 
 	"github.com/pterodactyl/wings/config"
@@ -33,6 +36,10 @@ type Server struct {
 	emitterLock sync.Mutex
 	powerLock   *system.Locker
 
+	// Stops the listeners for events emitted by the server's environment.
+	listenersMu     sync.Mutex
+	listenersCancel context.CancelFunc
+
 	// Maintains the configuration for the server. This is the data that gets returned by the Panel
 	// such as build settings and container images.
 	cfg    Configuration
@@ -62,6 +69,9 @@ type Server struct {
 	transferring *system.AtomicBool
 	restoring    *system.AtomicBool
 
+	// The number of backups of the server currently being generated.
+	backups atomic.Int32
+
 	// The console throttler instance used to control outputs.
 	throttler    *ConsoleThrottle
 	throttleOnce sync.Once
@@ -151,7 +161,7 @@ func (s *Server) GetEnvironmentVariables() []string {
 		fmt.Sprintf("TZ=%s", config.Get().System.Timezone),
 		fmt.Sprintf("STARTUP=%s", s.Config().Invocation),
 		fmt.Sprintf("SERVER_MEMORY=%d", s.MemoryLimit()),
-		fmt.Sprintf("SERVER_IP=%s", s.Config().Allocations.DefaultMapping.Ip),
+		fmt.Sprintf("SERVER_IP=%s", s.Config().Allocations.DefaultIP()),
 		fmt.Sprintf("SERVER_PORT=%d", s.Config().Allocations.DefaultMapping.Port),
 	}
 
@@ -263,6 +273,11 @@ func (s *Server) ProcessConfiguration() *remote.ProcessConfiguration {
 	return s.procConfig
 }
 
+// Client returns the client used to make requests to the Panel for this server.
+func (s *Server) Client() remote.Client {
+	return s.client
+}
+
 // Filesystem returns an instance of the filesystem for this server.
 func (s *Server) Filesystem() *filesystem.Filesystem {
 	return s.fs
@@ -287,6 +302,69 @@ func (s *Server) EnsureDataDirectoryExists() error {
 	return nil
 }
 
+// Boot configures the environment of the server while Wings is booting and
+// returns the server to the state it was last known to be in, starting it if it
+// was previously running or re-attaching to it if it is still running.
+func (s *Server) Boot(ctx context.Context, st string) {
+	s.Log().Info("configuring server environment and restoring to previous state")
+
+	// Use a timed context here to avoid booting issues where Docker hangs for a
+	// specific container that would cause Wings to be un-bootable until the entire
+	// machine is rebooted. It is much better for us to just have a single failed
+	// server instance than an entire offline node.
+	//
+	// @see https://github.com/pterodactyl/panel/issues/2475
+	// @see https://github.com/pterodactyl/panel/issues/3358
+	ctx, cancel := context.WithTimeout(ctx, time.Second*30)
+	defer cancel()
+
+	r, err := s.Environment.IsRunning(ctx)
+	// We ignore missing containers because we don't want to actually block booting of wings at this
+	// point. If we didn't do this, and you pruned all the images and then started wings you could
+	// end up waiting a long period of time for all the images to be re-pulled on Wings boot rather
+	// than when the server itself is started.
+	if err != nil && !client.IsErrNotFound(err) {
+		s.Log().WithField("error", err).Error("error checking server environment status")
+	}
+
+	// Check if the server was previously running. If so, attempt to start the server now so that Wings
+	// can pick up where it left off. If the environment does not exist at all, just create it and then allow
+	// the normal flow to execute.
+	//
+	// This does mean that booting wings after a catastrophic machine crash and wiping out the Docker images
+	// as a result will result in a slow boot.
+	if !r && (st == environment.ProcessRunningState || st == environment.ProcessStartingState) {
+		if err := s.HandlePowerAction(PowerActionStart); err != nil {
+			s.Log().WithField("error", err).Warn("failed to return server to running state")
+		}
+	} else if r || (!r && s.IsRunning()) {
+		// If the server is currently running on Docker, mark the process as being in that state.
+		// We never want to stop an instance that is currently running external from Wings since
+		// that is a good way of keeping things running even if Wings gets in a very corrupted state.
+		//
+		// This will also validate that a server process is running if the last tracked state we have
+		// is that it was running, but we see that the container process is not currently running.
+		s.Log().Info("detected server is running, re-attaching to process...")
+
+		s.Environment.SetState(environment.ProcessRunningState)
+		if err := s.Environment.Attach(ctx); err != nil {
+			s.Log().WithField("error", err).Warn("failed to attach to running server environment")
+		}
+	} else {
+		// At this point we've determined that the server should indeed be in an offline state, so we'll
+		// make a call to set that state just to ensure we don't ever accidentally end up with some invalid
+		// state being tracked.
+		s.Environment.SetState(environment.ProcessOfflineState)
+	}
+
+	if state := s.Environment.State(); state == environment.ProcessStartingState || state == environment.ProcessRunningState {
+		s.Log().Debug("re-syncing server configuration for already running server")
+		if err := s.Sync(); err != nil {
+			s.Log().WithError(err).Error("failed to re-sync server configuration")
+		}
+	}
+}
+
 // OnStateChange sets the state of the server internally. This function handles crash detection as
 // well as reporting to event listeners for the server.
 func (s *Server) OnStateChange() {
@@ -355,10 +433,36 @@ type APIResponse struct {
 // ToAPIResponse returns the server struct as an API object that can be consumed
 // by callers.
 func (s *Server) ToAPIResponse() APIResponse {
+	return s.toAPIResponse(false)
+}
+
+// ToRedactedAPIResponse returns the server struct as an API object without the
+// environment variables of the server, which commonly contain passwords and other
+// secrets. This is returned to callers that are not allowed to read the full
+// configuration of the server.
+func (s *Server) ToRedactedAPIResponse() APIResponse {
+	return s.toAPIResponse(true)
+}
+
+func (s *Server) toAPIResponse(redact bool) APIResponse {
 	return APIResponse{
 		State:         s.Environment.State(),
 		IsSuspended:   s.IsSuspended(),
 		Utilization:   s.Proc(),
-		Configuration: *s.Config(),
+		Configuration: *s.apiConfiguration(redact),
+	}
+}
+
+// apiConfiguration returns a copy of the server configuration that is safe to
+// return from the API. Registry credentials are only ever sent by the Panel and
+// are never returned, and environment variables are removed if redacting.
+func (s *Server) apiConfiguration(redact bool) *Configuration {
+	//goland:noinspection GoVetCopyLock
+	c := *s.Config()
+	c.Egg.RegistryAuth = nil
+	c.Container.RegistryAuth = nil
+	if redact {
+		c.EnvVars = nil
 	}
+	return &c
 }
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/franela/goblin"
+	"github.com/goccy/go-json"
+
+	"github.com/pterodactyl/wings/remote"
+)
+
+func TestServer_apiConfiguration(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Server#apiConfiguration", func() {
+		g.It("does not include registry credentials", func() {
+			s := &Server{}
+			s.cfg.Egg.RegistryAuth = &remote.RegistryCredentials{Username: "egg-user", Password: "egg-secret"}
+			s.cfg.Container.RegistryAuth = &remote.RegistryCredentials{Username: "user", Password: "secret", RegistryToken: "token"}
+
+			b, err := json.Marshal(s.apiConfiguration(false))
+			g.Assert(err).IsNil()
+			for _, v := range []string{"registry_auth", "egg-user", "egg-secret", "secret", "token"} {
+				g.Assert(strings.Contains(string(b), v)).IsFalse(v)
+			}
+
+			// The configuration of the server itself must not be modified.
+			g.Assert(s.cfg.Container.RegistryAuth.Password).Equal("secret")
+		})
+
+		g.It("only removes environment variables when redacting", func() {
+			s := &Server{}
+			s.cfg.EnvVars = map[string]interface{}{"RCON_PASSWORD": "secret"}
+
+			g.Assert(s.apiConfiguration(false).EnvVars["RCON_PASSWORD"]).Equal("secret")
+			g.Assert(s.apiConfiguration(true).EnvVars).IsNil()
+			g.Assert(s.cfg.EnvVars["RCON_PASSWORD"]).Equal("secret")
+		})
+	})
+}
@@ -0,0 +1,248 @@
+package transfer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	"emperror.dev/errors"
+	"github.com/google/uuid"
+
+	"github.com/pterodactyl/wings/server/backup"
+)
+
+// The prefixes of the multipart form names used when sending local backups to
+// the target node along with the server archive. The UUIDs of the backups are
+// declared first in a part named "backups", then each backup is sent as a part
+// named "backup:<uuid>", followed by a part named "backup_checksum:<uuid>"
+// containing the hex encoded SHA256 checksum of the backup.
+const (
+	BackupsPart              = "backups"
+	BackupPartPrefix         = "backup:"
+	BackupChecksumPartPrefix = "backup_checksum:"
+)
+
+const (
+	ErrInvalidBackup = errors.Sentinel("transfer: invalid backup identifier")
+	ErrBackupExists  = errors.Sentinel("transfer: backup already exists on this node")
+)
+
+// localBackups returns the local backups that should be sent to the target node,
+// logging any that do not exist on this node.
+func (t *Transfer) localBackups() []*backup.LocalBackup {
+	var out []*backup.LocalBackup
+	for _, id := range t.Backups {
+		b, _, err := backup.LocateLocal(t.Server.Client(), id)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				t.Log().WithField("backup", id).Warn("local backup for transfer does not exist on this node, skipping")
+			} else {
+				t.Log().WithField("backup", id).WithError(err).Warn("failed to locate local backup for transfer, skipping")
+			}
+			t.SendMessage("Local backup " + id + " could not be found on this node and will not be transferred.")
+			continue
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+// backupIdentifiers returns the UUIDs of the backups.
+func backupIdentifiers(backups []*backup.LocalBackup) []string {
+	out := make([]string, 0, len(backups))
+	for _, b := range backups {
+		out = append(out, b.Identifier())
+	}
+	return out
+}
+
+// removeSentBackups removes the local backups the target node has confirmed it
+// received, since they now belong to the target node. The target only responds
+// with these once the Panel has accepted the transfer. Backups that were not sent
+// for this transfer are never removed.
+func (t *Transfer) removeSentBackups(ids []string) {
+	for _, id := range ids {
+		if !slices.Contains(t.Backups, id) {
+			continue
+		}
+		b, _, err := backup.LocateLocal(t.Server.Client(), id)
+		if err == nil {
+			err = b.Remove()
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			t.Log().WithField("backup", id).WithError(err).Warn("failed to remove local backup after transfer")
+		}
+	}
+}
+
+// DeclareBackups sets the UUIDs of the backups the source node will send for an
+// incoming transfer. Only these backups are accepted by ReceiveBackup.
+func (t *Transfer) DeclareBackups(ids []string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.declared = make(map[string]bool, len(ids))
+	for _, id := range ids {
+		t.declared[id] = true
+	}
+}
+
+// sendBackup copies a local backup to the provided writer and returns the hex
+// encoded SHA256 checksum of the data that was sent.
+func (t *Transfer) sendBackup(b *backup.LocalBackup, w io.Writer) (string, error) {
+	f, err := os.Open(b.Path())
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, h), t.limitReader(f)); err != nil {
+		return "", errors.Wrap(err, "transfer: failed to send backup")
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// putBackup sends a local backup to the target node during an incremental
+// transfer.
+func (c *incrementalClient) putBackup(ctx context.Context, t *Transfer, b *backup.LocalBackup) error {
+	f, err := os.Open(b.Path())
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	q := url.Values{"backup": {b.Identifier()}, "checksum": {hex.EncodeToString(h.Sum(nil))}}
+	res, err := c.request(ctx, http.MethodPut, "/backups", q, t.limitReader(f))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusNoContent {
+		return responseError(res)
+	}
+	return nil
+}
+
+// AddReceivedBackup tracks a backup that has been received from the source node
+// so that it can be removed if the transfer fails.
+func (t *Transfer) AddReceivedBackup(ib *IncomingBackup) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.received = append(t.received, ib)
+}
+
+// ReceivedBackups returns the UUIDs of the backups received from the source node.
+func (t *Transfer) ReceivedBackups() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	out := make([]string, 0, len(t.received))
+	for _, ib := range t.received {
+		out = append(out, ib.Identifier())
+	}
+	return out
+}
+
+// DiscardReceivedBackups removes every backup that has been received from the
+// source node.
+func (t *Transfer) DiscardReceivedBackups() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, ib := range t.received {
+		ib.Discard()
+	}
+	t.received = nil
+}
+
+// IncomingBackup is a backup that is being received from the source node. The
+// backup is written next to its final location and only moved into place once
+// its checksum has been verified.
+type IncomingBackup struct {
+	b        *backup.LocalBackup
+	h        hash.Hash
+	path     string
+	verified bool
+}
+
+// ReceiveBackup writes a backup received from the source node to the backup
+// directory. Verify must be called once the checksum has been received to move
+// the backup into place. Only backups declared by the source node are accepted,
+// and an existing backup is never replaced.
+func (t *Transfer) ReceiveBackup(id string, r io.Reader) (*IncomingBackup, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, errors.WithStack(ErrInvalidBackup)
+	}
+	t.mu.Lock()
+	declared := t.declared[id]
+	t.mu.Unlock()
+	if !declared {
+		return nil, errors.Wrap(ErrInvalidBackup, "transfer: backup was not declared by the source node")
+	}
+
+	ib := &IncomingBackup{b: backup.NewLocal(nil, id, ""), h: sha256.New()}
+	if _, err := os.Lstat(ib.b.Path()); err == nil {
+		return nil, errors.WithStack(ErrBackupExists)
+	}
+	ib.path = ib.b.Path() + PartialSuffix
+	f, err := os.OpenFile(ib.path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if _, err := io.Copy(f, io.TeeReader(t.LimitDownload(r), ib.h)); err != nil {
+		ib.Discard()
+		return nil, errors.Wrap(err, "transfer: failed to write backup")
+	}
+	return ib, nil
+}
+
+// Identifier returns the UUID of the backup.
+func (ib *IncomingBackup) Identifier() string {
+	return ib.b.Identifier()
+}
+
+// Verify compares the checksum of the received backup against the one sent by
+// the source node and moves the backup into place if they match. If they do not
+// match the backup is removed and ErrChecksumMismatch is returned.
+func (ib *IncomingBackup) Verify(checksum string) error {
+	if !strings.EqualFold(strings.TrimSpace(checksum), hex.EncodeToString(ib.h.Sum(nil))) {
+		ib.Discard()
+		return errors.WithStack(ErrChecksumMismatch)
+	}
+	// Link rather than rename the backup into place so that a backup created at the
+	// same path in the meantime is never replaced.
+	if err := os.Link(ib.path, ib.b.Path()); err != nil {
+		ib.Discard()
+		if os.IsExist(err) {
+			return errors.WithStack(ErrBackupExists)
+		}
+		return err
+	}
+	_ = os.Remove(ib.path)
+	ib.verified = true
+	return nil
+}
+
+// Discard removes the backup, regardless of whether it has been verified.
+func (ib *IncomingBackup) Discard() {
+	if ib.verified {
+		_ = os.Remove(ib.b.Path())
+		return
+	}
+	_ = os.Remove(ib.path)
+}
@@ -0,0 +1,206 @@
+package transfer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+
+	"github.com/pterodactyl/wings/internal/ufs"
+)
+
+var (
+	ErrOffsetMismatch   = errors.Sentinel("transfer: chunk offset does not match received data")
+	ErrChecksumMismatch = errors.Sentinel("transfer: checksum does not match received data")
+	ErrInvalidPath      = errors.Sentinel("transfer: invalid path")
+)
+
+// Chunk describes a single chunk of an entry sent to the target node as part of
+// an incremental transfer. Directories and symlinks are always sent as a single
+// chunk without a body.
+type Chunk struct {
+	ManifestEntry
+	// Offset is the position in the file that this chunk starts at.
+	Offset int64
+	// Checksum is the hex encoded SHA256 checksum of the chunk data.
+	Checksum string
+	// FileChecksum is the hex encoded SHA256 checksum of the entire file. This
+	// must be provided on the final chunk of a file and is verified before the
+	// file is moved into place.
+	FileChecksum string
+}
+
+// cleanPath validates a path received from the source node.
+func cleanPath(p string) (string, error) {
+	p = path.Clean(strings.TrimPrefix(p, "/"))
+	if p == "." || p == ".." || strings.HasPrefix(p, "../") || strings.HasSuffix(p, PartialSuffix) {
+		return "", errors.WithStack(ErrInvalidPath)
+	}
+	return p, nil
+}
+
+// PartialOffset returns the number of bytes that have been received for the
+// file at the given path. This is used by the source node to resume sending a
+// file after a failed request.
+func (t *Transfer) PartialOffset(p string) (int64, error) {
+	p, err := cleanPath(p)
+	if err != nil {
+		return 0, err
+	}
+	st, err := t.Server.Filesystem().UnixFS().Lstat(p + PartialSuffix)
+	if err != nil {
+		if errors.Is(err, ufs.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return st.Size(), nil
+}
+
+// WriteChunk writes a chunk received from the source node into the server's
+// filesystem. File data is written to a partial file next to the final location
+// and is only moved into place once the final chunk has been received and the
+// checksum of the entire file has been verified.
+func (t *Transfer) WriteChunk(c Chunk, r io.Reader) error {
+	p, err := cleanPath(c.Path)
+	if err != nil {
+		return err
+	}
+	fs := t.Server.Filesystem().UnixFS()
+
+	switch c.Type {
+	case EntryDir:
+		if st, err := fs.Lstat(p); err == nil && !st.IsDir() {
+			if err := fs.RemoveAll(p); err != nil {
+				return err
+			}
+		}
+		if err := fs.MkdirAll(p, ufs.FileMode(c.Mode)); err != nil {
+			return err
+		}
+		return fs.Chmod(p, ufs.FileMode(c.Mode))
+	case EntrySymlink:
+		if err := fs.RemoveAll(p); err != nil && !errors.Is(err, ufs.ErrNotExist) {
+			return err
+		}
+		if err := fs.MkdirAll(path.Dir(p), 0o755); err != nil {
+			return err
+		}
+		return fs.Symlink(c.Target, p)
+	case EntryFile:
+	default:
+		return errors.Errorf("transfer: unknown entry type \"%s\"", c.Type)
+	}
+
+	partial := p + PartialSuffix
+	var f ufs.File
+	if c.Offset == 0 {
+		f, err = fs.Touch(partial, ufs.O_RDWR|ufs.O_TRUNC, 0o644)
+	} else {
+		f, err = fs.OpenFile(partial, ufs.O_RDWR, 0)
+	}
+	if err != nil {
+		if errors.Is(err, ufs.ErrNotExist) {
+			return errors.WithStack(ErrOffsetMismatch)
+		}
+		return err
+	}
+	defer f.Close()
+
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if st.Size() != c.Offset {
+		return errors.WithStack(ErrOffsetMismatch)
+	}
+	if _, err := f.Seek(c.Offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	h := sha256.New()
+	n, err := io.Copy(f, io.TeeReader(io.LimitReader(t.LimitDownload(r), c.Size-c.Offset), h))
+	if err == nil && hex.EncodeToString(h.Sum(nil)) != c.Checksum {
+		err = errors.WithStack(ErrChecksumMismatch)
+	}
+	if err != nil {
+		// Throw away whatever was written from this chunk so that the source node
+		// is able to resume from the start of it.
+		_ = f.Truncate(c.Offset)
+		return err
+	}
+
+	// If this was not the final chunk of the file there is nothing left to do.
+	if c.Offset+n < c.Size {
+		return nil
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	h.Reset()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	if hex.EncodeToString(h.Sum(nil)) != c.FileChecksum {
+		_ = fs.Remove(partial)
+		return errors.WithStack(ErrChecksumMismatch)
+	}
+	_ = f.Close()
+
+	if err := fs.Chmod(partial, ufs.FileMode(c.Mode)); err != nil {
+		return err
+	}
+	mtime := time.Unix(0, c.ModTime)
+	if err := fs.Chtimes(partial, mtime, mtime); err != nil {
+		return err
+	}
+	if st, err := fs.Lstat(p); err == nil && st.IsDir() {
+		if err := fs.RemoveAll(p); err != nil {
+			return err
+		}
+	}
+	return fs.Rename(partial, p)
+}
+
+// Finalize removes every entry from the server's filesystem that is not in the
+// provided manifest, including any partial files that were never completed, and
+// then ensures the ownership of all files is correct.
+func (t *Transfer) Finalize(m Manifest) error {
+	fs := t.Server.Filesystem().UnixFS()
+
+	var remove []string
+	err := fs.WalkDir(".", func(p string, d ufs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, ufs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if p == "." {
+			return nil
+		}
+		if e, ok := m[p]; !ok || (e.Type == EntryDir) != d.IsDir() {
+			remove = append(remove, p)
+			if d.IsDir() {
+				return ufs.SkipDir
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "transfer: failed to walk server files")
+	}
+
+	for _, p := range remove {
+		if err := fs.RemoveAll(p); err != nil && !errors.Is(err, ufs.ErrNotExist) {
+			return err
+		}
+	}
+
+	return t.Server.Filesystem().Chown("/")
+}
@@ -0,0 +1,330 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"emperror.dev/errors"
+	. "github.com/franela/goblin"
+	"github.com/goccy/go-json"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/remote"
+	"github.com/pterodactyl/wings/server"
+)
+
+// newTestTransfer returns a transfer for a server with its data and backups
+// stored in a new temporary directory.
+func newTestTransfer() (*Transfer, string) {
+	dir, err := os.MkdirTemp(os.TempDir(), "pterodactyl")
+	if err != nil {
+		panic(err)
+	}
+	config.Set(&config.Configuration{
+		AuthenticationToken: "abc",
+		System: config.SystemConfiguration{
+			RootDirectory:   "/server",
+			Data:            dir,
+			BackupDirectory: filepath.Join(dir, "backups"),
+		},
+	})
+
+	s, err := server.NewEmptyManager(nil).InitServer(remote.ServerConfigurationResponse{
+		Settings: json.RawMessage(`{"uuid":"00000000-0000-0000-0000-000000000000"}`),
+	})
+	if err != nil {
+		panic(err)
+	}
+	return New(context.Background(), s), s.Filesystem().Path()
+}
+
+func checksum(v string) string {
+	h := sha256.Sum256([]byte(v))
+	return hex.EncodeToString(h[:])
+}
+
+// fileChunk returns the chunk of a file with the given contents starting at the
+// offset and ending at the end position.
+func fileChunk(p string, contents string, offset int, end int) (Chunk, *strings.Reader) {
+	return Chunk{
+		ManifestEntry: ManifestEntry{Path: p, Type: EntryFile, Size: int64(len(contents)), Mode: 0o644},
+		Offset:        int64(offset),
+		Checksum:      checksum(contents[offset:end]),
+		FileChecksum:  checksum(contents),
+	}, strings.NewReader(contents[offset:end])
+}
+
+func TestCleanPath(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("cleanPath", func() {
+		g.It("cleans paths within the server", func() {
+			for in, out := range map[string]string{
+				"file.txt":                   "file.txt",
+				"/file.txt":                  "file.txt",
+				"dir/../file.txt":            "file.txt",
+				"dir/./sub//file.txt":        "dir/sub/file.txt",
+				"dir/file.wings-partial.txt": "dir/file.wings-partial.txt",
+			} {
+				p, err := cleanPath(in)
+				g.Assert(err).IsNil()
+				g.Assert(p).Equal(out)
+			}
+		})
+
+		g.It("rejects paths outside the server", func() {
+			for _, in := range []string{"", ".", "/", "..", "../file.txt", "dir/../../file.txt", "/../etc/passwd"} {
+				_, err := cleanPath(in)
+				g.Assert(errors.Is(err, ErrInvalidPath)).IsTrue(in)
+			}
+		})
+
+		g.It("rejects partial files", func() {
+			_, err := cleanPath("file.txt" + PartialSuffix)
+			g.Assert(errors.Is(err, ErrInvalidPath)).IsTrue()
+		})
+	})
+}
+
+func TestWriteChunk(t *testing.T) {
+	g := Goblin(t)
+
+	var (
+		trnsfr *Transfer
+		root   string
+	)
+	g.Describe("Transfer#WriteChunk", func() {
+		g.BeforeEach(func() {
+			trnsfr, root = newTestTransfer()
+		})
+		g.AfterEach(func() {
+			_ = os.RemoveAll(config.Get().System.Data)
+		})
+
+		contents := "hello world, this is a test file"
+
+		g.It("writes a file sent in a single chunk", func() {
+			c, r := fileChunk("dir/file.txt", contents, 0, len(contents))
+			g.Assert(trnsfr.WriteChunk(c, r)).IsNil()
+
+			b, err := os.ReadFile(filepath.Join(root, "dir/file.txt"))
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal(contents)
+			_, err = os.Stat(filepath.Join(root, "dir/file.txt"+PartialSuffix))
+			g.Assert(os.IsNotExist(err)).IsTrue()
+		})
+
+		g.It("only moves the file into place once the final chunk is received", func() {
+			c, r := fileChunk("file.txt", contents, 0, 10)
+			g.Assert(trnsfr.WriteChunk(c, r)).IsNil()
+
+			_, err := os.Stat(filepath.Join(root, "file.txt"))
+			g.Assert(os.IsNotExist(err)).IsTrue()
+			n, err := trnsfr.PartialOffset("file.txt")
+			g.Assert(err).IsNil()
+			g.Assert(n).Equal(int64(10))
+
+			c, r = fileChunk("file.txt", contents, 10, len(contents))
+			g.Assert(trnsfr.WriteChunk(c, r)).IsNil()
+
+			b, err := os.ReadFile(filepath.Join(root, "file.txt"))
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal(contents)
+		})
+
+		g.It("rejects a chunk that does not start at the received offset", func() {
+			c, r := fileChunk("file.txt", contents, 0, 10)
+			g.Assert(trnsfr.WriteChunk(c, r)).IsNil()
+
+			c, r = fileChunk("file.txt", contents, 15, len(contents))
+			g.Assert(errors.Is(trnsfr.WriteChunk(c, r), ErrOffsetMismatch)).IsTrue()
+
+			n, _ := trnsfr.PartialOffset("file.txt")
+			g.Assert(n).Equal(int64(10))
+		})
+
+		g.It("rejects a chunk for a partial file that does not exist", func() {
+			c, r := fileChunk("file.txt", contents, 10, len(contents))
+			g.Assert(errors.Is(trnsfr.WriteChunk(c, r), ErrOffsetMismatch)).IsTrue()
+		})
+
+		g.It("discards a chunk that does not match its checksum", func() {
+			c, r := fileChunk("file.txt", contents, 0, 10)
+			g.Assert(trnsfr.WriteChunk(c, r)).IsNil()
+
+			c, _ = fileChunk("file.txt", contents, 10, 20)
+			err := trnsfr.WriteChunk(c, strings.NewReader("XXXXXXXXXX"))
+			g.Assert(errors.Is(err, ErrChecksumMismatch)).IsTrue()
+
+			// The source node is able to resume from the start of the failed chunk.
+			n, _ := trnsfr.PartialOffset("file.txt")
+			g.Assert(n).Equal(int64(10))
+
+			c, r = fileChunk("file.txt", contents, 10, len(contents))
+			g.Assert(trnsfr.WriteChunk(c, r)).IsNil()
+
+			b, err := os.ReadFile(filepath.Join(root, "file.txt"))
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal(contents)
+		})
+
+		g.It("removes the partial file if the file checksum does not match", func() {
+			c, r := fileChunk("file.txt", contents, 0, len(contents))
+			c.FileChecksum = checksum("something else")
+			g.Assert(errors.Is(trnsfr.WriteChunk(c, r), ErrChecksumMismatch)).IsTrue()
+
+			_, err := os.Stat(filepath.Join(root, "file.txt"))
+			g.Assert(os.IsNotExist(err)).IsTrue()
+			n, _ := trnsfr.PartialOffset("file.txt")
+			g.Assert(n).Equal(int64(0))
+		})
+
+		g.It("starts again when a chunk is sent from the beginning", func() {
+			c, r := fileChunk("file.txt", contents, 0, 10)
+			g.Assert(trnsfr.WriteChunk(c, r)).IsNil()
+
+			c, r = fileChunk("file.txt", contents, 0, len(contents))
+			g.Assert(trnsfr.WriteChunk(c, r)).IsNil()
+
+			b, err := os.ReadFile(filepath.Join(root, "file.txt"))
+			g.Assert(err).IsNil()
+			g.Assert(string(b)).Equal(contents)
+		})
+
+		g.It("creates directories and symlinks", func() {
+			g.Assert(trnsfr.WriteChunk(Chunk{ManifestEntry: ManifestEntry{Path: "dir/sub", Type: EntryDir, Mode: 0o750}}, nil)).IsNil()
+			g.Assert(trnsfr.WriteChunk(Chunk{ManifestEntry: ManifestEntry{Path: "link", Type: EntrySymlink, Target: "dir/sub"}}, nil)).IsNil()
+
+			st, err := os.Stat(filepath.Join(root, "dir/sub"))
+			g.Assert(err).IsNil()
+			g.Assert(st.IsDir()).IsTrue()
+			g.Assert(uint32(st.Mode().Perm())).Equal(uint32(0o750))
+
+			target, err := os.Readlink(filepath.Join(root, "link"))
+			g.Assert(err).IsNil()
+			g.Assert(target).Equal("dir/sub")
+		})
+
+		g.It("rejects chunks outside the server", func() {
+			c, r := fileChunk("../file.txt", contents, 0, len(contents))
+			g.Assert(errors.Is(trnsfr.WriteChunk(c, r), ErrInvalidPath)).IsTrue()
+
+			_, err := os.Stat(filepath.Join(filepath.Dir(root), "file.txt"))
+			g.Assert(os.IsNotExist(err)).IsTrue()
+		})
+	})
+
+	g.Describe("Transfer#Finalize", func() {
+		g.BeforeEach(func() {
+			trnsfr, root = newTestTransfer()
+		})
+		g.AfterEach(func() {
+			_ = os.RemoveAll(config.Get().System.Data)
+		})
+
+		g.It("removes entries that are not in the manifest", func() {
+			for _, p := range []string{"keep/file.txt", "keep/remove.txt", "remove/file.txt", "file.txt", "partial.txt" + PartialSuffix} {
+				g.Assert(os.MkdirAll(filepath.Join(root, filepath.Dir(p)), 0o755)).IsNil()
+				g.Assert(os.WriteFile(filepath.Join(root, p), []byte("data"), 0o644)).IsNil()
+			}
+			// An entry that changed from a directory to a file on the source node.
+			g.Assert(os.MkdirAll(filepath.Join(root, "changed"), 0o755)).IsNil()
+
+			g.Assert(trnsfr.Finalize(Manifest{
+				"keep":          {Path: "keep", Type: EntryDir},
+				"keep/file.txt": {Path: "keep/file.txt", Type: EntryFile},
+				"file.txt":      {Path: "file.txt", Type: EntryFile},
+				"changed":       {Path: "changed", Type: EntryFile},
+			})).IsNil()
+
+			for _, p := range []string{"keep/file.txt", "file.txt"} {
+				_, err := os.Stat(filepath.Join(root, p))
+				g.Assert(err).IsNil()
+			}
+			for _, p := range []string{"keep/remove.txt", "remove", "partial.txt" + PartialSuffix, "changed"} {
+				_, err := os.Stat(filepath.Join(root, p))
+				g.Assert(os.IsNotExist(err)).IsTrue(p)
+			}
+		})
+	})
+}
+
+func TestManifest_Differs(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Manifest#Differs", func() {
+		m := Manifest{
+			"file.txt": {Path: "file.txt", Type: EntryFile, Size: 10, Mode: 0o644, ModTime: 100},
+			"dir":      {Path: "dir", Type: EntryDir, Mode: 0o755, ModTime: 100},
+			"link":     {Path: "link", Type: EntrySymlink, Mode: 0o777, Target: "file.txt"},
+		}
+
+		g.It("returns false for matching entries", func() {
+			for _, e := range m {
+				g.Assert(m.Differs(e)).IsFalse(e.Path)
+			}
+		})
+
+		g.It("ignores the modification time of directories", func() {
+			g.Assert(m.Differs(ManifestEntry{Path: "dir", Type: EntryDir, Mode: 0o755, ModTime: 200})).IsFalse()
+		})
+
+		g.It("returns true for entries that have changed", func() {
+			for _, e := range []ManifestEntry{
+				{Path: "missing.txt", Type: EntryFile, Size: 10, Mode: 0o644, ModTime: 100},
+				{Path: "file.txt", Type: EntryFile, Size: 11, Mode: 0o644, ModTime: 100},
+				{Path: "file.txt", Type: EntryFile, Size: 10, Mode: 0o600, ModTime: 100},
+				{Path: "file.txt", Type: EntryFile, Size: 10, Mode: 0o644, ModTime: 101},
+				{Path: "file.txt", Type: EntryDir, Mode: 0o644},
+				{Path: "link", Type: EntrySymlink, Mode: 0o777, Target: "dir"},
+			} {
+				g.Assert(m.Differs(e)).IsTrue(e.Path)
+			}
+		})
+	})
+}
+
+// opaqueHash is a hash that does not expose the ability to copy its state.
+type opaqueHash struct {
+	hash.Hash
+}
+
+func TestCloneHash(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("cloneHash", func() {
+		contents := []byte("hello world, this is a test file")
+
+		g.It("copies the state of the hash", func() {
+			h := sha256.New()
+			h.Write(contents[:10])
+
+			// The file is empty, so the state must have been copied from the hash.
+			c, err := cloneHash(h, bytes.NewReader(nil), 10)
+			g.Assert(err).IsNil()
+			c.Write(contents[10:])
+			g.Assert(hex.EncodeToString(c.Sum(nil))).Equal(checksum(string(contents)))
+
+			// Writing to the copy does not change the original hash.
+			h.Write(contents[10:])
+			g.Assert(hex.EncodeToString(h.Sum(nil))).Equal(checksum(string(contents)))
+		})
+
+		g.It("hashes the file again if the state cannot be copied", func() {
+			h := opaqueHash{sha256.New()}
+			h.Write([]byte("this is ignored"))
+
+			c, err := cloneHash(h, bytes.NewReader(contents), 10)
+			g.Assert(err).IsNil()
+			c.Write(contents[10:])
+			g.Assert(hex.EncodeToString(c.Sum(nil))).Equal(checksum(string(contents)))
+		})
+	})
+}
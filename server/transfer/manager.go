@@ -23,6 +23,9 @@ func Outgoing() *Manager {
 type Manager struct {
 	mu        sync.RWMutex
 	transfers map[string]*Transfer
+
+	// createMu ensures only one transfer is created at a time.
+	createMu sync.Mutex
 }
 
 // NewManager returns a new transfer manager.
@@ -55,3 +58,36 @@ func (m *Manager) Get(id string) *Transfer {
 
 	return m.transfers[id]
 }
+
+// Take removes a transfer from the manager, returning false if the transfer
+// was not present. This allows callers racing to finish a transfer to ensure
+// that only one of them does so.
+func (m *Manager) Take(transfer *Transfer) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.transfers[transfer.Server.ID()] != transfer {
+		return false
+	}
+	delete(m.transfers, transfer.Server.ID())
+	return true
+}
+
+// GetOrCreate returns the transfer for the server with the given ID. If there is
+// no transfer for the server, create is called to start one and must add it to
+// the manager before returning. Transfers are only created one at a time so that
+// concurrent requests for the same server cannot both create it. The returned
+// boolean is true if the transfer was created by this call.
+func (m *Manager) GetOrCreate(id string, create func() (*Transfer, error)) (*Transfer, bool, error) {
+	m.createMu.Lock()
+	defer m.createMu.Unlock()
+
+	if t := m.Get(id); t != nil {
+		return t, false, nil
+	}
+	t, err := create()
+	if err != nil {
+		return nil, false, err
+	}
+	return t, true, nil
+}
@@ -0,0 +1,52 @@
+package transfer
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/server"
+)
+
+func TestManager_GetOrCreate(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Manager#GetOrCreate", func() {
+		g.It("only creates one transfer for concurrent requests", func() {
+			config.Set(&config.Configuration{AuthenticationToken: "abc"})
+			m := NewManager()
+			s, _ := server.New(nil)
+			s.Config().Uuid = "server-a"
+
+			var (
+				created atomic.Int32
+				wg      sync.WaitGroup
+			)
+			out := make([]*Transfer, 10)
+			for i := range out {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					t, _, err := m.GetOrCreate("server-a", func() (*Transfer, error) {
+						created.Add(1)
+						t := New(context.Background(), s)
+						m.Add(t)
+						return t, nil
+					})
+					g.Assert(err).IsNil()
+					out[i] = t
+				}(i)
+			}
+			wg.Wait()
+
+			g.Assert(created.Load()).Equal(int32(1))
+			for _, t := range out {
+				g.Assert(t == out[0]).IsTrue()
+			}
+		})
+	})
+}
@@ -0,0 +1,112 @@
+package transfer
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"emperror.dev/errors"
+
+	"github.com/pterodactyl/wings/internal/ufs"
+	"github.com/pterodactyl/wings/server/filesystem"
+)
+
+// The types of entries that can be present in a transfer manifest.
+const (
+	EntryFile    = "file"
+	EntryDir     = "dir"
+	EntrySymlink = "symlink"
+)
+
+// PartialSuffix is appended to the name of a file that is in the process of
+// being received by the target node. Partial files are never included in a
+// manifest, and are removed once the transfer completes.
+const PartialSuffix = ".wings-partial"
+
+// ManifestEntry describes a single file, directory or symlink belonging to a
+// server. Two entries are considered to be the same file if their type, size,
+// mode and modification time all match.
+type ManifestEntry struct {
+	Path    string `json:"path"`
+	Type    string `json:"type"`
+	Size    int64  `json:"size"`
+	Mode    uint32 `json:"mode"`
+	ModTime int64  `json:"mtime"`
+	Target  string `json:"target,omitempty"`
+}
+
+// Manifest is a listing of every entry in a server's data directory, keyed by
+// the path of the entry relative to the root of the server.
+type Manifest map[string]ManifestEntry
+
+// BuildManifest walks the given filesystem and returns a manifest of every entry
+// within it.
+func BuildManifest(fsys *filesystem.Filesystem) (Manifest, error) {
+	m := make(Manifest)
+	err := fsys.UnixFS().WalkDir(".", func(p string, d ufs.DirEntry, err error) error {
+		if err != nil {
+			// Files can disappear while we are walking a running server, skip over
+			// them rather than failing the entire transfer.
+			if errors.Is(err, ufs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if p == "." || strings.HasSuffix(p, PartialSuffix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, ufs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		e := ManifestEntry{Path: p, Mode: uint32(info.Mode().Perm())}
+		switch {
+		case info.IsDir():
+			e.Type = EntryDir
+		case info.Mode()&fs.ModeSymlink != 0:
+			e.Type = EntrySymlink
+			// UnixFS does not expose readlink, but reading the link itself never
+			// follows it so this is safe to do against the real path.
+			target, err := os.Readlink(filepath.Join(fsys.Path(), p))
+			if err != nil {
+				return nil
+			}
+			e.Target = target
+		case info.Mode().IsRegular():
+			e.Type = EntryFile
+			e.Size = info.Size()
+			e.ModTime = info.ModTime().UnixNano()
+		default:
+			// Skip sockets, devices and other special files.
+			return nil
+		}
+		m[p] = e
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "transfer: failed to build manifest")
+	}
+	return m, nil
+}
+
+// Differs returns true if the entry does not exist in the manifest, or if the
+// entry in the manifest does not match the one provided.
+func (m Manifest) Differs(e ManifestEntry) bool {
+	o, ok := m[e.Path]
+	if !ok || o.Type != e.Type || o.Mode != e.Mode {
+		return true
+	}
+	switch e.Type {
+	case EntryFile:
+		return o.Size != e.Size || o.ModTime != e.ModTime
+	case EntrySymlink:
+		return o.Target != e.Target
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package transfer
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/juju/ratelimit"
+
+	"github.com/pterodactyl/wings/internal/progress"
+	"github.com/pterodactyl/wings/remote"
+	"github.com/pterodactyl/wings/server"
+	"github.com/pterodactyl/wings/system"
+)
+
+// How often progress is sent to websocket clients, and to the Panel.
+const (
+	progressInterval       = time.Second * 5
+	panelProgressInterval  = time.Second * 15
+	progressBarWidth       = 25
+	panelProgressTimeout   = time.Second * 5
+	unknownProgressSeconds = -1
+)
+
+// ProgressEvent is the payload published with server.TransferProgressEvent while
+// data is being sent to the target node.
+type ProgressEvent struct {
+	Status   Status         `json:"status"`
+	Progress ProgressReport `json:"progress"`
+}
+
+// ProgressReport describes how much data has been sent for a transfer.
+type ProgressReport struct {
+	// Bytes is the number of bytes that have been sent to the target node.
+	Bytes uint64 `json:"bytes"`
+	// Total is the estimated total number of bytes that will be sent.
+	Total uint64 `json:"total"`
+	// Rate is the send rate in bytes per second since the last report.
+	Rate uint64 `json:"rate"`
+	// Eta is the estimated number of seconds until all data has been sent, or -1
+	// if it cannot be estimated yet.
+	Eta int64 `json:"eta"`
+}
+
+// newBucket returns a token bucket limiting data to the given rate in MiB/s, or
+// nil if the rate is less than 1.
+func newBucket(rate int) *ratelimit.Bucket {
+	if limit := int64(rate) * 1024 * 1024; limit > 0 {
+		return ratelimit.NewBucketWithRate(float64(limit), limit)
+	}
+	return nil
+}
+
+// limitReader wraps the reader so that data read from it is limited to the
+// configured upload rate. The same limit is shared by every reader wrapped for
+// the transfer.
+func (t *Transfer) limitReader(r io.Reader) io.Reader {
+	if t.bucket == nil {
+		return r
+	}
+	return ratelimit.Reader(r, t.bucket)
+}
+
+// LimitDownload wraps the reader so that data received from the source node is
+// limited to the configured download rate. The same limit is shared by every
+// reader wrapped for the transfer.
+func (t *Transfer) LimitDownload(r io.Reader) io.Reader {
+	if t.download == nil {
+		return r
+	}
+	return ratelimit.Reader(r, t.download)
+}
+
+// reportProgress sends the progress of the transfer to websocket clients and the
+// Panel until the context is canceled.
+func (t *Transfer) reportProgress(ctx context.Context, p *progress.Progress) {
+	tc := time.NewTicker(progressInterval)
+	defer tc.Stop()
+
+	last, lastAt := p.Written(), time.Now()
+	var lastPanel time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-tc.C:
+			written := p.Written()
+			r := ProgressReport{Bytes: written, Total: p.Total(), Eta: unknownProgressSeconds}
+			if elapsed := now.Sub(lastAt).Seconds(); elapsed > 0 && written >= last {
+				r.Rate = uint64(float64(written-last) / elapsed)
+			}
+			if r.Rate > 0 && r.Total >= r.Bytes {
+				r.Eta = int64((r.Total - r.Bytes) / r.Rate)
+			}
+			last, lastAt = written, now
+
+			msg := "Uploading " + p.Progress(progressBarWidth) + " (" + system.FormatBytes(r.Rate) + "/s"
+			if r.Eta >= 0 {
+				msg += ", " + (time.Duration(r.Eta) * time.Second).String() + " remaining"
+			}
+			t.SendMessage(msg + ")")
+			t.Server.Events().Publish(server.TransferProgressEvent, ProgressEvent{Status: t.Status(), Progress: r})
+
+			if now.Sub(lastPanel) >= panelProgressInterval {
+				lastPanel = now
+				t.sendPanelProgress(ctx, r)
+			}
+		}
+	}
+}
+
+// sendPanelProgress reports the progress of the transfer to the Panel. Failures
+// are only logged since they should never cause the transfer itself to fail.
+func (t *Transfer) sendPanelProgress(ctx context.Context, r ProgressReport) {
+	c := t.Server.Client()
+	if c == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, panelProgressTimeout)
+	defer cancel()
+	err := c.SendTransferProgress(ctx, t.Server.ID(), remote.TransferProgressRequest{
+		Status: t.Status().String(),
+		Bytes:  r.Bytes,
+		Total:  r.Total,
+		Rate:   r.Rate,
+		Eta:    r.Eta,
+	})
+	if err != nil {
+		t.Log().WithError(err).Debug("failed to send transfer progress to panel")
+	}
+}
@@ -9,9 +9,11 @@ import (
 	"io"
 	"mime/multipart"
 	"net/http"
-	"time"
+	"strings"
 
-	"github.com/pterodactyl/wings/internal/progress"
+	"github.com/goccy/go-json"
+
+	"github.com/pterodactyl/wings/config"
 )
 
 // PushArchiveToTarget POSTs the archive to the target node and returns the
@@ -31,21 +33,10 @@ func (t *Transfer) PushArchiveToTarget(url, token string) ([]byte, error) {
 
 	t.SendMessage("Streaming archive to destination...")
 
-	// Send the upload progress to the websocket every 5 seconds.
+	// Send the upload progress to the websocket and the Panel while streaming.
 	ctx2, cancel2 := context.WithCancel(ctx)
 	defer cancel2()
-	go func(ctx context.Context, p *progress.Progress, tc *time.Ticker) {
-		defer tc.Stop()
-
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-tc.C:
-				t.SendMessage("Uploading " + p.Progress(25))
-			}
-		}
-	}(ctx2, a.Progress(), time.NewTicker(5*time.Second))
+	go t.reportProgress(ctx2, a.Progress())
 
 	// Create a new request using the pipe as the body.
 	body, writer := io.Pipe()
@@ -62,9 +53,13 @@ func (t *Transfer) PushArchiveToTarget(url, token string) ([]byte, error) {
 	defer mp.Close()
 	req.Header.Set("Content-Type", mp.FormDataContentType())
 
+	backups := t.localBackups()
+
 	// Create a new goroutine to write the archive to the pipe used by the
 	// multipart writer.
-	errChan := make(chan error)
+	// The channel is buffered so that an error can be reported without waiting on
+	// the request, which does not complete until the body has been closed.
+	errChan := make(chan error, 1)
 	go func() {
 		defer close(errChan)
 		defer writer.Close()
@@ -74,8 +69,14 @@ func (t *Transfer) PushArchiveToTarget(url, token string) ([]byte, error) {
 		defer src.Close()
 		defer pw.Close()
 
+		// Declare the backups that will be sent so the target only accepts those.
+		if err := mp.WriteField(BackupsPart, strings.Join(backupIdentifiers(backups), ",")); err != nil {
+			errChan <- errors.New("failed to stream backups")
+			return
+		}
+
 		h := sha256.New()
-		tee := io.TeeReader(src, h)
+		tee := io.TeeReader(t.limitReader(src), h)
 
 		dest, err := mp.CreateFormFile("archive", "archive.tar.gz")
 		if err != nil {
@@ -116,6 +117,32 @@ func (t *Transfer) PushArchiveToTarget(url, token string) ([]byte, error) {
 			return
 		}
 
+		// Send any local backups for the server after the archive, each followed by
+		// its own checksum so the target can verify it independently. The progress
+		// no longer writes to the archive, which has been closed, and only counts
+		// the data sent for the backups.
+		a.Progress().Writer = nil
+		for _, b := range backups {
+			t.SendMessage("Streaming backup " + b.Identifier() + " to destination...")
+			if size, err := b.Size(); err == nil {
+				a.Progress().SetTotal(a.Progress().Total() + uint64(size))
+			}
+			dest, err := mp.CreateFormFile(BackupPartPrefix+b.Identifier(), b.Identifier()+".tar.gz")
+			if err != nil {
+				errChan <- errors.New("failed to create form file")
+				return
+			}
+			sum, err := t.sendBackup(b, io.MultiWriter(dest, a.Progress()))
+			if err != nil {
+				errChan <- err
+				return
+			}
+			if err := mp.WriteField(BackupChecksumPartPrefix+b.Identifier(), sum); err != nil {
+				errChan <- errors.New("failed to stream backup checksum")
+				return
+			}
+		}
+
 		cancel2()
 		t.SendMessage("Finished streaming archive to destination.")
 
@@ -126,7 +153,7 @@ func (t *Transfer) PushArchiveToTarget(url, token string) ([]byte, error) {
 	}()
 
 	t.Log().Debug("sending archive to destination")
-	client := http.Client{Timeout: 0}
+	client := http.Client{Timeout: 0, Transport: config.ClientTransport()}
 	res, err := client.Do(req)
 	if err != nil {
 		t.Log().Debug("error while sending archive to destination")
@@ -161,6 +188,14 @@ func (t *Transfer) PushArchiveToTarget(url, token string) ([]byte, error) {
 			return nil, errors.New(string(v))
 		}
 
+		// The target responds with the backups it received, which now belong to it.
+		var data struct {
+			Backups []string `json:"backups"`
+		}
+		if err := json.Unmarshal(v, &data); err == nil {
+			t.removeSentBackups(data.Backups)
+		}
+
 		return v, nil
 	}
 }
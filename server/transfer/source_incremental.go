@@ -0,0 +1,405 @@
+package transfer
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"emperror.dev/errors"
+	"github.com/goccy/go-json"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/internal/progress"
+	"github.com/pterodactyl/wings/internal/ufs"
+)
+
+// The size of each chunk of file data sent to the target node during an
+// incremental transfer, and the number of times a single chunk will be retried
+// before the transfer is failed.
+const (
+	chunkSize        = 8 * 1024 * 1024
+	maxChunkAttempts = 10
+)
+
+// ErrIncrementalUnsupported is returned when the target node does not support
+// incremental transfers, in which case the caller should fall back to sending
+// a single archive.
+var ErrIncrementalUnsupported = errors.Sentinel("transfer: target does not support incremental transfers")
+
+// PushIncrementalToTarget copies the server's files to the target node while the
+// server is still running, then calls the provided stop function and sends only
+// the files that changed in the meantime. Every file is sent in checksummed chunks,
+// and a chunk that fails to send is resumed from the last offset the target has
+// received rather than restarting the entire transfer.
+func (t *Transfer) PushIncrementalToTarget(url, token string, stop func() error) error {
+	ctx := t.ctx
+	c := &incrementalClient{
+		base:   strings.TrimSuffix(url, "/") + "/incremental",
+		token:  token,
+		client: &http.Client{Transport: config.ClientTransport()},
+	}
+
+	t.SetStatus(StatusProcessing)
+	t.SendMessage("Requesting file manifest from destination...")
+	backups := t.localBackups()
+	remote, err := c.begin(ctx, backupIdentifiers(backups))
+	if err != nil {
+		return err
+	}
+
+	t.SendMessage("Copying server files to destination while the server is running...")
+	if _, err := t.syncToTarget(ctx, c, remote); err != nil {
+		return err
+	}
+
+	// Backups never change once they have been created, so they can all be sent
+	// before the server is stopped.
+	for _, b := range backups {
+		t.SendMessage("Copying backup " + b.Identifier() + " to destination...")
+		if err := c.withRetries(ctx, t, func() error { return c.putBackup(ctx, t, b) }); err != nil {
+			return err
+		}
+	}
+
+	t.SendMessage("Stopping server to copy remaining changes...")
+	if err := stop(); err != nil {
+		return errors.WrapIf(err, "transfer: failed to stop server")
+	}
+
+	t.SendMessage("Copying changed files to destination...")
+	local, err := t.syncToTarget(ctx, c, remote)
+	if err != nil {
+		return err
+	}
+
+	t.SendMessage("Finalizing transfer on destination...")
+	received, err := c.complete(ctx, local)
+	if err != nil {
+		return err
+	}
+	t.removeSentBackups(received)
+	t.SendMessage("Finished copying server files to destination.")
+	return nil
+}
+
+// syncToTarget sends every entry in the server's filesystem that differs from the
+// remote manifest to the target node. The remote manifest is updated as entries
+// are sent so that subsequent calls only send what has changed since. The local
+// manifest that was synced is returned.
+func (t *Transfer) syncToTarget(ctx context.Context, c *incrementalClient, remote Manifest) (Manifest, error) {
+	local, err := BuildManifest(t.Server.Filesystem())
+	if err != nil {
+		return nil, err
+	}
+
+	var total uint64
+	var changed []ManifestEntry
+	for _, e := range local {
+		if remote.Differs(e) {
+			changed = append(changed, e)
+			total += uint64(e.Size)
+		}
+	}
+	// Sending entries in path order ensures that directories are always created
+	// before the entries within them.
+	sort.Slice(changed, func(i, j int) bool {
+		return changed[i].Path < changed[j].Path
+	})
+
+	p := progress.NewProgress(total)
+	ctx2, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go t.reportProgress(ctx2, p)
+
+	for _, e := range changed {
+		var err error
+		if e.Type == EntryFile {
+			err = t.sendFile(ctx, c, e, p)
+		} else {
+			err = c.withRetries(ctx, t, func() error {
+				return c.putChunk(ctx, Chunk{ManifestEntry: e}, http.NoBody)
+			})
+		}
+		if err != nil {
+			if errors.Is(err, ufs.ErrNotExist) || errors.Is(err, io.ErrUnexpectedEOF) {
+				// The file was removed or modified while we were reading it. Drop it
+				// from the local manifest so it is sent again on the next pass.
+				delete(local, e.Path)
+				continue
+			}
+			return nil, err
+		}
+		remote[e.Path] = e
+	}
+	return local, nil
+}
+
+// sendFile sends a single file to the target node in chunks.
+func (t *Transfer) sendFile(ctx context.Context, c *incrementalClient, e ManifestEntry, p *progress.Progress) error {
+	f, err := t.Server.Filesystem().UnixFS().Open(e.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	buf := make([]byte, chunkSize)
+	var offset int64
+	var attempts int
+	for {
+		n := e.Size - offset
+		if n > chunkSize {
+			n = chunkSize
+		}
+		chunk := buf[:n]
+		if _, err := f.ReadAt(chunk, offset); err != nil && !(errors.Is(err, io.EOF) && n == 0) {
+			if errors.Is(err, io.EOF) {
+				return errors.WithStack(io.ErrUnexpectedEOF)
+			}
+			return err
+		}
+
+		sum := sha256.Sum256(chunk)
+		ch := Chunk{ManifestEntry: e, Offset: offset, Checksum: hex.EncodeToString(sum[:])}
+		if offset+n == e.Size {
+			fh, err := cloneHash(h, f, offset)
+			if err != nil {
+				return err
+			}
+			fh.Write(chunk)
+			ch.FileChecksum = hex.EncodeToString(fh.Sum(nil))
+		}
+
+		err := c.putChunk(ctx, ch, t.limitReader(bytes.NewReader(chunk)))
+		if err == nil {
+			h.Write(chunk)
+			_, _ = p.Write(chunk)
+			offset += n
+			attempts = 0
+			if offset >= e.Size {
+				return nil
+			}
+			continue
+		}
+
+		attempts++
+		if ctx.Err() != nil || attempts >= maxChunkAttempts {
+			return err
+		}
+		t.Log().WithField("path", e.Path).WithField("offset", offset).WithError(err).Warn("failed to send chunk to destination, retrying")
+		if err := sleepContext(ctx, backoffDelay(attempts)); err != nil {
+			return err
+		}
+
+		// Find out how much of the file the target actually has and resume from that
+		// point, hashing everything before it again so the file checksum is correct.
+		r, err := c.offset(ctx, e.Path)
+		if err != nil || r == offset {
+			continue
+		}
+		if r > e.Size || r%chunkSize != 0 {
+			r = 0
+		}
+		h.Reset()
+		if _, err := io.Copy(h, io.NewSectionReader(f, 0, r)); err != nil {
+			return err
+		}
+		offset = r
+	}
+}
+
+// cloneHash returns a copy of the given hash of the first n bytes of the file in
+// its current state. If the state of the hash cannot be copied, the first n bytes
+// of the file are hashed again instead.
+func cloneHash(h hash.Hash, f io.ReaderAt, n int64) (hash.Hash, error) {
+	c := sha256.New()
+	if m, ok := h.(encoding.BinaryMarshaler); ok {
+		if b, err := m.MarshalBinary(); err == nil {
+			if err := c.(encoding.BinaryUnmarshaler).UnmarshalBinary(b); err == nil {
+				return c, nil
+			}
+		}
+	}
+	c.Reset()
+	if _, err := io.Copy(c, io.NewSectionReader(f, 0, n)); err != nil {
+		return nil, errors.Wrap(err, "transfer: failed to hash file")
+	}
+	return c, nil
+}
+
+func backoffDelay(attempt int) time.Duration {
+	d := time.Second * time.Duration(1<<uint(attempt))
+	if d > time.Second*30 {
+		d = time.Second * 30
+	}
+	return d
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// incrementalClient makes requests to the incremental transfer endpoints on the
+// target node.
+type incrementalClient struct {
+	base   string
+	token  string
+	client *http.Client
+}
+
+func (c *incrementalClient) request(ctx context.Context, method string, path string, q url.Values, body io.Reader) (*http.Response, error) {
+	u := c.base + path
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", c.token)
+	return c.client.Do(req)
+}
+
+// responseError returns an error for an unexpected response from the target.
+func responseError(res *http.Response) error {
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+	return fmt.Errorf("transfer: unexpected status code from destination: %d: %s", res.StatusCode, strings.TrimSpace(string(b)))
+}
+
+// begin starts the incremental transfer on the target and returns the manifest of
+// the files it already has.
+func (c *incrementalClient) begin(ctx context.Context, backups []string) (Manifest, error) {
+	b, err := json.Marshal(map[string][]string{"backups": backups})
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.request(ctx, http.MethodPost, "", nil, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusMethodNotAllowed {
+		return nil, errors.WithStack(ErrIncrementalUnsupported)
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, responseError(res)
+	}
+	var data struct {
+		Manifest Manifest `json:"manifest"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&data); err != nil {
+		return nil, errors.Wrap(err, "transfer: failed to decode manifest")
+	}
+	if data.Manifest == nil {
+		data.Manifest = make(Manifest)
+	}
+	return data.Manifest, nil
+}
+
+// offset returns the number of bytes the target has received for a file.
+func (c *incrementalClient) offset(ctx context.Context, p string) (int64, error) {
+	res, err := c.request(ctx, http.MethodHead, "/files", url.Values{"path": {p}}, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return 0, responseError(res)
+	}
+	return strconv.ParseInt(res.Header.Get("X-Transfer-Offset"), 10, 64)
+}
+
+// putChunk sends a single chunk of an entry to the target.
+func (c *incrementalClient) putChunk(ctx context.Context, ch Chunk, body io.Reader) error {
+	q := url.Values{
+		"path":   {ch.Path},
+		"type":   {ch.Type},
+		"size":   {strconv.FormatInt(ch.Size, 10)},
+		"mode":   {strconv.FormatUint(uint64(ch.Mode), 10)},
+		"mtime":  {strconv.FormatInt(ch.ModTime, 10)},
+		"offset": {strconv.FormatInt(ch.Offset, 10)},
+	}
+	if ch.Target != "" {
+		q.Set("target", ch.Target)
+	}
+	if ch.Checksum != "" {
+		q.Set("checksum", ch.Checksum)
+	}
+	if ch.FileChecksum != "" {
+		q.Set("file_checksum", ch.FileChecksum)
+	}
+	res, err := c.request(ctx, http.MethodPut, "/files", q, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusConflict {
+		return errors.WithStack(ErrOffsetMismatch)
+	}
+	if res.StatusCode != http.StatusNoContent {
+		return responseError(res)
+	}
+	return nil
+}
+
+// complete tells the target that all files have been sent, passing along the
+// final manifest so that any files removed from the source are also removed
+// on the target.
+func (c *incrementalClient) complete(ctx context.Context, m Manifest) ([]string, error) {
+	b, err := json.Marshal(map[string]Manifest{"manifest": m})
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.request(ctx, http.MethodPost, "/complete", nil, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, responseError(res)
+	}
+	var data struct {
+		Backups []string `json:"backups"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&data); err != nil {
+		return nil, errors.Wrap(err, "transfer: failed to decode response")
+	}
+	return data.Backups, nil
+}
+
+// withRetries runs the given function until it succeeds, retrying with a delay
+// between each attempt.
+func (c *incrementalClient) withRetries(ctx context.Context, t *Transfer, fn func() error) error {
+	var err error
+	for attempt := 1; attempt <= maxChunkAttempts; attempt++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		t.Log().WithError(err).Warn("failed to send entry to destination, retrying")
+		if err := sleepContext(ctx, backoffDelay(attempt)); err != nil {
+			return err
+		}
+	}
+	return err
+}
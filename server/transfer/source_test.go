@@ -0,0 +1,71 @@
+package transfer
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/franela/goblin"
+
+	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/server/backup"
+)
+
+// newTestTarget returns a target node that reads the entire archive sent to it
+// and then responds with the given status and body.
+func newTestTarget(status int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.Copy(io.Discard, r.Body)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func TestTransfer_PushArchiveToTarget(t *testing.T) {
+	g := Goblin(t)
+
+	g.Describe("Transfer#PushArchiveToTarget", func() {
+		var (
+			trnsfr *Transfer
+			id     = "11111111-1111-1111-1111-111111111111"
+		)
+		g.BeforeEach(func() {
+			trnsfr, _ = newTestTransfer()
+			trnsfr.Backups = []string{id}
+
+			b := backup.NewLocal(nil, id, "")
+			g.Assert(os.MkdirAll(filepath.Dir(b.Path()), 0o755)).IsNil()
+			g.Assert(os.WriteFile(b.Path(), []byte("backup"), 0o644)).IsNil()
+		})
+		g.AfterEach(func() {
+			_ = os.RemoveAll(config.Get().System.Data)
+		})
+
+		g.It("removes the backups the target received once it succeeds", func() {
+			srv := newTestTarget(http.StatusOK, `{"backups":["`+id+`"]}`)
+			defer srv.Close()
+
+			_, err := trnsfr.PushArchiveToTarget(srv.URL, "token")
+			g.Assert(err).IsNil()
+
+			_, err = os.Stat(backup.NewLocal(nil, id, "").Path())
+			g.Assert(os.IsNotExist(err)).IsTrue()
+		})
+
+		g.It("keeps the backups if the target fails to complete the transfer", func() {
+			// The target responds with an error if the Panel could not be notified
+			// that the transfer was successful.
+			srv := newTestTarget(http.StatusInternalServerError, `{"error":"failed to set transfer status"}`)
+			defer srv.Close()
+
+			_, err := trnsfr.PushArchiveToTarget(srv.URL, "token")
+			g.Assert(err == nil).IsFalse()
+
+			_, err = os.Stat(backup.NewLocal(nil, id, "").Path())
+			g.Assert(err).IsNil()
+		})
+	})
+}
@@ -2,11 +2,14 @@ package transfer
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/apex/log"
+	"github.com/juju/ratelimit"
 	"github.com/mitchellh/colorstring"
 
+	"github.com/pterodactyl/wings/config"
 	"github.com/pterodactyl/wings/server"
 	"github.com/pterodactyl/wings/system"
 )
@@ -48,11 +51,29 @@ type Transfer struct {
 
 	// Server associated with the transfer.
 	Server *server.Server
+	// Backups is the UUIDs of the server's local backups that should be sent to
+	// the target node along with the server's files.
+	Backups []string
 	// status of the transfer.
 	status *system.Atomic[Status]
 
 	// archive is the archive that is being created for the transfer.
 	archive *Archive
+	// activity is the last time data was received for an incremental transfer.
+	activity *system.Atomic[time.Time]
+	// bucket limits the rate data is sent to the target node, if configured.
+	bucket *ratelimit.Bucket
+	// download limits the rate data is received from the source node, if
+	// configured.
+	download *ratelimit.Bucket
+
+	mu sync.Mutex
+	// received is the backups received from the source node for an incoming
+	// transfer.
+	received []*IncomingBackup
+	// declared is the UUIDs of the backups the source node declared it will send
+	// for an incoming transfer. Any other backups are rejected.
+	declared map[string]bool
 }
 
 // New returns a new transfer instance for the given server.
@@ -63,8 +84,11 @@ func New(ctx context.Context, s *server.Server) *Transfer {
 		ctx:    ctx,
 		cancel: &cancel,
 
-		Server: s,
-		status: system.NewAtomic(StatusPending),
+		Server:   s,
+		status:   system.NewAtomic(StatusPending),
+		activity: system.NewAtomic(time.Now()),
+		bucket:   newBucket(config.Get().System.Transfers.UploadLimit),
+		download: newBucket(config.Get().System.Transfers.DownloadLimit),
 	}
 }
 
@@ -105,6 +129,17 @@ func (t *Transfer) SetStatus(s Status) {
 	t.Server.Events().Publish(server.TransferStatusEvent, s)
 }
 
+// Touch records that data was received for the transfer.
+func (t *Transfer) Touch() {
+	t.activity.Store(time.Now())
+}
+
+// IdleFor returns the amount of time since data was last received for the
+// transfer.
+func (t *Transfer) IdleFor() time.Duration {
+	return time.Since(t.activity.Load())
+}
+
 // SendMessage sends a message to the server's console.
 func (t *Transfer) SendMessage(v string) {
 	t.Server.Events().Publish(
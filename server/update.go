@@ -28,6 +28,8 @@ func (s *Server) SyncWithEnvironment() {
 		Mounts:      s.Mounts(),
 		Allocations: cfg.Allocations,
 		Limits:      cfg.Build,
+		Ulimits:     s.Ulimits(),
+		Devices:     s.Devices(),
 	})
 
 	// For Docker specific environments we also want to update the configured image
@@ -35,6 +37,9 @@ func (s *Server) SyncWithEnvironment() {
 	if e, ok := s.Environment.(*docker.Environment); ok {
 		s.Log().Debug("syncing stop configuration with configured docker environment")
 		e.SetImage(cfg.Container.Image)
+		e.SetRegistryAuth(cfg.RegistryCredentials())
+		e.SetSecurityProfile(cfg.SecurityProfile())
+		e.SetNetworkSettings(docker.NetworkSettings{Owner: cfg.OwnerUuid, Links: cfg.Networks})
 		e.SetStopConfiguration(s.ProcessConfiguration().Stop)
 	}
 
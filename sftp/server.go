@@ -12,6 +12,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 
 	"emperror.dev/errors"
 	"github.com/apex/log"
@@ -20,6 +21,7 @@ import (
 	"golang.org/x/crypto/ssh"
 
 	"github.com/pterodactyl/wings/config"
+	"github.com/pterodactyl/wings/internal/restart"
 	"github.com/pterodactyl/wings/remote"
 	"github.com/pterodactyl/wings/server"
 )
@@ -35,6 +37,10 @@ type SFTPServer struct {
 	BasePath string
 	ReadOnly bool
 	Listen   string
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    sync.WaitGroup
 }
 
 func New(m *server.Manager) *SFTPServer {
@@ -94,17 +100,26 @@ func (c *SFTPServer) Run() error {
 	}
 	conf.AddHostKey(private)
 
-	listener, err := net.Listen("tcp", c.Listen)
+	listener, err := restart.Listen("sftp", c.Listen)
 	if err != nil {
 		return err
 	}
+	c.mu.Lock()
+	c.listener = listener
+	c.mu.Unlock()
 
 	public := string(ssh.MarshalAuthorizedKey(private.PublicKey()))
 	log.WithField("listen", c.Listen).WithField("public_key", strings.Trim(public, "\n")).Info("sftp server listening for connections")
 
 	for {
-		if conn, _ := listener.Accept(); conn != nil {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if conn != nil {
+			c.conns.Add(1)
 			go func(conn net.Conn) {
+				defer c.conns.Done()
 				defer conn.Close()
 				if err := c.AcceptInbound(conn, conf); err != nil {
 					log.WithField("error", err).WithField("ip", conn.RemoteAddr().String()).Error("sftp: failed to accept inbound connection")
@@ -114,6 +129,28 @@ func (c *SFTPServer) Run() error {
 	}
 }
 
+// Shutdown stops accepting new connections and waits for the open connections to
+// be closed by their clients, or for the context to be canceled.
+func (c *SFTPServer) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	if c.listener != nil {
+		_ = c.listener.Close()
+	}
+	c.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.conns.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // AcceptInbound handles an inbound connection to the instance and determines if we should
 // serve the request or not.
 func (c *SFTPServer) AcceptInbound(conn net.Conn, config *ssh.ServerConfig) error {
@@ -48,3 +48,27 @@ func (r *Rate) Reset() {
 	r.last = time.Now()
 	r.mu.Unlock()
 }
+
+// SetLimit changes the number of items allowed per duration of time.
+func (r *Rate) SetLimit(limit uint64, duration time.Duration) {
+	r.mu.Lock()
+	r.limit = limit
+	r.duration = duration
+	r.mu.Unlock()
+}
+
+// State returns the number of items counted in the current duration and the time
+// the current duration started.
+func (r *Rate) State() (uint64, time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.count, r.last
+}
+
+// Restore restores the state of the rate limiter, as previously returned by State.
+func (r *Rate) Restore(count uint64, last time.Time) {
+	r.mu.Lock()
+	r.count = count
+	r.last = last
+	r.mu.Unlock()
+}